@@ -12,6 +12,8 @@ import (
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 
+	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/repository"
 	"github.com/Brix101/budgetto-backend/internal/util"
 )
 
@@ -31,11 +33,27 @@ func SchedulerCmd(ctx context.Context) *cobra.Command {
 			logger := util.NewLogger("api")
 			defer func() { _ = logger.Sync() }()
 
+			db, err := util.NewDatabasePool(ctx, 4)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			// Scheduled jobs have no actor, their audit events are the system's.
+			conn := repository.NewAuditedConnection(db, func(context.Context) domain.AuditInfo {
+				return domain.AuditInfo{}
+			})
+
+			userRepo := repository.NewPostgresUser(conn)
+			trashRepo := repository.NewPostgresTrash(conn)
+
 			fmt.Println(port, args)
 			s := gocron.NewScheduler(time.UTC)
 			s.SetMaxConcurrentJobs(8, gocron.WaitMode)
 
 			_, _ = s.Every(5).Seconds().Do(func() { enqueueLiveActivities(ctx, logger) })
+			_, _ = s.Every(1).Hour().Do(func() { purgeDeletedUsers(ctx, logger, userRepo) })
+			_, _ = s.Every(1).Hour().Do(func() { purgeTrash(ctx, logger, trashRepo) })
 			s.StartAsync()
 
 			srv := &http.Server{Addr: ":8080"}
@@ -55,3 +73,27 @@ func SchedulerCmd(ctx context.Context) *cobra.Command {
 func enqueueLiveActivities(_ context.Context, logger *zap.Logger) {
 	logger.Info("Pinging ....")
 }
+
+func purgeDeletedUsers(ctx context.Context, logger *zap.Logger, userRepo domain.UserRepository) {
+	purged, err := userRepo.PurgeScheduled(ctx)
+	if err != nil {
+		logger.Error("❌❌❌ Failed to purge deleted users:", zap.Error(err))
+		return
+	}
+
+	if purged > 0 {
+		logger.Info("🗑️🗑️🗑️ Purged deleted users", zap.Int64("count", purged))
+	}
+}
+
+func purgeTrash(ctx context.Context, logger *zap.Logger, trashRepo domain.TrashRepository) {
+	purged, err := trashRepo.Purge(ctx, time.Now().Add(-domain.TrashRetention()))
+	if err != nil {
+		logger.Error("❌❌❌ Failed to purge trash:", zap.Error(err))
+		return
+	}
+
+	if purged > 0 {
+		logger.Info("🗑️🗑️🗑️ Purged trash", zap.Int64("count", purged))
+	}
+}
@@ -12,7 +12,6 @@ import (
 
 	"github.com/Brix101/budgetto-backend/internal/domain"
 	"github.com/Brix101/budgetto-backend/internal/middlewares"
-	"github.com/Brix101/budgetto-backend/internal/util"
 )
 
 type AccountCtx struct{}
@@ -21,16 +20,22 @@ func (a api) AccountRoutes() chi.Router {
 	r := chi.NewRouter()
 
 	r.Use(middlewares.Auth)
+	r.Use(middlewares.Scope("accounts"))
+	r.Use(a.Idempotency)
 
-	r.Get("/", a.accountListHandler)
-	r.Post("/", a.accountCreateHandler)
+	r.Group(func(r chi.Router) {
+		r.Use(a.MemberCtx)
+
+		r.Get("/", a.accountListHandler)
+		r.Post("/", a.accountCreateHandler)
+	})
 
 	r.Route("/{id}", func(r chi.Router) {
 		r.Use(a.AccountCtx)
 
 		r.Get("/", a.accountGetHandler)
-		r.Put("/", a.accountUpdateHandler)
-		r.Delete("/", a.accountDeleteHandler)
+		r.With(a.IfMatch(AccountCtx{})).Put("/", a.accountUpdateHandler)
+		r.With(a.IfMatch(AccountCtx{})).Delete("/", a.accountDeleteHandler)
 	})
 
 	return r
@@ -40,12 +45,6 @@ func (a api) AccountCtx(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
-		sub, err := util.GetSub(ctx)
-		if err != nil {
-			a.errorResponse(w, r, 500, err)
-			return
-		}
-
 		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
 			a.errorResponse(w, r, 500, err)
@@ -62,8 +61,7 @@ func (a api) AccountCtx(next http.Handler) http.Handler {
 			return
 		}
 
-		if item.CreatedBy != sub {
-			a.errorResponse(w, r, 403, domain.ErrForbidden)
+		if _, ok := a.authorizeMember(w, r, item.HouseholdID); !ok {
 			return
 		}
 
@@ -76,20 +74,17 @@ type createAccountRequest struct {
 	Name    string  `json:"name" validate:"required"`
 	Balance float64 `json:"balance" validate:"gte=0"`
 	Note    string  `json:"note,omitempty"`
+	// FamilyMemberID optionally marks whose account this is.
+	FamilyMemberID *uint `json:"family_member_id,omitempty"`
 }
 
 func (a api) accountListHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
-	user := r.Context().Value(middlewares.AuthCtx{}).(*domain.UserClaims)
-	sub, err := user.GetSubject()
-	if err != nil {
-		a.errorResponse(w, r, 500, err)
-		return
-	}
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
 
-	accs, err := a.accountRepo.GetByUserSUB(ctx, sub)
+	accs, err := a.accountRepo.GetByHouseholdID(ctx, mem.HouseholdID)
 	if err != nil {
 		a.logger.Error("failed to fetch accounts from database", zap.Error(err))
 		a.errorResponse(w, r, 500, err)
@@ -111,11 +106,7 @@ func (a api) accountCreateHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
-	sub, err := util.GetSub(ctx)
-	if err != nil {
-		a.errorResponse(w, r, 401, err)
-		return
-	}
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
 
 	reqBody := createAccountRequest{}
 
@@ -131,11 +122,20 @@ func (a api) accountCreateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if reqBody.FamilyMemberID != nil {
+		if _, err := a.householdFamilyMember(ctx, *reqBody.FamilyMemberID, mem.HouseholdID); err != nil {
+			a.errorResponse(w, r, referenceStatus(err), err)
+			return
+		}
+	}
+
 	newAcc := domain.Account{
-		Name:      reqBody.Name,
-		Balance:   reqBody.Balance,
-		Note:      reqBody.Note,
-		CreatedBy: sub,
+		Name:           reqBody.Name,
+		Balance:        reqBody.Balance,
+		Note:           reqBody.Note,
+		CreatedBy:      mem.UserID,
+		HouseholdID:    mem.HouseholdID,
+		FamilyMemberID: reqBody.FamilyMemberID,
 	}
 
 	acc, err := a.accountRepo.Create(ctx, &newAcc)
@@ -150,6 +150,7 @@ func (a api) accountCreateHandler(w http.ResponseWriter, r *http.Request) {
 		a.errorResponse(w, r, 500, err)
 		return
 	}
+	setETag(w, acc)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(resJSON)
@@ -171,6 +172,7 @@ func (a api) accountGetHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, item)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(resJSON)
@@ -186,16 +188,32 @@ func (a api) accountUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	householdID, version := item.HouseholdID, item.Version
 	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
 		a.logger.Error("failed to parse request json", zap.Error(err))
 		a.errorResponse(w, r, 422, err)
 		return
 	}
+	item.HouseholdID = householdID
+	item.Version = version
+
+	if item.FamilyMemberID != nil {
+		if _, err := a.householdFamilyMember(ctx, *item.FamilyMemberID, householdID); err != nil {
+			a.errorResponse(w, r, referenceStatus(err), err)
+			return
+		}
+	}
 
 	acc, err := a.accountRepo.Update(ctx, &item)
 	if err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.accountRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
 		a.logger.Error("failed to update account", zap.Error(err))
 		a.errorResponse(w, r, 500, err)
+		return
 	}
 
 	resJSON, err := json.Marshal(acc)
@@ -204,6 +222,7 @@ func (a api) accountUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, acc)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(resJSON)
@@ -219,13 +238,19 @@ func (a api) accountDeleteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := a.accountRepo.Delete(ctx, int64(item.ID)); err != nil {
+	if err := a.accountRepo.Delete(ctx, int64(item.ID), item.Version); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.accountRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
 		a.logger.Error("failed to delete account", zap.Error(err))
 		status := 500
 		if err.Error() == domain.ErrNotFound.Error() {
 			status = 404
 		}
 		a.errorResponse(w, r, status, err)
+		return
 	}
 
 	data := map[string]string{
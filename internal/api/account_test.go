@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type fakeAccounts struct {
+	domain.AccountRepository
+	accounts []domain.Account
+}
+
+func (f *fakeAccounts) GetByID(ctx context.Context, id uint) (domain.Account, error) {
+	for _, acc := range f.accounts {
+		if acc.ID == id {
+			return acc, nil
+		}
+	}
+	return domain.Account{}, domain.ErrNotFound
+}
+
+func (f *fakeAccounts) Update(ctx context.Context, acc *domain.Account) (*domain.Account, error) {
+	for i, cur := range f.accounts {
+		if cur.ID != acc.ID {
+			continue
+		}
+		if cur.Version != acc.Version {
+			return nil, domain.ErrVersionConflict
+		}
+		acc.Version++
+		f.accounts[i] = *acc
+		return acc, nil
+	}
+	return nil, domain.ErrVersionConflict
+}
+
+// book moves an account's balance the way the transactions_balance trigger
+// does, bumping its version.
+func (f *fakeAccounts) book(id uint, amount float64) {
+	for i := range f.accounts {
+		if f.accounts[i].ID == id {
+			f.accounts[i].Balance += amount
+			f.accounts[i].Version++
+		}
+	}
+}
+
+type fakeMembers struct {
+	domain.HouseholdMemberRepository
+	members []domain.HouseholdMember
+}
+
+func (f *fakeMembers) GetByHouseholdUser(ctx context.Context, householdID uint, userID uint) (domain.HouseholdMember, error) {
+	for _, mem := range f.members {
+		if mem.HouseholdID == householdID && mem.UserID == userID {
+			return mem, nil
+		}
+	}
+	return domain.HouseholdMember{}, domain.ErrNotFound
+}
+
+// sessionToken signs in usr as a household owner of householdID.
+func sessionToken(t *testing.T, members *fakeMembers, usr domain.User, householdID uint) string {
+	t.Helper()
+
+	members.members = append(members.members, domain.HouseholdMember{
+		HouseholdID: householdID,
+		UserID:      usr.ID,
+		Role:        domain.HouseholdOwner,
+	})
+
+	token, err := usr.GenerateClaims()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestAccountUpdateAfterBookingIsStale(t *testing.T) {
+	accounts := &fakeAccounts{accounts: []domain.Account{{
+		Base:        domain.Base{ID: 1},
+		Versioned:   domain.Versioned{Version: 1},
+		Name:        "Cash",
+		HouseholdID: 1,
+		Balance:     100,
+	}}}
+	members := &fakeMembers{}
+
+	a := &api{
+		logger:              zap.NewNop(),
+		redis:               fakeRedis(t),
+		accountRepo:         accounts,
+		householdMemberRepo: members,
+	}
+	router := a.AccountRoutes()
+	token := sessionToken(t, members, domain.User{Base: domain.Base{ID: 1}}, 1)
+
+	req := httptest.NewRequest(http.MethodGet, "/1", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("get returned %d: %s", res.Code, res.Body)
+	}
+	tag := res.Header().Get("ETag")
+
+	// A transaction is booked against the account after the client read it.
+	accounts.book(1, -30)
+
+	req = httptest.NewRequest(http.MethodPut, "/1", strings.NewReader(`{"name":"Wallet","balance":100}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", tag)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	if res.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale update returned %d, want 412: %s", res.Code, res.Body)
+	}
+
+	var current domain.Account
+	if err := json.Unmarshal(res.Body.Bytes(), &current); err != nil {
+		t.Fatal(err)
+	}
+	if current.Balance != 70 {
+		t.Fatalf("412 carried balance %v, want 70", current.Balance)
+	}
+	if stored, _ := accounts.GetByID(context.Background(), 1); stored.Balance != 70 || stored.Name != "Cash" {
+		t.Fatalf("stale update was saved: %+v", stored)
+	}
+}
@@ -0,0 +1,485 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/middlewares"
+	"github.com/Brix101/budgetto-backend/internal/util"
+)
+
+type AdminUserCtx struct{}
+type AdminCatCtx struct{}
+
+var errSelfDeactivate = errors.New("You can't deactivate or demote your own account.")
+
+func (a api) AdminRoutes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middlewares.Auth)
+	r.Use(a.Idempotency)
+
+	r.Route("/users", func(r chi.Router) {
+		// Permissions are checked before AdminUserCtx loads the user, so
+		// callers without them can't probe which ids exist.
+		r.Use(middlewares.Require(domain.PermUsersRead))
+
+		r.Get("/", a.adminUserListHandler)
+
+		r.Route("/{id}", func(r chi.Router) {
+			r.With(a.AdminUserCtx).Get("/", a.adminUserGetHandler)
+			r.With(middlewares.Require(domain.PermUsersWrite), a.AdminUserCtx, a.IfMatch(AdminUserCtx{})).Put("/active", a.adminUserActiveHandler)
+			r.With(middlewares.Require(domain.PermUsersWrite), a.AdminUserCtx, a.IfMatch(AdminUserCtx{})).Put("/role", a.adminUserRoleHandler)
+		})
+	})
+
+	r.Route("/categories", func(r chi.Router) {
+		r.Use(middlewares.Require(domain.PermGlobalCategories))
+
+		r.Get("/", a.adminCategoryListHandler)
+		r.Post("/", a.adminCategoryCreateHandler)
+
+		r.Route("/{id}", func(r chi.Router) {
+			r.Use(a.AdminCategoryCtx)
+
+			r.With(a.IfMatch(AdminCatCtx{})).Put("/", a.adminCategoryUpdateHandler)
+			r.With(a.IfMatch(AdminCatCtx{})).Delete("/", a.adminCategoryDeleteHandler)
+			r.Post("/merge", a.adminCategoryMergeHandler)
+		})
+	})
+
+	r.With(middlewares.Require(domain.PermAuditVerify)).Get("/audit/verify", a.adminAuditVerifyHandler)
+
+	return r
+}
+
+func (a api) AdminUserCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+
+		item, err := a.userRepo.GetByID(ctx, uint(id))
+		if err != nil {
+			status := 500
+			if err.Error() == domain.ErrNotFound.Error() {
+				status = 404
+			}
+			a.errorResponse(w, r, status, err)
+			return
+		}
+
+		ctx = context.WithValue(ctx, AdminUserCtx{}, item)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (a api) AdminCategoryCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+
+		item, err := a.categoryRepo.GetByID(ctx, uint(id))
+		if err != nil {
+			status := 500
+			if err.Error() == domain.ErrNotFound.Error() {
+				status = 404
+			}
+			a.errorResponse(w, r, status, err)
+			return
+		}
+
+		// The admin API only manages global categories.
+		if item.HouseholdID != nil {
+			a.errorResponse(w, r, 404, domain.ErrNotFound)
+			return
+		}
+
+		ctx = context.WithValue(ctx, AdminCatCtx{}, item)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type adminUserActiveRequest struct {
+	IsActive *bool `json:"is_active" validate:"required"`
+}
+
+type adminUserRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=user admin support"`
+}
+
+func (a api) adminUserListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	offset, _ := strconv.Atoi(query.Get("offset"))
+
+	usrs, err := a.userRepo.List(ctx, domain.UserFilter{
+		Query:  query.Get("q"),
+		Role:   query.Get("role"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		a.logger.Error("failed to fetch users from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(usrs)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) adminUserGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(AdminUserCtx{}).(domain.User)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	resJSON, err := json.Marshal(item)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, item)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) adminUserActiveHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(AdminUserCtx{}).(domain.User)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	reqBody := adminUserActiveRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	sub, err := util.GetSub(ctx)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	if item.ID == sub && !*reqBody.IsActive {
+		a.errorResponse(w, r, 400, errSelfDeactivate)
+		return
+	}
+
+	if err := a.userRepo.SetActive(ctx, item.ID, item.Version, *reqBody.IsActive); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.userRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to set user active", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	if !*reqBody.IsActive {
+		if err := a.revokeCredentials(ctx, item.ID); err != nil {
+			a.logger.Error("failed to revoke user credentials", zap.Error(err))
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+	}
+
+	usr, err := a.userRepo.GetByID(ctx, item.ID)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(usr)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, usr)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) adminUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(AdminUserCtx{}).(domain.User)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	reqBody := adminUserRoleRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	sub, err := util.GetSub(ctx)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	if item.ID == sub && reqBody.Role != domain.RoleAdmin {
+		a.errorResponse(w, r, 400, errSelfDeactivate)
+		return
+	}
+
+	if err := a.userRepo.SetRole(ctx, item.ID, item.Version, reqBody.Role); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.userRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to set user role", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	usr, err := a.userRepo.GetByID(ctx, item.ID)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(usr)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, usr)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) adminCategoryListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	cats, err := a.categoryRepo.GetGlobal(ctx)
+	if err != nil {
+		a.logger.Error("failed to fetch global categories from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(cats)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) adminCategoryCreateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	reqBody := createCategoryRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	newCat := domain.Category{
+		Name:     reqBody.Name,
+		Note:     reqBody.Note,
+		ParentID: reqBody.ParentID,
+	}
+
+	if status, err := a.checkCategoryParent(ctx, newCat, newCat.ParentID); err != nil {
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	cat, err := a.categoryRepo.Create(ctx, &newCat)
+	if err != nil {
+		a.logger.Error("failed to create global category", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(cat)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, cat)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) adminCategoryUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(AdminCatCtx{}).(domain.Category)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	reqBody := createCategoryRequest{Name: item.Name, Note: item.Note, ParentID: item.ParentID}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	if status, err := a.checkCategoryParent(ctx, item, reqBody.ParentID); err != nil {
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	item.Name = reqBody.Name
+	item.Note = reqBody.Note
+	item.ParentID = reqBody.ParentID
+
+	cat, err := a.categoryRepo.Update(ctx, &item)
+	if err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.categoryRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		if err.Error() == domain.ErrCategoryCycle.Error() {
+			a.errorResponse(w, r, 400, err)
+			return
+		}
+		a.logger.Error("failed to update global category", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(cat)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, cat)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) adminCategoryDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(AdminCatCtx{}).(domain.Category)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	if status, err := a.deleteCategory(ctx, item, r.URL.Query().Get("replacement_id")); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.categoryRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	data := map[string]string{
+		"message": "Item deleted successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func (a api) adminCategoryMergeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(AdminCatCtx{}).(domain.Category)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	r = r.WithContext(context.WithValue(ctx, CatCtx{}, item))
+	a.categoryMergeHandler(w, r)
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/middlewares"
+)
+
+func (f *fakeUsers) List(ctx context.Context, filter domain.UserFilter) ([]domain.User, error) {
+	return f.users, nil
+}
+
+func (f *fakeUsers) set(id uint, version int, change func(usr *domain.User)) error {
+	for i := range f.users {
+		if f.users[i].ID == id && f.users[i].Version == version {
+			change(&f.users[i])
+			f.users[i].Version++
+			return nil
+		}
+	}
+	return domain.ErrVersionConflict
+}
+
+func (f *fakeUsers) SetActive(ctx context.Context, id uint, version int, active bool) error {
+	return f.set(id, version, func(usr *domain.User) { usr.IsActive = active })
+}
+
+func (f *fakeUsers) SetRole(ctx context.Context, id uint, version int, role string) error {
+	return f.set(id, version, func(usr *domain.User) { usr.Role = role })
+}
+
+// newAdminTest serves the admin routes with session tokens checked against
+// users.
+func newAdminTest(t *testing.T, users *fakeUsers) http.Handler {
+	t.Helper()
+
+	a := &api{
+		logger:   zap.NewNop(),
+		redis:    fakeRedis(t),
+		userRepo: users,
+	}
+
+	middlewares.RegisterSessionCheck(a.checkSession)
+	t.Cleanup(func() { middlewares.RegisterSessionCheck(nil) })
+
+	return a.AdminRoutes()
+}
+
+func adminRequest(router http.Handler, method string, path string, token string) *httptest.ResponseRecorder {
+	return adminWrite(router, method, path, token, "", "")
+}
+
+func adminWrite(router http.Handler, method string, path string, token string, ifMatch string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+func TestAdminDeactivatedAdminIsRefused(t *testing.T) {
+	users := &fakeUsers{}
+	admin := domain.User{Name: "Ada", Email: "ada@example.com", Role: domain.RoleAdmin}
+	users.Create(context.Background(), &admin)
+	router := newAdminTest(t, users)
+
+	token, err := admin.GenerateClaims()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res := adminRequest(router, http.MethodGet, "/users/", token); res.Code != http.StatusOK {
+		t.Fatalf("active admin got %d: %s", res.Code, res.Body)
+	}
+
+	// The token still says admin, but the account no longer is active.
+	users.users[0].IsActive = false
+	if res := adminRequest(router, http.MethodGet, "/users/", token); res.Code != http.StatusUnauthorized {
+		t.Fatalf("deactivated admin got %d, want 401: %s", res.Code, res.Body)
+	}
+}
+
+func TestAdminDemotedAdminIsRefused(t *testing.T) {
+	users := &fakeUsers{}
+	admin := domain.User{Name: "Ada", Email: "ada@example.com", Role: domain.RoleAdmin}
+	users.Create(context.Background(), &admin)
+	router := newAdminTest(t, users)
+
+	token, err := admin.GenerateClaims()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users.users[0].Role = domain.RoleUser
+	if res := adminRequest(router, http.MethodGet, "/users/", token); res.Code != http.StatusForbidden {
+		t.Fatalf("demoted admin got %d, want 403: %s", res.Code, res.Body)
+	}
+}
+
+func TestAdminUserChangesRequireIfMatch(t *testing.T) {
+	users := &fakeUsers{}
+	admin := domain.User{Name: "Ada", Email: "ada@example.com", Role: domain.RoleAdmin}
+	users.Create(context.Background(), &admin)
+	member := domain.User{Name: "Bob", Email: "bob@example.com", Role: domain.RoleUser}
+	users.Create(context.Background(), &member)
+	users.users[1].IsActive = false
+	router := newAdminTest(t, users)
+
+	token, err := admin.GenerateClaims()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, change := range []struct{ path, body string }{
+		{"/users/2/role", `{"role":"support"}`},
+		{"/users/2/active", `{"is_active":true}`},
+	} {
+		if res := adminWrite(router, http.MethodPut, change.path, token, "", change.body); res.Code != http.StatusPreconditionRequired {
+			t.Fatalf("%s without If-Match returned %d, want 428: %s", change.path, res.Code, res.Body)
+		}
+
+		get := adminRequest(router, http.MethodGet, "/users/2/", token)
+		if get.Code != http.StatusOK {
+			t.Fatalf("get returned %d: %s", get.Code, get.Body)
+		}
+		tag := get.Header().Get("ETag")
+
+		if res := adminWrite(router, http.MethodPut, change.path, token, etag(99), change.body); res.Code != http.StatusPreconditionFailed {
+			t.Fatalf("%s with a stale If-Match returned %d, want 412: %s", change.path, res.Code, res.Body)
+		}
+
+		res := adminWrite(router, http.MethodPut, change.path, token, tag, change.body)
+		if res.Code != http.StatusOK {
+			t.Fatalf("%s returned %d: %s", change.path, res.Code, res.Body)
+		}
+		if res.Header().Get("ETag") == tag {
+			t.Fatalf("%s kept ETag %s", change.path, tag)
+		}
+	}
+
+	if usr := users.users[1]; usr.Role != domain.RoleSupport || !usr.IsActive {
+		t.Fatalf("changes not saved: %+v", usr)
+	}
+}
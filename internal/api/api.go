@@ -12,7 +12,11 @@ import (
 	"github.com/redis/go-redis/v9"
 
 	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/middlewares"
+	"github.com/Brix101/budgetto-backend/internal/oidc"
 	"github.com/Brix101/budgetto-backend/internal/repository"
+	"github.com/Brix101/budgetto-backend/internal/throttle"
+	"github.com/Brix101/budgetto-backend/internal/util"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/swaggo/http-swagger/example/go-chi/docs"
@@ -23,33 +27,112 @@ import (
 type api struct {
 	logger     *zap.Logger
 	httpClient *http.Client
+	redis      *redis.Client
+	mailer     domain.Mailer
+
+	oidcProviders map[string]*oidc.Provider
+	signInLimiter *throttle.Limiter
+	shareLimiter  *throttle.Limiter
 
 	categoryRepo    domain.CategoryRepository
 	accountRepo     domain.AccountRepository
 	budgetRepo      domain.BudgetRepository
 	transactionRepo domain.TransactionRepository
 	userRepo        domain.UserRepository
+	tokenRepo       domain.PersonalAccessTokenRepository
+	identityRepo    domain.UserIdentityRepository
+	householdRepo   domain.HouseholdRepository
+
+	signInAttemptRepo     domain.SignInAttemptRepository
+	emailVerificationRepo domain.EmailVerificationRepository
+	householdMemberRepo   domain.HouseholdMemberRepository
+	shareLinkRepo         domain.ShareLinkRepository
+	shareAccessRepo       domain.ShareAccessRepository
+	familyMemberRepo      domain.FamilyMemberRepository
+	contactRepo           domain.ContactRepository
+	splitRepo             domain.SplitRepository
+	settlementRepo        domain.SettlementRepository
+	tagRepo               domain.TagRepository
+	payeeRepo             domain.PayeeRepository
+	ruleRepo              domain.RuleRepository
+	categoryOverrideRepo  domain.CategoryOverrideRepository
+	trashRepo             domain.TrashRepository
+	auditRepo             domain.AuditRepository
+	syncRepo              domain.SyncRepository
 }
 
-func NewAPI(_ context.Context, logger *zap.Logger, _ *redis.Client, pool *pgxpool.Pool) *api {
-	categoryRepo := repository.NewPostgresCategory(pool)
-	accountRepo := repository.NewPostgresAccount(pool)
-	budgetRepo := repository.NewPostgresBudget(pool)
-	transctionRepo := repository.NewPostgresTransaction(pool)
-	userRepo := repository.NewPostgresUser(pool)
+func NewAPI(_ context.Context, logger *zap.Logger, rdb *redis.Client, pool *pgxpool.Pool) *api {
+	// Writes go through conn so the audit log knows who made them.
+	conn := repository.NewAuditedConnection(pool, auditInfo)
+
+	categoryRepo := repository.NewPostgresCategory(conn)
+	accountRepo := repository.NewPostgresAccount(conn)
+	budgetRepo := repository.NewPostgresBudget(conn)
+	transctionRepo := repository.NewPostgresTransaction(conn)
+	userRepo := repository.NewPostgresUser(conn)
+	tokenRepo := repository.NewPostgresToken(conn)
+	identityRepo := repository.NewPostgresIdentity(conn)
+	signInAttemptRepo := repository.NewPostgresSignInAttempt(conn)
+	emailVerificationRepo := repository.NewPostgresEmailVerification(conn)
+	householdRepo := repository.NewPostgresHousehold(conn)
+	householdMemberRepo := repository.NewPostgresHouseholdMember(conn)
+	shareLinkRepo := repository.NewPostgresShareLink(conn)
+	shareAccessRepo := repository.NewPostgresShareAccess(conn)
+	familyMemberRepo := repository.NewPostgresFamilyMember(conn)
+	contactRepo := repository.NewPostgresContact(conn)
+	splitRepo := repository.NewPostgresSplit(conn)
+	settlementRepo := repository.NewPostgresSettlement(conn)
+	tagRepo := repository.NewPostgresTag(conn)
+	payeeRepo := repository.NewPostgresPayee(conn)
+	ruleRepo := repository.NewPostgresRule(conn)
+	categoryOverrideRepo := repository.NewPostgresCategoryOverride(conn)
+	trashRepo := repository.NewPostgresTrash(conn)
+	auditRepo := repository.NewPostgresAudit(conn)
+	syncRepo := repository.NewPostgresSync(conn)
 
 	client := &http.Client{}
 
-	return &api{
+	a := &api{
 		logger:     logger,
 		httpClient: client,
+		redis:      rdb,
+		mailer:     util.NewLogMailer(logger),
+
+		oidcProviders: oidc.ProvidersFromEnv(client),
+		signInLimiter: throttle.NewLimiter(rdb, throttle.ConfigFromEnv()),
+		shareLimiter:  throttle.NewLimiter(rdb, throttle.PasscodeConfigFromEnv()),
 
 		categoryRepo:    categoryRepo,
 		accountRepo:     accountRepo,
 		budgetRepo:      budgetRepo,
 		transactionRepo: transctionRepo,
 		userRepo:        userRepo,
+		tokenRepo:       tokenRepo,
+		identityRepo:    identityRepo,
+		householdRepo:   householdRepo,
+
+		signInAttemptRepo:     signInAttemptRepo,
+		emailVerificationRepo: emailVerificationRepo,
+		householdMemberRepo:   householdMemberRepo,
+		shareLinkRepo:         shareLinkRepo,
+		shareAccessRepo:       shareAccessRepo,
+		familyMemberRepo:      familyMemberRepo,
+		contactRepo:           contactRepo,
+		splitRepo:             splitRepo,
+		settlementRepo:        settlementRepo,
+		tagRepo:               tagRepo,
+		payeeRepo:             payeeRepo,
+		ruleRepo:              ruleRepo,
+		categoryOverrideRepo:  categoryOverrideRepo,
+		trashRepo:             trashRepo,
+		auditRepo:             auditRepo,
+		syncRepo:              syncRepo,
 	}
+
+	middlewares.RegisterTokenVerifier(domain.TokenPrefix, a.verifyPersonalAccessToken)
+	middlewares.RegisterSessionCheck(a.checkSession)
+
+	return a
 }
 
 func (a *api) Server(port int) *http.Server {
@@ -78,7 +161,9 @@ func (a *api) Routes() *chi.Mux {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(middlewares.RealIP(middlewares.TrustedProxiesFromEnv()))
+	r.Use(a.ClientIPCtx)
+	r.Use(redactShareToken)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(telemetry.Collector(telemetry.Config{AllowAny: true}, []string{"/api"}))
@@ -86,7 +171,8 @@ func (a *api) Routes() *chi.Mux {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:5173", "https://budgetto.vercel.app", "https://budgetto.brixterporras.com"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Link", "If-Match", IdempotencyKeyHeader, HouseholdHeader, SharePasscodeHeader},
+		ExposedHeaders:   []string{"Retry-After", "ETag", "Idempotent-Replayed"},
 		AllowCredentials: false,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
@@ -95,6 +181,10 @@ func (a *api) Routes() *chi.Mux {
 		httpSwagger.URL("http://localhost:5000/swagger/doc.json"), // The url pointing to API definition
 	))
 
+	r.Get("/.well-known/jwks.json", a.jwksHandler)
+
+	// Idempotency runs inside each router, after Auth, so keys are kept per
+	// user.
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Mount("/health", a.HealthRoutes())
 		r.Mount("/categories", a.CategoryRoutes())
@@ -103,6 +193,20 @@ func (a *api) Routes() *chi.Mux {
 		r.Mount("/transactions", a.TransactionRoutes())
 		r.Mount("/auth", a.AuthRoutes())
 		r.Mount("/users", a.UserRoutes())
+		r.Mount("/tokens", a.TokenRoutes())
+		r.Mount("/admin", a.AdminRoutes())
+		r.Mount("/households", a.HouseholdRoutes())
+		r.Mount("/shares", a.ShareRoutes())
+		r.Mount("/shared", a.SharedRoutes())
+		r.Mount("/family-members", a.FamilyMemberRoutes())
+		r.Mount("/contacts", a.ContactRoutes())
+		r.Mount("/splits", a.SplitRoutes())
+		r.Mount("/tags", a.TagRoutes())
+		r.Mount("/payees", a.PayeeRoutes())
+		r.Mount("/rules", a.RuleRoutes())
+		r.Mount("/trash", a.TrashRoutes())
+		r.Mount("/audit", a.AuditRoutes())
+		r.Mount("/sync", a.SyncRoutes())
 	})
 
 	return r
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/middlewares"
+)
+
+type ClientIPCtx struct{}
+
+var (
+	errAuditDate  = errors.New("from and to must be dates (YYYY-MM-DD).")
+	errAuditLimit = errors.New("limit must be a positive number.")
+)
+
+func (a api) AuditRoutes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middlewares.Auth)
+	r.Use(middlewares.Scope("audit"))
+	r.Use(a.MemberCtx)
+
+	r.Get("/", a.auditListHandler)
+
+	return r
+}
+
+// ClientIPCtx keeps the caller's address for the audit log.
+func (a api) ClientIPCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), ClientIPCtx{}, clientIP(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// auditInfo describes the request behind a change for the audit log.
+func auditInfo(ctx context.Context) domain.AuditInfo {
+	info := domain.AuditInfo{RequestID: middleware.GetReqID(ctx)}
+	info.IP, _ = ctx.Value(ClientIPCtx{}).(string)
+
+	if claims, ok := ctx.Value(middlewares.AuthCtx{}).(jwt.MapClaims); ok {
+		if sub, err := claims.GetSubject(); err == nil {
+			if id, err := strconv.Atoi(sub); err == nil {
+				actorID := uint(id)
+				info.ActorID = &actorID
+			}
+		}
+	}
+
+	return info
+}
+
+// auditListHandler lists the household's audit events, newest first,
+// filtered by ?entity_type, ?entity_id and a ?from / ?to date range.
+func (a api) auditListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	query := r.URL.Query()
+	filter := domain.AuditFilter{EntityType: query.Get("entity_type"), Limit: 100}
+	if v := query.Get("entity_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			a.errorResponse(w, r, 400, err)
+			return
+		}
+		entityID := uint(id)
+		filter.EntityID = &entityID
+	}
+	if v := query.Get("from"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			a.errorResponse(w, r, 400, errAuditDate)
+			return
+		}
+		filter.From = &from
+	}
+	if v := query.Get("to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			a.errorResponse(w, r, 400, errAuditDate)
+			return
+		}
+		to = to.AddDate(0, 0, 1)
+		filter.To = &to
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			a.errorResponse(w, r, 400, errAuditLimit)
+			return
+		}
+		filter.Limit = min(limit, 500)
+	}
+
+	events, err := a.auditRepo.GetByHouseholdID(ctx, mem.HouseholdID, filter)
+	if err != nil {
+		a.logger.Error("failed to fetch audit events from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(events)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+// adminAuditVerifyHandler checks the audit log's hash chain for tampering.
+func (a api) adminAuditVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	res, err := a.auditRepo.Verify(ctx)
+	if err != nil {
+		a.logger.Error("failed to verify audit events", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(res)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
@@ -2,11 +2,13 @@ package api
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"math"
+	"net"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -15,6 +17,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/keys"
 	"github.com/Brix101/budgetto-backend/internal/middlewares"
 	"github.com/Brix101/budgetto-backend/internal/util"
 )
@@ -26,9 +29,19 @@ func (a api) AuthRoutes() chi.Router {
 	r.Post("/sign-in", a.signInHandler)
 	r.Post("/sign-up", a.signUpHandler)
 
+	r.Get("/oidc", a.oidcProviderListHandler)
+	r.Get("/oidc/{provider}/login", a.oidcLoginHandler)
+	r.Get("/oidc/{provider}/callback", a.oidcCallbackHandler)
+
 	r.Group(func(r chi.Router) {
 		r.Use(middlewares.Auth)
+		// "auth" is never a grantable scope, so a personal access token can't
+		// link an identity and sign in through it as the full account.
+		r.Use(middlewares.Scope("auth"))
+		r.Use(a.Idempotency)
 		r.Get("/me", a.meHandler)
+		r.Get("/identities", a.identityListHandler)
+		r.Post("/identities/{provider}", a.identityLinkHandler)
 	})
 
 	return r
@@ -61,19 +74,126 @@ func (a api) signInHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := clientIP(r)
+
+	wait, locked, err := a.signInLimiter.Check(ctx, ip, reqBody.Email)
+	if err != nil {
+		a.logger.Error("failed to check sign in throttle", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+	if wait > 0 {
+		reason, reasonErr := domain.SignInThrottled, domain.ErrTooManyAttempts
+		if locked {
+			reason, reasonErr = domain.SignInLocked, domain.ErrAccountLocked
+		}
+		a.recordSignInFailure(ctx, r, reqBody.Email, reason, nil)
+		a.tooManyRequests(w, r, wait, reasonErr)
+		return
+	}
+
 	usr, err := a.userRepo.GetByEmail(ctx, reqBody.Email)
 	if err != nil {
-		a.errorResponse(w, r, 401, domain.ErrInvalidCredentials)
+		// Hash anyway so an unknown email takes as long as a wrong password.
+		dummyUser().CheckPassword(reqBody.Password)
+		a.recordSignInFailure(ctx, r, reqBody.Email, domain.SignInUnknownEmail, nil)
+		a.signInFailed(w, r, ip, reqBody.Email)
 		return
 	}
 
 	if validatePass := usr.CheckPassword(reqBody.Password); !validatePass {
-		a.errorResponse(w, r, 401, domain.ErrInvalidCredentials)
+		a.recordSignInFailure(ctx, r, reqBody.Email, domain.SignInBadPassword, &usr.ID)
+		a.signInFailed(w, r, ip, reqBody.Email)
 		return
 	}
 
-	token, err := usr.GenerateRefreshToken()
+	if err := a.signInLimiter.Reset(ctx, reqBody.Email); err != nil {
+		a.logger.Error("failed to reset sign in throttle", zap.Error(err))
+	}
+
+	if usr.PasswordNeedsRehash() {
+		a.rehashPassword(ctx, usr, reqBody.Password)
+	}
+
+	a.sessionResponse(w, r, usr)
+}
+
+// dummyUser holds a password hashed with the current hasher, for sign ins
+// with an unknown email to check against.
+var dummyUser = sync.OnceValue(func() domain.User {
+	usr := domain.User{Password: "budgetto-dummy-password"}
+	_ = usr.HashPassword()
+	return usr
+})
+
+// rehashPassword upgrades a stored hash to the current hasher. Failures are
+// only logged; the old hash keeps working.
+func (a api) rehashPassword(ctx context.Context, usr domain.User, plain string) {
+	usr.Password = plain
+	if err := usr.HashPassword(); err != nil {
+		a.logger.Error("failed to rehash password", zap.Error(err))
+		return
+	}
+
+	if _, err := a.userRepo.Update(ctx, &usr); err != nil {
+		a.logger.Error("failed to store rehashed password", zap.Error(err))
+	}
+}
+
+// signInFailed counts a failed attempt against the throttle and answers with
+// 401, or 429 once the attempt pushed the caller into backoff or lockout.
+func (a api) signInFailed(w http.ResponseWriter, r *http.Request, ip string, email string) {
+	wait, locked, err := a.signInLimiter.Fail(r.Context(), ip, email)
 	if err != nil {
+		a.logger.Error("failed to record sign in failure", zap.Error(err))
+	}
+
+	switch {
+	case locked:
+		a.tooManyRequests(w, r, wait, domain.ErrAccountLocked)
+	case wait > 0:
+		a.tooManyRequests(w, r, wait, domain.ErrTooManyAttempts)
+	default:
+		a.errorResponse(w, r, 401, domain.ErrInvalidCredentials)
+	}
+}
+
+func (a api) recordSignInFailure(ctx context.Context, r *http.Request, email string, reason string, userID *uint) {
+	if _, err := a.signInAttemptRepo.Create(ctx, &domain.SignInAttempt{
+		Email:     strings.ToLower(strings.TrimSpace(email)),
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+		Reason:    reason,
+		UserID:    userID,
+	}); err != nil {
+		a.logger.Error("failed to record sign in attempt", zap.Error(err))
+	}
+}
+
+func (a api) tooManyRequests(w http.ResponseWriter, r *http.Request, wait time.Duration, err error) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	a.errorResponse(w, r, http.StatusTooManyRequests, err)
+}
+
+// clientIP returns the caller's address without the port. middleware.RealIP
+// has already replaced RemoteAddr with any forwarded address.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// sessionResponse sets the refresh token cookie and writes the user with a
+// fresh access token, completing any successful sign-in.
+func (a api) sessionResponse(w http.ResponseWriter, r *http.Request, usr domain.User) {
+	if !usr.IsActive {
+		a.errorResponse(w, r, 403, domain.ErrAccountDisabled)
+		return
+	}
+
+	if err := setRefreshCookie(w, usr); err != nil {
 		a.logger.Error("failed to generate user claims", zap.Error(err))
 		a.errorResponse(w, r, 500, err)
 		return
@@ -92,6 +212,18 @@ func (a api) signInHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+// setRefreshCookie starts a new refresh session for usr.
+func setRefreshCookie(w http.ResponseWriter, usr domain.User) error {
+	token, err := usr.GenerateRefreshToken()
+	if err != nil {
+		return err
+	}
+
 	// Create and set cookies in the response
 	cookie := http.Cookie{
 		Name:     middlewares.BudgetttoCookieKey, // Cookie name
@@ -103,10 +235,16 @@ func (a api) signInHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	http.SetCookie(w, &cookie)
+	return nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(resJSON)
+// revokeCredentials signs usr out of every session and revokes their
+// personal access tokens.
+func (a api) revokeCredentials(ctx context.Context, userID uint) error {
+	if err := a.userRepo.RevokeSessions(ctx, userID); err != nil {
+		return err
+	}
+	return a.tokenRepo.RevokeByUserSUB(ctx, userID)
 }
 
 func (a api) signUpHandler(w http.ResponseWriter, r *http.Request) {
@@ -143,6 +281,12 @@ func (a api) signUpHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := a.createPersonalHousehold(ctx, usr); err != nil {
+		a.logger.Error("failed to create personal household", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
 	resJSON, err := json.Marshal(usr)
 	if err != nil {
 		a.errorResponse(w, r, 500, err)
@@ -164,23 +308,13 @@ func (a api) refreshHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	publicKey := os.Getenv("REFRESH_PUBLIC_KEY")
-	keyData, err := base64.StdEncoding.DecodeString(publicKey)
+	manager, err := keys.Refresh()
 	if err != nil {
-		a.errorResponse(w, r, 401, err)
-		return
-	}
-
-	parsedKey, err := jwt.ParseRSAPublicKeyFromPEM([]byte(keyData))
-	if err != nil {
-		a.errorResponse(w, r, 401, err)
+		a.errorResponse(w, r, 500, err)
 		return
 	}
 
-	token, err := jwt.Parse(cookie.Value, func(token *jwt.Token) (interface{}, error) {
-		return parsedKey, nil
-	})
-
+	token, err := manager.Parse(cookie.Value, &jwt.RegisteredClaims{})
 	if err != nil || !token.Valid {
 		a.errorResponse(w, r, 401, err)
 		return
@@ -204,6 +338,21 @@ func (a api) refreshHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !usr.IsActive {
+		a.errorResponse(w, r, 403, domain.ErrAccountDisabled)
+		return
+	}
+
+	issuedAt, err := token.Claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		a.errorResponse(w, r, 401, domain.ErrInvalidToken)
+		return
+	}
+	if usr.SessionsRevokedAt != nil && issuedAt.Before(*usr.SessionsRevokedAt) {
+		a.errorResponse(w, r, 401, domain.ErrInvalidToken)
+		return
+	}
+
 	data, err := usr.GenerateUserWithToken()
 	if err != nil {
 		a.errorResponse(w, r, 500, err)
@@ -221,6 +370,42 @@ func (a api) refreshHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(resJSON)
 }
 
+// checkSession runs on every session access token. The token's role and
+// status are only as fresh as the sign in, so the user is loaded again:
+// deactivated users and tokens issued before a revocation are refused, and
+// the role claim is replaced with the current one.
+func (a api) checkSession(ctx context.Context, claims jwt.MapClaims) (jwt.MapClaims, error) {
+	sub, err := claims.GetSubject()
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := strconv.Atoi(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	usr, err := a.userRepo.GetByID(ctx, uint(userId))
+	if err != nil {
+		return nil, err
+	}
+
+	if !usr.IsActive {
+		return nil, domain.ErrAccountDisabled
+	}
+
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return nil, domain.ErrInvalidToken
+	}
+	if usr.SessionsRevokedAt != nil && issuedAt.Before(*usr.SessionsRevokedAt) {
+		return nil, domain.ErrInvalidToken
+	}
+
+	claims["role"] = usr.Role
+	return claims, nil
+}
+
 func (a api) meHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
@@ -12,7 +12,6 @@ import (
 
 	"github.com/Brix101/budgetto-backend/internal/domain"
 	"github.com/Brix101/budgetto-backend/internal/middlewares"
-	"github.com/Brix101/budgetto-backend/internal/util"
 )
 
 type BudgetCtx struct{}
@@ -21,16 +20,22 @@ func (a api) BudgetRoutes() chi.Router {
 	r := chi.NewRouter()
 
 	r.Use(middlewares.Auth)
+	r.Use(middlewares.Scope("budgets"))
+	r.Use(a.Idempotency)
 
-	r.Get("/", a.budgetListHandler)
-	r.Post("/", a.budgetCreateHandler)
+	r.Group(func(r chi.Router) {
+		r.Use(a.MemberCtx)
+
+		r.Get("/", a.budgetListHandler)
+		r.Post("/", a.budgetCreateHandler)
+	})
 
 	r.Route("/{id}", func(r chi.Router) {
 		r.Use(a.BudgetCtx)
 
-		r.Get("/{id}", a.budgetGetHandler)
-		r.Put("/{id}", a.budgetUpdateHandler)
-		r.Delete("/{id}", a.budgetDeleteHandler)
+		r.Get("/", a.budgetGetHandler)
+		r.With(a.IfMatch(BudgetCtx{})).Put("/", a.budgetUpdateHandler)
+		r.With(a.IfMatch(BudgetCtx{})).Delete("/", a.budgetDeleteHandler)
 	})
 
 	return r
@@ -40,12 +45,6 @@ func (a api) BudgetCtx(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
-		sub, err := util.GetSub(ctx)
-		if err != nil {
-			a.errorResponse(w, r, 500, err)
-			return
-		}
-
 		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
 			a.errorResponse(w, r, 500, err)
@@ -62,12 +61,11 @@ func (a api) BudgetCtx(next http.Handler) http.Handler {
 			return
 		}
 
-		if item.CreatedBy != sub {
-			a.errorResponse(w, r, 403, domain.ErrForbidden)
+		if _, ok := a.authorizeMember(w, r, item.HouseholdID); !ok {
 			return
 		}
 
-		ctx = context.WithValue(ctx, AccountCtx{}, item)
+		ctx = context.WithValue(ctx, BudgetCtx{}, item)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -81,14 +79,9 @@ func (a api) budgetListHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
-	user := r.Context().Value(middlewares.AuthCtx{}).(*domain.UserClaims)
-	sub, err := user.GetSubject()
-	if err != nil {
-		a.errorResponse(w, r, 500, err)
-		return
-	}
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
 
-	buds, err := a.budgetRepo.GetByUserSUB(ctx, sub)
+	buds, err := a.budgetRepo.GetByHouseholdID(ctx, mem.HouseholdID)
 	if err != nil {
 		a.logger.Error("failed to fetch budgets from database", zap.Error(err))
 		a.errorResponse(w, r, 500, err)
@@ -110,11 +103,7 @@ func (a api) budgetCreateHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
-	sub, err := util.GetSub(ctx)
-	if err != nil {
-		a.errorResponse(w, r, 500, err)
-		return
-	}
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
 
 	reqBody := createBudgetRequest{}
 
@@ -131,10 +120,16 @@ func (a api) budgetCreateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, err := a.householdCategory(ctx, reqBody.CategoryID, mem.HouseholdID); err != nil {
+		a.errorResponse(w, r, referenceStatus(err), err)
+		return
+	}
+
 	budReq := domain.Budget{
-		Amount:     reqBody.Amount,
-		CategoryID: reqBody.CategoryID,
-		CreatedBy:  sub,
+		Amount:      reqBody.Amount,
+		CategoryID:  reqBody.CategoryID,
+		CreatedBy:   mem.UserID,
+		HouseholdID: mem.HouseholdID,
 	}
 
 	newBud, err := a.budgetRepo.Create(ctx, &budReq)
@@ -155,6 +150,7 @@ func (a api) budgetCreateHandler(w http.ResponseWriter, r *http.Request) {
 		a.errorResponse(w, r, 500, err)
 		return
 	}
+	setETag(w, bud)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(resJSON)
@@ -176,6 +172,7 @@ func (a api) budgetGetHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, bud)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(resJSON)
@@ -189,6 +186,11 @@ func (a api) budgetUpdateHandler(w http.ResponseWriter, r *http.Request) {
 
 	upBud, err := a.budgetRepo.Update(ctx, &bud)
 	if err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.budgetRepo.GetByID(ctx, bud.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
 		a.logger.Error("failed to update budget", zap.Error(err))
 		a.errorResponse(w, r, 500, err)
 		return
@@ -212,6 +214,7 @@ func (a api) budgetUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, upBud)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(resJSON)
@@ -227,13 +230,19 @@ func (a api) budgetDeleteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := a.budgetRepo.Delete(ctx, bud.ID); err != nil {
+	if err := a.budgetRepo.Delete(ctx, bud.ID, bud.Version); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.budgetRepo.GetByID(ctx, bud.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
 		a.logger.Error("failed to delete budget", zap.Error(err))
 		status := 500
 		if err.Error() == domain.ErrNotFound.Error() {
 			status = 404
 		}
 		a.errorResponse(w, r, status, err)
+		return
 	}
 
 	data := map[string]string{
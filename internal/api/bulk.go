@@ -0,0 +1,339 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-playground/validator"
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+// bulkLimit caps how many transactions one bulk request may match.
+const bulkLimit = 500
+
+var (
+	errBulkTarget   = errors.New("Either ids or filter is required.")
+	errBulkLimit    = errors.New("A bulk operation can match at most 500 transactions.")
+	errBulkCategory = errors.New("category_id is required to recategorize.")
+	errBulkAccount  = errors.New("account_id is required to move.")
+	errBulkTags     = errors.New("tags are required to tag.")
+	errBulkDate     = errors.New("from and to must be dates (YYYY-MM-DD) with from on or before to.")
+)
+
+type bulkTransactionRequest struct {
+	Action string                 `json:"action" validate:"oneof=recategorize move tag delete"`
+	IDs    []uint                 `json:"ids,omitempty"`
+	Filter *bulkTransactionFilter `json:"filter,omitempty"`
+	// Versions holds the version the client last saw of listed IDs, keyed
+	// by ID. If any of those has changed since, nothing is changed.
+	Versions map[uint]int `json:"versions,omitempty"`
+	// CategoryID, AccountID and Tags are the targets of recategorize, move
+	// and tag.
+	CategoryID uint     `json:"category_id,omitempty"`
+	AccountID  uint     `json:"account_id,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+}
+
+type bulkTransactionFilter struct {
+	FamilyMemberID *uint  `json:"family_member_id,omitempty"`
+	PayeeID        *uint  `json:"payee_id,omitempty"`
+	AccountID      *uint  `json:"account_id,omitempty"`
+	CategoryID     *uint  `json:"category_id,omitempty"`
+	Operation      string `json:"operation,omitempty" validate:"omitempty,oneof=Expense Income Transfer Refund"`
+	// From and To are inclusive YYYY-MM-DD dates; either may be left out.
+	From     string   `json:"from,omitempty"`
+	To       string   `json:"to,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	TagMatch string   `json:"tag_match,omitempty" validate:"omitempty,oneof=any all"`
+}
+
+// transactionFilter turns the request filter into the repository's, with
+// the dates as the half-open range [From, To+1).
+func (f bulkTransactionFilter) transactionFilter() (domain.TransactionFilter, error) {
+	filter := domain.TransactionFilter{
+		FamilyMemberID: f.FamilyMemberID,
+		PayeeID:        f.PayeeID,
+		AccountID:      f.AccountID,
+		CategoryID:     f.CategoryID,
+		Operation:      f.Operation,
+	}
+	if len(f.Tags) > 0 {
+		filter.Tags = f.Tags
+		filter.TagMatch = f.TagMatch
+		if filter.TagMatch == "" {
+			filter.TagMatch = domain.TagMatchAny
+		}
+	}
+
+	if f.From != "" {
+		from, err := time.Parse("2006-01-02", f.From)
+		if err != nil {
+			return filter, errBulkDate
+		}
+		filter.From = &from
+	}
+	if f.To != "" {
+		to, err := time.Parse("2006-01-02", f.To)
+		if err != nil {
+			return filter, errBulkDate
+		}
+		to = to.AddDate(0, 0, 1)
+		filter.To = &to
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return filter, errBulkDate
+	}
+
+	return filter, nil
+}
+
+// checkBulkTarget makes sure the action has what it needs and that the
+// category or account it points at belongs to householdID.
+func (a api) checkBulkTarget(ctx context.Context, req bulkTransactionRequest, householdID uint) (int, error) {
+	switch req.Action {
+	case domain.BulkRecategorize:
+		if req.CategoryID == 0 {
+			return 400, errBulkCategory
+		}
+		if _, err := a.householdCategory(ctx, req.CategoryID, householdID); err != nil {
+			return referenceStatus(err), err
+		}
+	case domain.BulkMove:
+		if req.AccountID == 0 {
+			return 400, errBulkAccount
+		}
+		if _, err := a.householdAccount(ctx, req.AccountID, householdID); err != nil {
+			return referenceStatus(err), err
+		}
+	case domain.BulkTag:
+		if len(domain.NormalizeTags(req.Tags)) == 0 {
+			return 400, errBulkTags
+		}
+	}
+	return 0, nil
+}
+
+// bulkTransactions loads what the request matches. Listed IDs that don't
+// exist or belong to another household are reported as skipped, and those
+// given a version in req.Versions keep it so the write checks against it.
+func (a api) bulkTransactions(ctx context.Context, req bulkTransactionRequest, householdID uint, res *domain.BulkResult) ([]domain.Transaction, error) {
+	if req.Filter != nil {
+		filter, err := req.Filter.transactionFilter()
+		if err != nil {
+			return nil, err
+		}
+
+		trns, err := a.transactionRepo.GetByHouseholdID(ctx, householdID, filter)
+		if err != nil {
+			return nil, err
+		}
+		res.Matched = len(trns)
+		if len(trns) > bulkLimit {
+			return nil, errBulkLimit
+		}
+		return trns, nil
+	}
+
+	seen := map[uint]bool{}
+	trns := []domain.Transaction{}
+	for _, id := range req.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		trn, err := a.transactionRepo.GetByID(ctx, id)
+		if err != nil {
+			if err.Error() != domain.ErrNotFound.Error() {
+				return nil, err
+			}
+			res.Skipped = append(res.Skipped, domain.BulkSkip{ID: id, Reason: domain.BulkSkipNotFound})
+			continue
+		}
+
+		if trn.HouseholdID != householdID {
+			res.Skipped = append(res.Skipped, domain.BulkSkip{ID: id, Reason: domain.BulkSkipForbidden})
+			continue
+		}
+
+		if version, ok := req.Versions[id]; ok {
+			trn.Version = version
+		}
+
+		trns = append(trns, trn)
+	}
+	res.Matched = len(seen)
+	return trns, nil
+}
+
+// applyBulk changes trn the way the action asks, reporting false when it
+// is already that way.
+func applyBulk(trn *domain.Transaction, req bulkTransactionRequest) bool {
+	switch req.Action {
+	case domain.BulkRecategorize:
+		if trn.CategoryID == req.CategoryID && len(trn.Lines) == 0 {
+			return false
+		}
+		// The whole amount goes to the new category.
+		trn.CategoryID = req.CategoryID
+		trn.Lines = nil
+	case domain.BulkMove:
+		if trn.AccountID == req.AccountID {
+			return false
+		}
+		trn.AccountID = req.AccountID
+	case domain.BulkTag:
+		before := len(domain.NormalizeTags(trn.Tags))
+		trn.Tags = domain.NormalizeTags(append(trn.Tags, req.Tags...))
+		if len(trn.Tags) == before {
+			return false
+		}
+	}
+	return true
+}
+
+// movedTransactions reloads trns and returns those whose version is no
+// longer the one in versions, ordered by ID.
+func (a api) movedTransactions(ctx context.Context, trns []domain.Transaction, versions map[uint]int) ([]domain.Transaction, error) {
+	moved := []domain.Transaction{}
+	for _, trn := range trns {
+		current, err := a.transactionRepo.GetByID(ctx, trn.ID)
+		if err != nil {
+			if err.Error() == domain.ErrNotFound.Error() {
+				continue
+			}
+			return nil, err
+		}
+		if current.Version != versions[trn.ID] {
+			moved = append(moved, current)
+		}
+	}
+	sort.Slice(moved, func(i, j int) bool { return moved[i].ID < moved[j].ID })
+	return moved, nil
+}
+
+// transactionBulkHandler recategorizes, moves, tags or deletes a list of
+// transactions, or all of those matching a filter, in one database
+// transaction.
+//
+// The filter takes family_member_id, payee_id, account_id, category_id,
+// operation, from and to (inclusive dates on when the transaction was
+// created), and tags with tag_match. Every transaction is written at the
+// version it was loaded at, or the one given in versions; if any has
+// changed since, nothing is written and the answer is 412 with the current
+// state of those that moved.
+func (a api) transactionBulkHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	reqBody := bulkTransactionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	if (len(reqBody.IDs) == 0) == (reqBody.Filter == nil) {
+		a.errorResponse(w, r, 400, errBulkTarget)
+		return
+	}
+
+	if len(reqBody.IDs) > bulkLimit {
+		a.errorResponse(w, r, 400, errBulkLimit)
+		return
+	}
+
+	if status, err := a.checkBulkTarget(ctx, reqBody, mem.HouseholdID); err != nil {
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	res := domain.BulkResult{
+		Action:  reqBody.Action,
+		Changed: []uint{},
+		Skipped: []domain.BulkSkip{},
+	}
+
+	trns, err := a.bulkTransactions(ctx, reqBody, mem.HouseholdID, &res)
+	if err != nil {
+		if err == errBulkLimit || err == errBulkDate {
+			a.errorResponse(w, r, 400, err)
+			return
+		}
+		a.logger.Error("failed to fetch transactions from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	changed := []domain.Transaction{}
+	for _, trn := range trns {
+		if !applyBulk(&trn, reqBody) {
+			res.Skipped = append(res.Skipped, domain.BulkSkip{ID: trn.ID, Reason: domain.BulkSkipUnchanged})
+			continue
+		}
+		changed = append(changed, trn)
+		res.Changed = append(res.Changed, trn.ID)
+	}
+
+	if len(changed) > 0 {
+		versions := map[uint]int{}
+		for _, trn := range changed {
+			versions[trn.ID] = trn.Version
+		}
+
+		if reqBody.Action == domain.BulkDelete {
+			_, err = a.transactionRepo.DeleteMany(ctx, changed)
+		} else {
+			err = a.transactionRepo.UpdateMany(ctx, changed)
+		}
+		if err != nil {
+			if err.Error() != domain.ErrVersionConflict.Error() {
+				a.logger.Error("failed to apply bulk transaction change", zap.Error(err))
+				a.errorResponse(w, r, 500, err)
+				return
+			}
+
+			moved, err := a.movedTransactions(ctx, changed, versions)
+			if err != nil {
+				a.logger.Error("failed to fetch transactions from database", zap.Error(err))
+				a.errorResponse(w, r, 500, err)
+				return
+			}
+
+			resJSON, err := json.Marshal(moved)
+			if err != nil {
+				a.errorResponse(w, r, 500, err)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusPreconditionFailed)
+			w.Write(resJSON)
+			return
+		}
+	}
+
+	resJSON, err := json.Marshal(res)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+func (f *fakeTransactions) DeleteMany(ctx context.Context, trns []domain.Transaction) (int64, error) {
+	for _, trn := range trns {
+		if cur, err := f.GetByID(ctx, trn.ID); err != nil || cur.Version != trn.Version {
+			return 0, domain.ErrVersionConflict
+		}
+	}
+
+	kept := []domain.Transaction{}
+	for _, cur := range f.transactions {
+		deleted := false
+		for _, trn := range trns {
+			deleted = deleted || trn.ID == cur.ID
+		}
+		if !deleted {
+			kept = append(kept, cur)
+		}
+	}
+	f.transactions = kept
+	return int64(len(trns)), nil
+}
+
+func (tt *transactionTest) bulk(body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/bulk", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+tt.token)
+	req.Header.Set(HouseholdHeader, "1")
+	res := httptest.NewRecorder()
+	tt.router.ServeHTTP(res, req)
+	return res
+}
+
+func TestBulkDeleteOfMovedTransactionIsStale(t *testing.T) {
+	tt := newTransactionTest(t, domain.Split{})
+
+	res := tt.bulk(`{"action":"delete","ids":[1],"versions":{"1":2}}`)
+	if res.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale bulk delete returned %d, want 412: %s", res.Code, res.Body)
+	}
+
+	var moved []domain.Transaction
+	if err := json.Unmarshal(res.Body.Bytes(), &moved); err != nil {
+		t.Fatal(err)
+	}
+	if len(moved) != 1 || moved[0].ID != 1 || moved[0].Version != 3 {
+		t.Fatalf("412 didn't carry the moved transaction: %s", res.Body)
+	}
+	if _, err := tt.transactions.GetByID(context.Background(), 1); err != nil {
+		t.Fatal("stale bulk delete removed the transaction")
+	}
+
+	res = tt.bulk(`{"action":"delete","ids":[1],"versions":{"1":3}}`)
+	if res.Code != http.StatusOK {
+		t.Fatalf("bulk delete returned %d: %s", res.Code, res.Body)
+	}
+	if _, err := tt.transactions.GetByID(context.Background(), 1); err == nil {
+		t.Fatal("transaction was not deleted")
+	}
+}
@@ -16,20 +16,42 @@ import (
 )
 
 type CatCtx struct{}
+type CatOverrideCtx struct{}
 
 func (a api) CategoryRoutes() chi.Router {
 	r := chi.NewRouter()
 	r.Use(middlewares.Auth)
+	r.Use(middlewares.Scope("categories"))
+	r.Use(a.Idempotency)
 
-	r.Get("/", a.categoryListHandler)
-	r.Post("/", a.categoryCreateHandler)
+	r.Group(func(r chi.Router) {
+		r.Use(a.MemberCtx)
+
+		r.Get("/", a.categoryListHandler)
+		r.Post("/", a.categoryCreateHandler)
+	})
 
 	r.Route("/{id}", func(r chi.Router) {
 		r.Use(a.CategoryCtx)
 
 		r.Get("/", a.categoryGetHandler)
-		r.Put("/", a.categoryUpdateHandler)
-		r.Delete("/", a.categoryDeleteHandler)
+		r.Get("/usage", a.categoryUsageHandler)
+
+		r.Route("/override", func(r chi.Router) {
+			r.Use(a.CategoryOverrideCtx)
+
+			r.Get("/", a.categoryOverrideGetHandler)
+			r.With(a.IfMatch(CatOverrideCtx{})).Put("/", a.categoryOverrideSaveHandler)
+			r.With(a.IfMatch(CatOverrideCtx{})).Delete("/", a.categoryOverrideDeleteHandler)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(a.categoryWritable)
+
+			r.With(a.IfMatch(CatCtx{})).Put("/", a.categoryUpdateHandler)
+			r.With(a.IfMatch(CatCtx{})).Delete("/", a.categoryDeleteHandler)
+			r.Post("/merge", a.categoryMergeHandler)
+		})
 	})
 
 	return r
@@ -39,12 +61,6 @@ func (a api) CategoryCtx(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
-		sub, err := util.GetSub(ctx)
-		if err != nil {
-			a.errorResponse(w, r, 500, err)
-			return
-		}
-
 		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
 			a.errorResponse(w, r, 500, err)
@@ -61,9 +77,11 @@ func (a api) CategoryCtx(next http.Handler) http.Handler {
 			return
 		}
 
-		if item.CreatedBy != nil && *item.CreatedBy != sub {
-			a.errorResponse(w, r, 403, domain.ErrForbidden)
-			return
+		// Global categories are visible to everyone.
+		if item.HouseholdID != nil {
+			if _, ok := a.authorizeMember(w, r, *item.HouseholdID); !ok {
+				return
+			}
 		}
 
 		ctx = context.WithValue(ctx, CatCtx{}, item)
@@ -71,29 +89,144 @@ func (a api) CategoryCtx(next http.Handler) http.Handler {
 	})
 }
 
+// CategoryOverrideCtx loads the signed in user's override of the category in
+// the context. Without one it stores an empty override at version 0, so the
+// first save is made with If-Match: "0".
+func (a api) CategoryOverrideCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		item, ok := ctx.Value(CatCtx{}).(domain.Category)
+		if !ok {
+			http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+			return
+		}
+
+		sub, err := util.GetSub(ctx)
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+
+		o, err := a.categoryOverrideRepo.Get(ctx, sub, item.ID)
+		if err != nil {
+			if err.Error() != domain.ErrNotFound.Error() {
+				a.errorResponse(w, r, 500, err)
+				return
+			}
+			o = domain.CategoryOverride{UserID: sub, CategoryID: item.ID}
+		}
+
+		ctx = context.WithValue(ctx, CatOverrideCtx{}, o)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// categoryWritable stops changes to global categories, which are shared by
+// everyone and only change through the admin API. Users can override how
+// they see them instead.
+func (a api) categoryWritable(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		item, ok := r.Context().Value(CatCtx{}).(domain.Category)
+		if !ok || item.HouseholdID == nil {
+			a.errorResponse(w, r, 403, domain.ErrForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+type mergeCategoryRequest struct {
+	IntoID uint `json:"into_id" validate:"required"`
+}
+
+type categoryOverrideRequest struct {
+	Hidden    bool    `json:"hidden"`
+	Name      *string `json:"name,omitempty"`
+	Icon      *string `json:"icon,omitempty"`
+	Color     *string `json:"color,omitempty" validate:"omitempty,hexcolor"`
+	SortOrder *int    `json:"sort_order,omitempty"`
+}
+
 type createCategoryRequest struct {
-	Name string `json:"name" validate:"required"`
-	Note string `json:"note,omitempty"`
+	Name     string `json:"name" validate:"required"`
+	Note     string `json:"note,omitempty"`
+	ParentID *uint  `json:"parent_id,omitempty"`
+}
+
+// checkCategoryParent makes sure cat may be placed under parentID: the parent
+// must be visible to cat's household, global categories only nest under
+// global ones, and cat can't end up below itself. The last check only gives
+// a friendly answer; the database refuses cycles under a lock, so concurrent
+// moves can't slip one past it.
+func (a api) checkCategoryParent(ctx context.Context, cat domain.Category, parentID *uint) (int, error) {
+	if parentID == nil {
+		return 0, nil
+	}
+
+	if cat.HouseholdID == nil {
+		parent, err := a.categoryRepo.GetByID(ctx, *parentID)
+		if err != nil {
+			return referenceStatus(err), err
+		}
+		if parent.HouseholdID != nil {
+			return 400, domain.ErrCategoryParent
+		}
+	} else if _, err := a.householdCategory(ctx, *parentID, *cat.HouseholdID); err != nil {
+		return referenceStatus(err), err
+	}
+
+	if cat.ID == 0 {
+		return 0, nil
+	}
+
+	ancestors, err := a.categoryRepo.GetAncestorIDs(ctx, *parentID)
+	if err != nil {
+		return 500, err
+	}
+	for _, id := range ancestors {
+		if id == cat.ID {
+			return 400, domain.ErrCategoryCycle
+		}
+	}
+	return 0, nil
 }
 
 func (a api) categoryListHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	cats, err := a.categoryRepo.GetByHouseholdID(ctx, mem.HouseholdID)
+	if err != nil {
+		a.logger.Error("failed to fetch categories from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
 	sub, err := util.GetSub(ctx)
 	if err != nil {
-		a.logger.Error("failed to get sub from context", zap.Error(err))
 		a.errorResponse(w, r, 500, err)
 		return
 	}
 
-	cats, err := a.categoryRepo.GetByUserSUB(ctx, sub)
+	overrides, err := a.categoryOverrideRepo.GetByUserID(ctx, sub)
 	if err != nil {
-		a.logger.Error("failed to fetch categories from database", zap.Error(err))
+		a.logger.Error("failed to fetch category overrides from database", zap.Error(err))
 		a.errorResponse(w, r, 500, err)
 		return
 	}
 
+	// Hidden categories are left out unless ?hidden=true.
+	cats = domain.ApplyOverrides(cats, overrides, r.URL.Query().Get("hidden") == "true")
+
+	// Categories come back as a tree unless ?flat=true.
+	if r.URL.Query().Get("flat") != "true" {
+		cats = domain.CategoryTree(cats)
+	}
+
 	resJSON, err := json.Marshal(cats)
 	if err != nil {
 		a.errorResponse(w, r, 500, err)
@@ -109,11 +242,7 @@ func (a api) categoryCreateHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
-	sub, err := util.GetSub(ctx)
-	if err != nil {
-		a.errorResponse(w, r, 500, err)
-		return
-	}
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
 
 	reqBody := createCategoryRequest{}
 	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
@@ -130,9 +259,16 @@ func (a api) categoryCreateHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	newCat := domain.Category{
-		Name:      reqBody.Name,
-		Note:      reqBody.Note,
-		CreatedBy: &sub,
+		Name:        reqBody.Name,
+		Note:        reqBody.Note,
+		CreatedBy:   &mem.UserID,
+		HouseholdID: &mem.HouseholdID,
+		ParentID:    reqBody.ParentID,
+	}
+
+	if status, err := a.checkCategoryParent(ctx, newCat, newCat.ParentID); err != nil {
+		a.errorResponse(w, r, status, err)
+		return
 	}
 
 	cat, err := a.categoryRepo.Create(ctx, &newCat)
@@ -147,6 +283,7 @@ func (a api) categoryCreateHandler(w http.ResponseWriter, r *http.Request) {
 		a.errorResponse(w, r, 500, err)
 		return
 	}
+	setETag(w, cat)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(resJSON)
@@ -162,12 +299,29 @@ func (a api) categoryGetHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if item.HouseholdID == nil {
+		sub, err := util.GetSub(ctx)
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+
+		o, err := a.categoryOverrideRepo.Get(ctx, sub, item.ID)
+		if err == nil {
+			o.Apply(&item)
+		} else if err.Error() != domain.ErrNotFound.Error() {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+	}
+
 	res, err := json.Marshal(item)
 	if err != nil {
 		http.Error(w, "Error marshaling response", http.StatusInternalServerError)
 		return
 	}
 
+	setETag(w, item)
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(res)
 }
@@ -183,15 +337,32 @@ func (a api) categoryUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	householdID, version := item.HouseholdID, item.Version
 	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
 		a.logger.Error("failed to parse request json", zap.Error(err))
 		a.errorResponse(w, r, 422, err)
 		return
 	}
 	defer r.Body.Close()
+	item.HouseholdID = householdID
+	item.Version = version
+
+	if status, err := a.checkCategoryParent(ctx, item, item.ParentID); err != nil {
+		a.errorResponse(w, r, status, err)
+		return
+	}
 
 	cat, err := a.categoryRepo.Update(ctx, &item)
 	if err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.categoryRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		if err.Error() == domain.ErrCategoryCycle.Error() {
+			a.errorResponse(w, r, 400, err)
+			return
+		}
 		a.logger.Error("failed to delete category", zap.Error(err))
 		a.errorResponse(w, r, 500, err)
 		return
@@ -203,6 +374,7 @@ func (a api) categoryUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, cat)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(resJSON)
@@ -218,13 +390,141 @@ func (a api) categoryDeleteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := a.categoryRepo.Delete(ctx, item.ID); err != nil {
-		a.logger.Error("failed to delete category", zap.Error(err))
-		status := 500
-		if err.Error() == domain.ErrNotFound.Error() {
-			status = 404
+	if status, err := a.deleteCategory(ctx, item, r.URL.Query().Get("replacement_id")); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.categoryRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
 		}
 		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	data := map[string]string{
+		"message": "Item deleted successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func (a api) categoryOverrideGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	o, ok := ctx.Value(CatOverrideCtx{}).(domain.CategoryOverride)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	resJSON, err := json.Marshal(o)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, o)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+// categoryOverrideSaveHandler sets how the signed in user sees a global
+// category.
+func (a api) categoryOverrideSaveHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(CatCtx{}).(domain.Category)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	if item.HouseholdID != nil {
+		a.errorResponse(w, r, 400, domain.ErrNotGlobal)
+		return
+	}
+
+	reqBody := categoryOverrideRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	cur := ctx.Value(CatOverrideCtx{}).(domain.CategoryOverride)
+
+	o, err := a.categoryOverrideRepo.Save(ctx, &domain.CategoryOverride{
+		Versioned:  cur.Versioned,
+		UserID:     cur.UserID,
+		CategoryID: item.ID,
+		Hidden:     reqBody.Hidden,
+		Name:       reqBody.Name,
+		Icon:       reqBody.Icon,
+		Color:      reqBody.Color,
+		SortOrder:  reqBody.SortOrder,
+	})
+	if err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.categoryOverrideRepo.Get(ctx, cur.UserID, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to save category override", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	o.Apply(&item)
+
+	resJSON, err := json.Marshal(item)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	// The tag is the override's; the category keeps its own at /.
+	setETag(w, o)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+// categoryOverrideDeleteHandler goes back to the global category as is.
+func (a api) categoryOverrideDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(CatCtx{}).(domain.Category)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	o := ctx.Value(CatOverrideCtx{}).(domain.CategoryOverride)
+	if o.Version == 0 {
+		a.errorResponse(w, r, 404, domain.ErrNotFound)
+		return
+	}
+
+	if err := a.categoryOverrideRepo.Delete(ctx, o.UserID, item.ID, o.Version); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.categoryOverrideRepo.Get(ctx, o.UserID, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to delete category override", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
 	}
 
 	data := map[string]string{
@@ -235,3 +535,157 @@ func (a api) categoryDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(data)
 }
+
+func (a api) categoryUsageHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(CatCtx{}).(domain.Category)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	usage, err := a.categoryRepo.GetUsage(ctx, item.ID)
+	if err != nil {
+		a.logger.Error("failed to fetch category usage", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(usage)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) categoryMergeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(CatCtx{}).(domain.Category)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	reqBody := mergeCategoryRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	if status, err := a.mergeCategory(ctx, item, reqBody.IntoID); err != nil {
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	cat, err := a.categoryRepo.GetByID(ctx, reqBody.IntoID)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(cat)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+// deleteCategory deletes cat. A category that is still in use can only be
+// deleted by merging it into a replacement. It returns the status to respond
+// with when it fails.
+func (a api) deleteCategory(ctx context.Context, cat domain.Category, replacement string) (int, error) {
+	if replacement != "" {
+		id, err := strconv.Atoi(replacement)
+		if err != nil {
+			return 400, err
+		}
+		return a.mergeCategory(ctx, cat, uint(id))
+	}
+
+	usage, err := a.categoryRepo.GetUsage(ctx, cat.ID)
+	if err != nil {
+		a.logger.Error("failed to fetch category usage", zap.Error(err))
+		return 500, err
+	}
+
+	if usage.InUse() {
+		return 409, domain.ErrCategoryInUse
+	}
+
+	if err := a.categoryRepo.Delete(ctx, cat.ID, cat.Version); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			return 412, err
+		}
+		a.logger.Error("failed to delete category", zap.Error(err))
+		if err.Error() == domain.ErrNotFound.Error() {
+			return 404, err
+		}
+		return 500, err
+	}
+
+	return 200, nil
+}
+
+// mergeCategory moves the transactions, budgets and rules of cat over to
+// intoID and deletes cat. It returns the status to respond with when it
+// fails.
+func (a api) mergeCategory(ctx context.Context, cat domain.Category, intoID uint) (int, error) {
+	if intoID == cat.ID {
+		return 400, domain.ErrCategoryMerge
+	}
+
+	if cat.HouseholdID != nil {
+		if _, err := a.householdCategory(ctx, intoID, *cat.HouseholdID); err != nil {
+			return referenceStatus(err), err
+		}
+	} else {
+		// Global categories are used by every household.
+		into, err := a.categoryRepo.GetByID(ctx, intoID)
+		if err != nil {
+			return referenceStatus(err), err
+		}
+		if into.HouseholdID != nil {
+			return 400, domain.ErrCategoryGlobal
+		}
+	}
+
+	// Merging into a subcategory would leave it under itself.
+	ancestorIDs, err := a.categoryRepo.GetAncestorIDs(ctx, intoID)
+	if err != nil {
+		return 500, err
+	}
+	for _, id := range ancestorIDs {
+		if id == cat.ID {
+			return 400, domain.ErrCategoryMerge
+		}
+	}
+
+	if err := a.categoryRepo.Merge(ctx, cat.ID, intoID); err != nil {
+		a.logger.Error("failed to merge category", zap.Error(err))
+		if err.Error() == domain.ErrNotFound.Error() {
+			return 404, err
+		}
+		return 500, err
+	}
+
+	return 200, nil
+}
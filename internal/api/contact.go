@@ -0,0 +1,264 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/middlewares"
+)
+
+type ContactCtx struct{}
+
+func (a api) ContactRoutes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middlewares.Auth)
+	r.Use(middlewares.Scope("contacts"))
+	r.Use(a.Idempotency)
+
+	r.Group(func(r chi.Router) {
+		r.Use(a.MemberCtx)
+
+		r.Get("/", a.contactListHandler)
+		r.Post("/", a.contactCreateHandler)
+	})
+
+	r.Route("/{id}", func(r chi.Router) {
+		r.Use(a.ContactCtx)
+
+		r.Get("/", a.contactGetHandler)
+		r.With(a.IfMatch(ContactCtx{})).Put("/", a.contactUpdateHandler)
+		r.With(a.IfMatch(ContactCtx{})).Delete("/", a.contactDeleteHandler)
+	})
+
+	return r
+}
+
+func (a api) ContactCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+
+		item, err := a.contactRepo.GetByID(ctx, uint(id))
+		if err != nil {
+			status := 500
+			if err.Error() == domain.ErrNotFound.Error() {
+				status = 404
+			}
+			a.errorResponse(w, r, status, err)
+			return
+		}
+
+		if _, ok := a.authorizeMember(w, r, item.HouseholdID); !ok {
+			return
+		}
+
+		ctx = context.WithValue(ctx, ContactCtx{}, item)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// householdContact loads a contact profile of householdID.
+func (a api) householdContact(ctx context.Context, id uint, householdID uint) (domain.Contact, error) {
+	con, err := a.contactRepo.GetByID(ctx, id)
+	if err != nil {
+		return domain.Contact{}, err
+	}
+
+	if con.HouseholdID != householdID {
+		return domain.Contact{}, domain.ErrForbidden
+	}
+	return con, nil
+}
+
+type createContactRequest struct {
+	Name  string `json:"name" validate:"required"`
+	Email string `json:"email,omitempty" validate:"omitempty,email"`
+}
+
+func (a api) contactListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	cons, err := a.contactRepo.GetByHouseholdID(ctx, mem.HouseholdID)
+	if err != nil {
+		a.logger.Error("failed to fetch contacts from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(cons)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) contactCreateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	reqBody := createContactRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	newCon := domain.Contact{
+		HouseholdID: mem.HouseholdID,
+		Name:        reqBody.Name,
+		Email:       reqBody.Email,
+		CreatedBy:   mem.UserID,
+	}
+
+	con, err := a.contactRepo.Create(ctx, &newCon)
+	if err != nil {
+		a.logger.Error("failed to create contact", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(con)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, con)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) contactGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(ContactCtx{}).(domain.Contact)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	resJSON, err := json.Marshal(item)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, item)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) contactUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(ContactCtx{}).(domain.Contact)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	reqBody := createContactRequest{Name: item.Name, Email: item.Email}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	item.Name = reqBody.Name
+	item.Email = reqBody.Email
+
+	con, err := a.contactRepo.Update(ctx, &item)
+	if err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.contactRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to update contact", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(con)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, con)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) contactDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(ContactCtx{}).(domain.Contact)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := a.contactRepo.Delete(ctx, item.ID, item.Version); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.contactRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to delete contact", zap.Error(err))
+		status := 500
+		if err.Error() == domain.ErrNotFound.Error() {
+			status = 404
+		}
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	data := map[string]string{
+		"message": "Item deleted successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type versioned interface {
+	CurrentVersion() int
+}
+
+// etag formats an entity version as a strong entity tag.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+func setETag(w http.ResponseWriter, item versioned) {
+	w.Header().Set("ETag", etag(item.CurrentVersion()))
+}
+
+// etagMatches reports whether an If-Match header value lists version. Both
+// "*" and weak tags are accepted.
+func etagMatches(header string, version int) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// IfMatch requires PUT and DELETE requests to carry the ETag of the item
+// stored in the context under key, answering 428 without one and 412 with
+// the current item when it is stale.
+func (a api) IfMatch(key interface{}) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			item, ok := r.Context().Value(key).(versioned)
+			if !ok {
+				http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+				return
+			}
+
+			header := r.Header.Get("If-Match")
+			if header == "" {
+				a.errorResponse(w, r, 428, domain.ErrPreconditionNeeded)
+				return
+			}
+
+			if !etagMatches(header, item.CurrentVersion()) {
+				a.preconditionFailed(w, r, item, nil)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// preconditionFailed answers 412 with the current representation of an item
+// that changed under the client. err is the error from reloading it, if any.
+func (a api) preconditionFailed(w http.ResponseWriter, r *http.Request, current versioned, err error) {
+	if err != nil {
+		status := 500
+		if err.Error() == domain.ErrNotFound.Error() {
+			status = 404
+		}
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(current)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, current)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	w.Write(resJSON)
+}
@@ -0,0 +1,297 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/middlewares"
+)
+
+type FamilyMemberCtx struct{}
+
+func (a api) FamilyMemberRoutes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middlewares.Auth)
+	r.Use(middlewares.Scope("family_members"))
+	r.Use(a.Idempotency)
+
+	r.Group(func(r chi.Router) {
+		r.Use(a.MemberCtx)
+
+		r.Get("/", a.familyMemberListHandler)
+		r.Post("/", a.familyMemberCreateHandler)
+		r.Get("/report", a.familyMemberReportHandler)
+	})
+
+	r.Route("/{id}", func(r chi.Router) {
+		r.Use(a.FamilyMemberCtx)
+
+		r.Get("/", a.familyMemberGetHandler)
+		r.With(a.IfMatch(FamilyMemberCtx{})).Put("/", a.familyMemberUpdateHandler)
+		r.With(a.IfMatch(FamilyMemberCtx{})).Delete("/", a.familyMemberDeleteHandler)
+	})
+
+	return r
+}
+
+func (a api) FamilyMemberCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+
+		item, err := a.familyMemberRepo.GetByID(ctx, uint(id))
+		if err != nil {
+			status := 500
+			if err.Error() == domain.ErrNotFound.Error() {
+				status = 404
+			}
+			a.errorResponse(w, r, status, err)
+			return
+		}
+
+		if _, ok := a.authorizeMember(w, r, item.HouseholdID); !ok {
+			return
+		}
+
+		ctx = context.WithValue(ctx, FamilyMemberCtx{}, item)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// householdFamilyMember loads a family member profile of householdID.
+func (a api) householdFamilyMember(ctx context.Context, id uint, householdID uint) (domain.FamilyMember, error) {
+	fm, err := a.familyMemberRepo.GetByID(ctx, id)
+	if err != nil {
+		return domain.FamilyMember{}, err
+	}
+
+	if fm.HouseholdID != householdID {
+		return domain.FamilyMember{}, domain.ErrForbidden
+	}
+	return fm, nil
+}
+
+type createFamilyMemberRequest struct {
+	Name         string `json:"name" validate:"required"`
+	Relationship string `json:"relationship,omitempty"`
+}
+
+func (a api) familyMemberListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	fms, err := a.familyMemberRepo.GetByHouseholdID(ctx, mem.HouseholdID)
+	if err != nil {
+		a.logger.Error("failed to fetch family members from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(fms)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) familyMemberCreateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	reqBody := createFamilyMemberRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	newFm := domain.FamilyMember{
+		HouseholdID:  mem.HouseholdID,
+		Name:         reqBody.Name,
+		Relationship: reqBody.Relationship,
+		CreatedBy:    mem.UserID,
+	}
+
+	fm, err := a.familyMemberRepo.Create(ctx, &newFm)
+	if err != nil {
+		a.logger.Error("failed to create family member", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(fm)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, fm)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+// familyMemberReportHandler sums spending per family member and category
+// between ?from and ?to (inclusive, YYYY-MM-DD).
+func (a api) familyMemberReportHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	from, to, err := parseDateRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	totals, err := a.familyMemberRepo.GetTotals(ctx, mem.HouseholdID, from, to)
+	if err != nil {
+		a.logger.Error("failed to fetch family member totals from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(totals)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) familyMemberGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(FamilyMemberCtx{}).(domain.FamilyMember)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	resJSON, err := json.Marshal(item)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, item)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) familyMemberUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(FamilyMemberCtx{}).(domain.FamilyMember)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	reqBody := createFamilyMemberRequest{Name: item.Name, Relationship: item.Relationship}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	item.Name = reqBody.Name
+	item.Relationship = reqBody.Relationship
+
+	fm, err := a.familyMemberRepo.Update(ctx, &item)
+	if err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.familyMemberRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to update family member", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(fm)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, fm)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) familyMemberDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(FamilyMemberCtx{}).(domain.FamilyMember)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := a.familyMemberRepo.Delete(ctx, item.ID, item.Version); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.familyMemberRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to delete family member", zap.Error(err))
+		status := 500
+		if err.Error() == domain.ErrNotFound.Error() {
+			status = 404
+		}
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	data := map[string]string{
+		"message": "Item deleted successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(data)
+}
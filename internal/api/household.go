@@ -0,0 +1,696 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/middlewares"
+	"github.com/Brix101/budgetto-backend/internal/util"
+)
+
+// HouseholdHeader picks the household that list and create endpoints work
+// on. Without it they use the caller's personal household.
+const HouseholdHeader = "X-Household-ID"
+
+type HouseholdCtx struct{}
+type MemberCtx struct{}
+type HouseholdMemberCtx struct{}
+
+func (a api) HouseholdRoutes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middlewares.Auth)
+	r.Use(middlewares.Scope("households"))
+	r.Use(a.Idempotency)
+
+	r.Get("/", a.householdListHandler)
+	r.Post("/", a.householdCreateHandler)
+
+	r.Route("/{id}", func(r chi.Router) {
+		r.Use(a.HouseholdCtx)
+
+		r.Get("/", a.householdGetHandler)
+		r.With(a.IfMatch(HouseholdCtx{})).Put("/", a.householdUpdateHandler)
+		r.With(a.IfMatch(HouseholdCtx{})).Delete("/", a.householdDeleteHandler)
+
+		r.Get("/members", a.householdMemberListHandler)
+		r.Post("/members", a.householdMemberCreateHandler)
+
+		r.Route("/members/{userID}", func(r chi.Router) {
+			r.Use(a.HouseholdMemberCtx)
+
+			r.With(a.IfMatch(HouseholdMemberCtx{})).Put("/", a.householdMemberUpdateHandler)
+			r.With(a.IfMatch(HouseholdMemberCtx{})).Delete("/", a.householdMemberDeleteHandler)
+		})
+	})
+
+	return r
+}
+
+// membership loads the signed in user's membership of householdID, answering
+// 403 when they don't belong to it.
+func (a api) membership(w http.ResponseWriter, r *http.Request, householdID uint) (domain.HouseholdMember, bool) {
+	ctx := r.Context()
+
+	sub, err := util.GetSub(ctx)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return domain.HouseholdMember{}, false
+	}
+
+	mem, err := a.householdMemberRepo.GetByHouseholdUser(ctx, householdID, sub)
+	if err != nil {
+		if err.Error() == domain.ErrNotFound.Error() {
+			a.errorResponse(w, r, 403, domain.ErrForbidden)
+			return domain.HouseholdMember{}, false
+		}
+		a.errorResponse(w, r, 500, err)
+		return domain.HouseholdMember{}, false
+	}
+
+	return mem, true
+}
+
+// authorizeMember is membership plus a role check: viewers may only read.
+func (a api) authorizeMember(w http.ResponseWriter, r *http.Request, householdID uint) (domain.HouseholdMember, bool) {
+	mem, ok := a.membership(w, r, householdID)
+	if !ok {
+		return mem, false
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead && !mem.CanWrite() {
+		a.errorResponse(w, r, 403, domain.ErrForbidden)
+		return mem, false
+	}
+
+	return mem, true
+}
+
+// MemberCtx resolves the household selected by HouseholdHeader, falling back
+// to the personal household, and stores the caller's membership.
+func (a api) MemberCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var householdID uint
+		if v := r.Header.Get(HouseholdHeader); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				a.errorResponse(w, r, 400, err)
+				return
+			}
+			householdID = uint(id)
+		} else {
+			sub, err := util.GetSub(ctx)
+			if err != nil {
+				a.errorResponse(w, r, 500, err)
+				return
+			}
+
+			hh, err := a.householdRepo.GetPersonal(ctx, sub)
+			if err != nil {
+				a.errorResponse(w, r, 500, err)
+				return
+			}
+			householdID = hh.ID
+		}
+
+		mem, ok := a.authorizeMember(w, r, householdID)
+		if !ok {
+			return
+		}
+
+		ctx = context.WithValue(ctx, MemberCtx{}, mem)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (a api) HouseholdCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+
+		item, err := a.householdRepo.GetByID(ctx, uint(id))
+		if err != nil {
+			status := 500
+			if err.Error() == domain.ErrNotFound.Error() {
+				status = 404
+			}
+			a.errorResponse(w, r, status, err)
+			return
+		}
+
+		mem, ok := a.membership(w, r, item.ID)
+		if !ok {
+			return
+		}
+		item.Role = mem.Role
+
+		ctx = context.WithValue(ctx, HouseholdCtx{}, item)
+		ctx = context.WithValue(ctx, MemberCtx{}, mem)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// createPersonalHousehold gives a newly registered user the household their
+// data lives in by default.
+func (a api) createPersonalHousehold(ctx context.Context, usr *domain.User) error {
+	_, err := a.householdRepo.Create(ctx, &domain.Household{
+		Name:       "Personal",
+		IsPersonal: true,
+		CreatedBy:  &usr.ID,
+	})
+	return err
+}
+
+// householdCategory loads a category that householdID may reference: one of
+// its own or a global one.
+func (a api) householdCategory(ctx context.Context, id uint, householdID uint) (domain.Category, error) {
+	cat, err := a.categoryRepo.GetByID(ctx, id)
+	if err != nil {
+		return domain.Category{}, err
+	}
+
+	if cat.HouseholdID != nil && *cat.HouseholdID != householdID {
+		return domain.Category{}, domain.ErrForbidden
+	}
+	return cat, nil
+}
+
+// householdAccount loads an account that belongs to householdID.
+func (a api) householdAccount(ctx context.Context, id uint, householdID uint) (domain.Account, error) {
+	acc, err := a.accountRepo.GetByID(ctx, id)
+	if err != nil {
+		return domain.Account{}, err
+	}
+
+	if acc.HouseholdID != householdID {
+		return domain.Account{}, domain.ErrForbidden
+	}
+	return acc, nil
+}
+
+// referenceStatus maps the errors of the household* loaders to a response
+// status.
+func referenceStatus(err error) int {
+	switch err.Error() {
+	case domain.ErrNotFound.Error():
+		return 404
+	case domain.ErrForbidden.Error():
+		return 403
+	}
+	return 500
+}
+
+type createHouseholdRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type createHouseholdMemberRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=owner editor viewer"`
+}
+
+type updateHouseholdMemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner editor viewer"`
+}
+
+func (a api) householdListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	sub, err := util.GetSub(ctx)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	hhs, err := a.householdRepo.GetByUserSUB(ctx, sub)
+	if err != nil {
+		a.logger.Error("failed to fetch households from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(hhs)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) householdCreateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	sub, err := util.GetSub(ctx)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	reqBody := createHouseholdRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	newHh := domain.Household{
+		Name:      reqBody.Name,
+		CreatedBy: &sub,
+	}
+
+	hh, err := a.householdRepo.Create(ctx, &newHh)
+	if err != nil {
+		a.logger.Error("failed to create household", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(hh)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, hh)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) householdGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(HouseholdCtx{}).(domain.Household)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	resJSON, err := json.Marshal(item)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, item)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) householdUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(HouseholdCtx{}).(domain.Household)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+	if !mem.CanManage() {
+		a.errorResponse(w, r, 403, domain.ErrForbidden)
+		return
+	}
+
+	reqBody := createHouseholdRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	item.Name = reqBody.Name
+
+	hh, err := a.householdRepo.Update(ctx, &item)
+	if err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.householdRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to update household", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(hh)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, hh)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) householdDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(HouseholdCtx{}).(domain.Household)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+	if !mem.CanManage() {
+		a.errorResponse(w, r, 403, domain.ErrForbidden)
+		return
+	}
+
+	if item.IsPersonal {
+		a.errorResponse(w, r, 400, domain.ErrPersonalHousehold)
+		return
+	}
+
+	if err := a.householdRepo.Delete(ctx, item.ID, item.Version); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.householdRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to delete household", zap.Error(err))
+		status := 500
+		if err.Error() == domain.ErrNotFound.Error() {
+			status = 404
+		}
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	data := map[string]string{
+		"message": "Item deleted successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// HouseholdMemberCtx loads the member named by the userID URL parameter from
+// the household in the context.
+func (a api) HouseholdMemberCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		item, ok := ctx.Value(HouseholdCtx{}).(domain.Household)
+		if !ok {
+			http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+			return
+		}
+
+		userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+
+		target, err := a.householdMemberRepo.GetByHouseholdUser(ctx, item.ID, uint(userID))
+		if err != nil {
+			status := 500
+			if err.Error() == domain.ErrNotFound.Error() {
+				status = 404
+			}
+			a.errorResponse(w, r, status, err)
+			return
+		}
+
+		ctx = context.WithValue(ctx, HouseholdMemberCtx{}, target)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (a api) householdMemberListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(HouseholdCtx{}).(domain.Household)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	mems, err := a.householdMemberRepo.GetByHouseholdID(ctx, item.ID)
+	if err != nil {
+		a.logger.Error("failed to fetch household members from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(mems)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) householdMemberCreateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(HouseholdCtx{}).(domain.Household)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+	if !mem.CanManage() {
+		a.errorResponse(w, r, 403, domain.ErrForbidden)
+		return
+	}
+
+	if item.IsPersonal {
+		a.errorResponse(w, r, 400, domain.ErrPersonalHousehold)
+		return
+	}
+
+	reqBody := createHouseholdMemberRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	usr, err := a.userRepo.GetByEmail(ctx, reqBody.Email)
+	if err != nil {
+		status := 500
+		if err.Error() == domain.ErrNotFound.Error() {
+			status = 404
+		}
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	if _, err := a.householdMemberRepo.GetByHouseholdUser(ctx, item.ID, usr.ID); err == nil {
+		a.errorResponse(w, r, 400, domain.ErrAlreadyMember)
+		return
+	}
+
+	newMem := domain.HouseholdMember{
+		HouseholdID: item.ID,
+		UserID:      usr.ID,
+		Name:        usr.Name,
+		Email:       usr.Email,
+		Role:        reqBody.Role,
+	}
+
+	created, err := a.householdMemberRepo.Create(ctx, &newMem)
+	if err != nil {
+		a.logger.Error("failed to add household member", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(created)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) householdMemberUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(HouseholdCtx{}).(domain.Household)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+	if !mem.CanManage() {
+		a.errorResponse(w, r, 403, domain.ErrForbidden)
+		return
+	}
+
+	target, ok := ctx.Value(HouseholdMemberCtx{}).(domain.HouseholdMember)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	reqBody := updateHouseholdMemberRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	if target.Role == domain.HouseholdOwner && reqBody.Role != domain.HouseholdOwner {
+		owners, err := a.householdMemberRepo.CountOwners(ctx, item.ID)
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+		if owners <= 1 {
+			a.errorResponse(w, r, 400, domain.ErrLastOwner)
+			return
+		}
+	}
+
+	target.Role = reqBody.Role
+
+	upMem, err := a.householdMemberRepo.Update(ctx, &target)
+	if err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.householdMemberRepo.GetByHouseholdUser(ctx, item.ID, target.UserID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to update household member", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(upMem)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, upMem)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+// householdMemberDeleteHandler removes a member. Owners can remove anyone;
+// everybody else can only leave.
+func (a api) householdMemberDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(HouseholdCtx{}).(domain.Household)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	target, ok := ctx.Value(HouseholdMemberCtx{}).(domain.HouseholdMember)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	if !mem.CanManage() && mem.UserID != target.UserID {
+		a.errorResponse(w, r, 403, domain.ErrForbidden)
+		return
+	}
+
+	if item.IsPersonal {
+		a.errorResponse(w, r, 400, domain.ErrPersonalHousehold)
+		return
+	}
+
+	if target.Role == domain.HouseholdOwner {
+		owners, err := a.householdMemberRepo.CountOwners(ctx, item.ID)
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+		if owners <= 1 {
+			a.errorResponse(w, r, 400, domain.ErrLastOwner)
+			return
+		}
+	}
+
+	if err := a.householdMemberRepo.Delete(ctx, item.ID, target.UserID, target.Version); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.householdMemberRepo.GetByHouseholdUser(ctx, item.ID, target.UserID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to remove household member", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	data := map[string]string{
+		"message": "Member removed successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/util"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	idempotencyTTL       = 24 * time.Hour
+	// idempotencyLockTTL bounds how long a request that never finished (a
+	// dropped process) keeps its key locked. A running request keeps
+	// extending it, so slow handlers such as bulk edits stay locked.
+	idempotencyLockTTL = time.Minute
+)
+
+var (
+	errIdempotencyKeyReuse = errors.New("The Idempotency-Key was already used for a different request.")
+	errIdempotencyInFlight = errors.New("A request with this Idempotency-Key is still being processed.")
+)
+
+// idempotencyReplayHeaders are the response headers stored with a response
+// and sent again when it is replayed.
+var idempotencyReplayHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// idempotentResponse is kept in redis under the key. Status is zero while
+// the first request is still running.
+type idempotentResponse struct {
+	Hash   string            `json:"hash"`
+	Status int               `json:"status,omitempty"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   []byte            `json:"body,omitempty"`
+}
+
+// idempotencyKey scopes a client key to the signed in user, so two users
+// picking the same key never see each other's responses and a retry still
+// matches after the access token was refreshed.
+func idempotencyKey(sub uint, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "idempotency:" + strconv.FormatUint(uint64(sub), 10) + ":" + hex.EncodeToString(sum[:])
+}
+
+// idempotencyKeep reports whether a response is the outcome of the request
+// rather than of the moment it was sent. Server errors, rejected credentials,
+// permissions, stale versions and rate limits can all go away on a retry.
+func idempotencyKeep(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusPreconditionFailed, http.StatusTooManyRequests:
+		return false
+	}
+	return status < 500
+}
+
+// idempotencyHash identifies the request a key was first used for.
+func idempotencyHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\x00" + r.Header.Get(HouseholdHeader) + "\x00"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Idempotency makes POST requests carrying an Idempotency-Key safe to retry.
+// The first response for a key is kept for 24 hours and replayed for every
+// retry with the same body; reusing the key for a different body is a 422.
+// It goes after middlewares.Auth, as keys are kept per user. Routes used
+// before signing in (sign up, sign in, token refresh, email verification)
+// don't use it: their responses hold tokens that shouldn't be stored and
+// replayed.
+func (a api) Idempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+
+		sub, err := util.GetSub(ctx)
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			a.errorResponse(w, r, 422, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		redisKey := idempotencyKey(sub, key)
+		hash := idempotencyHash(r, body)
+
+		pending, err := json.Marshal(idempotentResponse{Hash: hash})
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+
+		first, err := a.redis.SetNX(ctx, redisKey, pending, idempotencyLockTTL).Result()
+		if err != nil {
+			a.logger.Error("failed to lock idempotency key", zap.Error(err))
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+
+		if !first {
+			a.idempotentReplay(w, r, redisKey, hash)
+			return
+		}
+
+		var buf bytes.Buffer
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&buf)
+
+		func() {
+			defer a.holdIdempotencyLock(redisKey)()
+			next.ServeHTTP(ww, r)
+		}()
+
+		// The outcome is stored even if the client has gone, since that
+		// client is the one that will retry.
+		ctx = context.WithoutCancel(ctx)
+
+		if !idempotencyKeep(ww.Status()) {
+			if err := a.redis.Del(ctx, redisKey).Err(); err != nil {
+				a.logger.Error("failed to release idempotency key", zap.Error(err))
+			}
+			return
+		}
+
+		res := idempotentResponse{
+			Hash:   hash,
+			Status: ww.Status(),
+			Header: map[string]string{},
+			Body:   buf.Bytes(),
+		}
+		for _, h := range idempotencyReplayHeaders {
+			if v := ww.Header().Get(h); v != "" {
+				res.Header[h] = v
+			}
+		}
+
+		resJSON, err := json.Marshal(res)
+		if err != nil {
+			a.logger.Error("failed to encode idempotent response", zap.Error(err))
+			return
+		}
+
+		if err := a.redis.Set(ctx, redisKey, resJSON, idempotencyTTL).Err(); err != nil {
+			a.logger.Error("failed to store idempotent response", zap.Error(err))
+		}
+	})
+}
+
+// holdIdempotencyLock extends the lock on redisKey until the returned func is
+// called, so a handler running past idempotencyLockTTL is not run twice.
+func (a api) holdIdempotencyLock(redisKey string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(idempotencyLockTTL / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := a.redis.Expire(context.Background(), redisKey, idempotencyLockTTL).Err(); err != nil {
+					a.logger.Error("failed to extend idempotency key lock", zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// idempotentReplay answers a retry with the stored response.
+func (a api) idempotentReplay(w http.ResponseWriter, r *http.Request, redisKey string, hash string) {
+	stored, err := a.redis.Get(r.Context(), redisKey).Bytes()
+	if err == redis.Nil {
+		// The first request failed and released the key in between.
+		a.errorResponse(w, r, 409, errIdempotencyInFlight)
+		return
+	}
+	if err != nil {
+		a.logger.Error("failed to read idempotency key", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	var res idempotentResponse
+	if err := json.Unmarshal(stored, &res); err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	if res.Hash != hash {
+		a.errorResponse(w, r, 422, errIdempotencyKeyReuse)
+		return
+	}
+
+	if res.Status == 0 {
+		a.errorResponse(w, r, 409, errIdempotencyInFlight)
+		return
+	}
+
+	for h, v := range res.Header {
+		w.Header().Set(h, v)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(res.Status)
+	w.Write(res.Body)
+}
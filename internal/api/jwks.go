@@ -0,0 +1,29 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Brix101/budgetto-backend/internal/keys"
+)
+
+// jwksHandler publishes the public access token keys so other services can
+// verify budgetto tokens.
+func (a api) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	manager, err := keys.Access()
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(manager.JWKS())
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
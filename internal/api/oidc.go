@@ -0,0 +1,344 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/oidc"
+	"github.com/Brix101/budgetto-backend/internal/util"
+)
+
+var errInvalidOIDCState = errors.New("The sign in request has expired. Please try again.")
+
+const oidcStateTTL = 10 * time.Minute
+
+// oidcState is kept in redis between the login redirect and the callback.
+type oidcState struct {
+	Provider string `json:"provider"`
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+	// UserID is set when a signed in user is linking the provider.
+	UserID *uint `json:"user_id,omitempty"`
+}
+
+func oidcStateKey(state string) string {
+	return "oidc:state:" + state
+}
+
+func (a api) oidcProviderListHandler(w http.ResponseWriter, r *http.Request) {
+	names := []string{}
+	for name := range a.oidcProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resJSON, err := json.Marshal(names)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	provider, ok := a.oidcProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		a.errorResponse(w, r, 404, domain.ErrNotFound)
+		return
+	}
+
+	authURL, err := a.oidcAuthURL(ctx, provider, nil)
+	if err != nil {
+		a.logger.Error("failed to start oidc sign in", zap.String("provider", provider.Name()), zap.Error(err))
+		a.errorResponse(w, r, 502, err)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// identityLinkHandler starts linking a provider to the signed in user. The
+// URL is returned rather than redirected to, since the request carries a
+// bearer token a browser navigation can't send.
+func (a api) identityLinkHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	sub, err := util.GetSub(ctx)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	provider, ok := a.oidcProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		a.errorResponse(w, r, 404, domain.ErrNotFound)
+		return
+	}
+
+	authURL, err := a.oidcAuthURL(ctx, provider, &sub)
+	if err != nil {
+		a.logger.Error("failed to start oidc link", zap.String("provider", provider.Name()), zap.Error(err))
+		a.errorResponse(w, r, 502, err)
+		return
+	}
+
+	data := map[string]string{
+		"url": authURL,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// oidcAuthURL stores a new state for the callback and returns the
+// provider's authorization URL.
+func (a api) oidcAuthURL(ctx context.Context, provider *oidc.Provider, userID *uint) (string, error) {
+	state, err := oidc.RandomString(24)
+	if err != nil {
+		return "", err
+	}
+
+	nonce, err := oidc.RandomString(24)
+	if err != nil {
+		return "", err
+	}
+
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, challenge)
+	if err != nil {
+		return "", err
+	}
+
+	stateJSON, err := json.Marshal(oidcState{
+		Provider: provider.Name(),
+		Verifier: verifier,
+		Nonce:    nonce,
+		UserID:   userID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := a.redis.Set(ctx, oidcStateKey(state), stateJSON, oidcStateTTL).Err(); err != nil {
+		return "", err
+	}
+
+	return authURL, nil
+}
+
+func (a api) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	provider, ok := a.oidcProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		a.errorResponse(w, r, 404, domain.ErrNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	if e := query.Get("error"); e != "" {
+		a.errorResponse(w, r, 401, errors.New(e))
+		return
+	}
+
+	stateJSON, err := a.redis.GetDel(ctx, oidcStateKey(query.Get("state"))).Bytes()
+	if err != nil {
+		a.errorResponse(w, r, 401, errInvalidOIDCState)
+		return
+	}
+
+	var state oidcState
+	if err := json.Unmarshal(stateJSON, &state); err != nil || state.Provider != provider.Name() {
+		a.errorResponse(w, r, 401, errInvalidOIDCState)
+		return
+	}
+
+	tok, err := provider.Exchange(ctx, query.Get("code"), state.Verifier)
+	if err != nil {
+		a.logger.Error("failed to exchange oidc code", zap.String("provider", provider.Name()), zap.Error(err))
+		a.errorResponse(w, r, 401, domain.ErrInvalidCredentials)
+		return
+	}
+
+	idToken, err := provider.VerifyIDToken(ctx, tok.IDToken, state.Nonce)
+	if err != nil {
+		a.logger.Error("failed to verify oidc id token", zap.String("provider", provider.Name()), zap.Error(err))
+		a.errorResponse(w, r, 401, domain.ErrInvalidCredentials)
+		return
+	}
+
+	var usr domain.User
+	if state.UserID != nil {
+		usr, err = a.linkIdentity(ctx, *state.UserID, provider.Name(), idToken)
+	} else {
+		usr, err = a.userForIdentity(ctx, provider.Name(), idToken)
+	}
+	if err != nil {
+		status := 500
+		switch err.Error() {
+		case domain.ErrEmailNotVerified.Error():
+			status = 401
+		case domain.ErrIdentityLinkRequired.Error(), domain.ErrIdentityTaken.Error():
+			status = 409
+		}
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	a.sessionResponse(w, r, usr)
+}
+
+// userForIdentity resolves the budgetto user behind an ID token. Known
+// identities map straight to their user; otherwise the identity is linked to
+// the user with the same email, creating that user if needed. An existing
+// user is only linked when both sides have verified the email; anyone else
+// has to link the provider from a signed in session.
+func (a api) userForIdentity(ctx context.Context, provider string, idToken *oidc.IDToken) (domain.User, error) {
+	idt, err := a.identityRepo.GetByProviderSubject(ctx, provider, idToken.Subject)
+	if err == nil {
+		return a.userRepo.GetByID(ctx, idt.UserID)
+	}
+	if err.Error() != domain.ErrNotFound.Error() {
+		return domain.User{}, err
+	}
+
+	if !idToken.Verified() {
+		return domain.User{}, domain.ErrEmailNotVerified
+	}
+
+	usr, err := a.userRepo.GetByEmail(ctx, idToken.Email)
+	if err == nil && usr.EmailVerifiedAt == nil {
+		return domain.User{}, domain.ErrIdentityLinkRequired
+	}
+	if err != nil {
+		if err.Error() != domain.ErrNotFound.Error() {
+			return domain.User{}, err
+		}
+
+		// Federated users still need a password hash; give them one nobody knows.
+		password, err := oidc.RandomString(32)
+		if err != nil {
+			return domain.User{}, err
+		}
+
+		// The provider vouched for the email.
+		verifiedAt := time.Now()
+		newUsr := domain.User{
+			Name:            idToken.Name,
+			Email:           idToken.Email,
+			EmailVerifiedAt: &verifiedAt,
+			Password:        password,
+		}
+		if newUsr.Name == "" {
+			newUsr.Name = idToken.Email
+		}
+		if idToken.Picture != "" {
+			newUsr.Image = &idToken.Picture
+		}
+
+		if err := newUsr.HashPassword(); err != nil {
+			return domain.User{}, err
+		}
+
+		created, err := a.userRepo.Create(ctx, &newUsr)
+		if err != nil {
+			return domain.User{}, err
+		}
+		if err := a.createPersonalHousehold(ctx, created); err != nil {
+			return domain.User{}, err
+		}
+		usr = *created
+	}
+
+	if _, err := a.identityRepo.Create(ctx, &domain.UserIdentity{
+		UserID:   usr.ID,
+		Provider: provider,
+		Subject:  idToken.Subject,
+		Email:    idToken.Email,
+	}); err != nil {
+		return domain.User{}, err
+	}
+
+	return usr, nil
+}
+
+// linkIdentity links the ID token's provider account to the signed in user
+// who started the flow. The user already proved who they are, so the emails
+// don't have to match.
+func (a api) linkIdentity(ctx context.Context, userID uint, provider string, idToken *oidc.IDToken) (domain.User, error) {
+	usr, err := a.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	idt, err := a.identityRepo.GetByProviderSubject(ctx, provider, idToken.Subject)
+	if err == nil {
+		if idt.UserID != usr.ID {
+			return domain.User{}, domain.ErrIdentityTaken
+		}
+		return usr, nil
+	}
+	if err.Error() != domain.ErrNotFound.Error() {
+		return domain.User{}, err
+	}
+
+	if _, err := a.identityRepo.Create(ctx, &domain.UserIdentity{
+		UserID:   usr.ID,
+		Provider: provider,
+		Subject:  idToken.Subject,
+		Email:    idToken.Email,
+	}); err != nil {
+		return domain.User{}, err
+	}
+
+	return usr, nil
+}
+
+func (a api) identityListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	sub, err := util.GetSub(ctx)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	idts, err := a.identityRepo.GetByUserSUB(ctx, sub)
+	if err != nil {
+		a.logger.Error("failed to fetch user identities from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(idts)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
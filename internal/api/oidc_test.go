@@ -0,0 +1,627 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/jwk"
+	"github.com/Brix101/budgetto-backend/internal/middlewares"
+	"github.com/Brix101/budgetto-backend/internal/oidc"
+)
+
+const (
+	mockClientID    = "budgetto"
+	mockRedirectURL = "https://budgetto.test/api/v1/auth/oidc/mock/callback"
+)
+
+func TestMain(m *testing.M) {
+	// Session tokens are signed with keys read from the environment.
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}))
+	os.Setenv("ACCESS_PRIVATE_KEY", encoded)
+	os.Setenv("REFRESH_PRIVATE_KEY", encoded)
+
+	os.Exit(m.Run())
+}
+
+// mockProvider is a minimal OpenID Connect provider: discovery, an
+// authorization endpoint that approves every request, a token endpoint that
+// checks the PKCE verifier, and its signing keys.
+type mockProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	// Claims of the ID tokens it issues.
+	subject       string
+	email         string
+	emailVerified bool
+
+	mu    sync.Mutex
+	codes map[string]url.Values
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &mockProvider{
+		key:           key,
+		subject:       "mock-subject",
+		email:         "jane@example.com",
+		emailVerified: true,
+		codes:         map[string]url.Values{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/jwks", p.jwks)
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+func (p *mockProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 p.URL,
+		"authorization_endpoint": p.URL + "/authorize",
+		"token_endpoint":         p.URL + "/token",
+		"jwks_uri":               p.URL + "/jwks",
+	})
+}
+
+func (p *mockProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	code, err := oidc.RandomString(16)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	p.mu.Lock()
+	p.codes[code] = q
+	p.mu.Unlock()
+
+	redirect := q.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+func (p *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	grant, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	if !ok ||
+		r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("client_id") != grant.Get("client_id") ||
+		r.PostForm.Get("redirect_uri") != grant.Get("redirect_uri") ||
+		oidc.S256Challenge(r.PostForm.Get("code_verifier")) != grant.Get("code_challenge") {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            p.URL,
+		"aud":            grant.Get("client_id"),
+		"sub":            p.subject,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          grant.Get("nonce"),
+		"name":           "Jane",
+		"email":          p.email,
+		"email_verified": p.emailVerified,
+	})
+	idToken.Header["kid"] = "mock"
+
+	raw, err := idToken.SignedString(p.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(oidc.Token{
+		AccessToken: "mock-access-token",
+		TokenType:   "Bearer",
+		IDToken:     raw,
+		ExpiresIn:   3600,
+	})
+}
+
+func (p *mockProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	key, err := jwk.FromPublicKey("mock", "RS256", &p.key.PublicKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(jwk.Set{Keys: []jwk.Key{key}})
+}
+
+// fakeRedis speaks just enough RESP for the oidc state: SET and GETDEL.
+func fakeRedis(t *testing.T) *redis.Client {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var mu sync.Mutex
+	store := map[string]string{}
+
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		rd := bufio.NewReader(conn)
+		for {
+			args, err := readCommand(rd)
+			if err != nil {
+				return
+			}
+
+			reply := "-ERR unknown command\r\n"
+			mu.Lock()
+			switch strings.ToUpper(args[0]) {
+			case "SET":
+				store[args[1]] = args[2]
+				reply = "+OK\r\n"
+			case "GETDEL":
+				if v, ok := store[args[1]]; ok {
+					delete(store, args[1])
+					reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+				} else {
+					reply = "$-1\r\n"
+				}
+			}
+			mu.Unlock()
+
+			if _, err := io.WriteString(conn, reply); err != nil {
+				return
+			}
+		}
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+
+	rdb := redis.NewClient(&redis.Options{Addr: ln.Addr().String()})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb
+}
+
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("bad command %q", line)
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+type fakeUsers struct {
+	domain.UserRepository
+	users []domain.User
+}
+
+func (f *fakeUsers) GetByID(ctx context.Context, id uint) (domain.User, error) {
+	for _, usr := range f.users {
+		if usr.ID == id {
+			return usr, nil
+		}
+	}
+	return domain.User{}, domain.ErrNotFound
+}
+
+func (f *fakeUsers) GetByEmail(ctx context.Context, email string) (domain.User, error) {
+	for _, usr := range f.users {
+		if usr.Email == email {
+			return usr, nil
+		}
+	}
+	return domain.User{}, domain.ErrNotFound
+}
+
+func (f *fakeUsers) Create(ctx context.Context, usr *domain.User) (*domain.User, error) {
+	usr.ID = uint(len(f.users) + 1)
+	usr.IsActive = true
+	f.users = append(f.users, *usr)
+	return usr, nil
+}
+
+type fakeIdentities struct {
+	domain.UserIdentityRepository
+	identities []domain.UserIdentity
+}
+
+func (f *fakeIdentities) GetByProviderSubject(ctx context.Context, provider string, subject string) (domain.UserIdentity, error) {
+	for _, idt := range f.identities {
+		if idt.Provider == provider && idt.Subject == subject {
+			return idt, nil
+		}
+	}
+	return domain.UserIdentity{}, domain.ErrNotFound
+}
+
+func (f *fakeIdentities) Create(ctx context.Context, idt *domain.UserIdentity) (*domain.UserIdentity, error) {
+	idt.ID = uint(len(f.identities) + 1)
+	f.identities = append(f.identities, *idt)
+	return idt, nil
+}
+
+type fakeHouseholds struct {
+	domain.HouseholdRepository
+}
+
+func (f *fakeHouseholds) Create(ctx context.Context, hh *domain.Household) (*domain.Household, error) {
+	return hh, nil
+}
+
+type oidcTest struct {
+	api        *api
+	provider   *mockProvider
+	users      *fakeUsers
+	identities *fakeIdentities
+	router     http.Handler
+}
+
+func newOIDCTest(t *testing.T) *oidcTest {
+	t.Helper()
+
+	provider := newMockProvider(t)
+	users := &fakeUsers{}
+	identities := &fakeIdentities{}
+
+	a := &api{
+		logger: zap.NewNop(),
+		redis:  fakeRedis(t),
+		oidcProviders: map[string]*oidc.Provider{
+			"mock": oidc.NewProvider(provider.Client(), oidc.Config{
+				Name:        "mock",
+				Issuer:      provider.URL,
+				ClientID:    mockClientID,
+				RedirectURL: mockRedirectURL,
+			}),
+		},
+		userRepo:      users,
+		identityRepo:  identities,
+		householdRepo: &fakeHouseholds{},
+	}
+
+	return &oidcTest{
+		api:        a,
+		provider:   provider,
+		users:      users,
+		identities: identities,
+		router:     a.AuthRoutes(),
+	}
+}
+
+// authorize follows an authorization URL through the mock provider and
+// returns the callback request it redirects back with.
+func (o *oidcTest) authorize(t *testing.T, authURL string) *http.Request {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("authorize returned %d", res.StatusCode)
+	}
+
+	callback, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(callback.String(), mockRedirectURL) {
+		t.Fatalf("provider redirected to %s", callback)
+	}
+
+	return httptest.NewRequest(http.MethodGet, "/oidc/mock/callback?"+callback.RawQuery, nil)
+}
+
+// signIn runs the whole login and callback flow and returns the callback's
+// response.
+func (o *oidcTest) signIn(t *testing.T) *httptest.ResponseRecorder {
+	t.Helper()
+
+	login := httptest.NewRecorder()
+	o.router.ServeHTTP(login, httptest.NewRequest(http.MethodGet, "/oidc/mock/login", nil))
+	if login.Code != http.StatusFound {
+		t.Fatalf("login returned %d: %s", login.Code, login.Body)
+	}
+
+	authURL := login.Header().Get("Location")
+	q, _ := url.Parse(authURL)
+	for _, param := range []string{"state", "nonce", "code_challenge"} {
+		if q.Query().Get(param) == "" {
+			t.Fatalf("authorization url has no %s: %s", param, authURL)
+		}
+	}
+
+	callback := httptest.NewRecorder()
+	o.router.ServeHTTP(callback, o.authorize(t, authURL))
+	return callback
+}
+
+func TestOIDCSignInCreatesUser(t *testing.T) {
+	o := newOIDCTest(t)
+
+	res := o.signIn(t)
+	if res.Code != http.StatusOK {
+		t.Fatalf("callback returned %d: %s", res.Code, res.Body)
+	}
+
+	var body domain.UserWithToken
+	if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.User.Email != o.provider.email || body.AccessToken == "" {
+		t.Fatalf("unexpected session %+v", body)
+	}
+	if !strings.Contains(res.Header().Get("Set-Cookie"), middlewares.BudgetttoCookieKey) {
+		t.Fatal("no refresh cookie set")
+	}
+
+	if len(o.users.users) != 1 || o.users.users[0].EmailVerifiedAt == nil {
+		t.Fatalf("expected one verified user, got %+v", o.users.users)
+	}
+	if len(o.identities.identities) != 1 || o.identities.identities[0].UserID != o.users.users[0].ID {
+		t.Fatalf("identity not linked: %+v", o.identities.identities)
+	}
+
+	// Signing in again finds the linked identity.
+	if res := o.signIn(t); res.Code != http.StatusOK {
+		t.Fatalf("second sign in returned %d: %s", res.Code, res.Body)
+	}
+	if len(o.users.users) != 1 || len(o.identities.identities) != 1 {
+		t.Fatalf("second sign in created records: %d users, %d identities", len(o.users.users), len(o.identities.identities))
+	}
+}
+
+func TestOIDCSignInLinksByVerifiedEmail(t *testing.T) {
+	verifiedAt := time.Now()
+
+	tests := []struct {
+		name          string
+		localVerified bool
+		idpVerified   bool
+		status        int
+		linked        bool
+	}{
+		{name: "both verified", localVerified: true, idpVerified: true, status: http.StatusOK, linked: true},
+		{name: "local email unverified", localVerified: false, idpVerified: true, status: http.StatusConflict},
+		{name: "provider email unverified", localVerified: true, idpVerified: false, status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newOIDCTest(t)
+			o.provider.emailVerified = tt.idpVerified
+
+			local := domain.User{Name: "Jane", Email: o.provider.email}
+			if tt.localVerified {
+				local.EmailVerifiedAt = &verifiedAt
+			}
+			o.users.Create(context.Background(), &local)
+
+			res := o.signIn(t)
+			if res.Code != tt.status {
+				t.Fatalf("callback returned %d, want %d: %s", res.Code, tt.status, res.Body)
+			}
+
+			if tt.linked != (len(o.identities.identities) == 1) {
+				t.Fatalf("linked identities: %+v", o.identities.identities)
+			}
+			if tt.linked && o.identities.identities[0].UserID != local.ID {
+				t.Fatalf("identity linked to user %d, want %d", o.identities.identities[0].UserID, local.ID)
+			}
+			if len(o.users.users) != 1 {
+				t.Fatalf("expected no new user, got %d", len(o.users.users))
+			}
+		})
+	}
+}
+
+func TestOIDCLinkFromSession(t *testing.T) {
+	o := newOIDCTest(t)
+
+	// An unverified local account with another email can still link the
+	// provider once signed in.
+	local := domain.User{Name: "Jane", Email: "jane@work.test"}
+	o.users.Create(context.Background(), &local)
+
+	accessToken, err := local.GenerateClaims()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/identities/mock", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	link := httptest.NewRecorder()
+	o.router.ServeHTTP(link, req)
+	if link.Code != http.StatusOK {
+		t.Fatalf("link returned %d: %s", link.Code, link.Body)
+	}
+
+	var body map[string]string
+	if err := json.Unmarshal(link.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	callback := httptest.NewRecorder()
+	o.router.ServeHTTP(callback, o.authorize(t, body["url"]))
+	if callback.Code != http.StatusOK {
+		t.Fatalf("callback returned %d: %s", callback.Code, callback.Body)
+	}
+
+	if len(o.identities.identities) != 1 || o.identities.identities[0].UserID != local.ID {
+		t.Fatalf("identity not linked to the signed in user: %+v", o.identities.identities)
+	}
+
+	// From now on the provider signs in as that user.
+	res := o.signIn(t)
+	if res.Code != http.StatusOK {
+		t.Fatalf("sign in returned %d: %s", res.Code, res.Body)
+	}
+	var session domain.UserWithToken
+	if err := json.Unmarshal(res.Body.Bytes(), &session); err != nil {
+		t.Fatal(err)
+	}
+	if session.User.ID != local.ID {
+		t.Fatalf("signed in as user %d, want %d", session.User.ID, local.ID)
+	}
+}
+
+func TestOIDCCallbackRejectsReusedState(t *testing.T) {
+	o := newOIDCTest(t)
+
+	login := httptest.NewRecorder()
+	o.router.ServeHTTP(login, httptest.NewRequest(http.MethodGet, "/oidc/mock/login", nil))
+	callback := o.authorize(t, login.Header().Get("Location"))
+
+	first := httptest.NewRecorder()
+	o.router.ServeHTTP(first, callback)
+	if first.Code != http.StatusOK {
+		t.Fatalf("callback returned %d: %s", first.Code, first.Body)
+	}
+
+	replay := httptest.NewRecorder()
+	o.router.ServeHTTP(replay, httptest.NewRequest(http.MethodGet, callback.URL.String(), nil))
+	if replay.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed callback returned %d, want 401", replay.Code)
+	}
+}
+
+type fakeTokens struct {
+	domain.PersonalAccessTokenRepository
+	tokens []domain.PersonalAccessToken
+}
+
+func (f *fakeTokens) GetByHash(ctx context.Context, hash string) (domain.PersonalAccessToken, error) {
+	for _, tok := range f.tokens {
+		if tok.TokenHash == hash {
+			return tok, nil
+		}
+	}
+	return domain.PersonalAccessToken{}, domain.ErrNotFound
+}
+
+func (f *fakeTokens) Touch(ctx context.Context, id uint) error {
+	return nil
+}
+
+func TestOIDCLinkRejectsPersonalAccessToken(t *testing.T) {
+	o := newOIDCTest(t)
+
+	local := domain.User{Name: "Jane", Email: "jane@work.test"}
+	o.users.Create(context.Background(), &local)
+
+	tokens := &fakeTokens{}
+	o.api.tokenRepo = tokens
+	middlewares.RegisterTokenVerifier(domain.TokenPrefix, o.api.verifyPersonalAccessToken)
+
+	pat := domain.TokenPrefix + "read-only-accounts"
+	tokens.tokens = append(tokens.tokens, domain.PersonalAccessToken{
+		Base:      domain.Base{ID: 1},
+		Scopes:    []string{"accounts:read"},
+		CreatedBy: local.ID,
+		TokenHash: domain.HashToken(pat),
+	})
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodPost, "/identities/mock"},
+		{http.MethodGet, "/identities"},
+		{http.MethodGet, "/me"},
+	} {
+		req := httptest.NewRequest(route.method, route.path, nil)
+		req.Header.Set("Authorization", "Bearer "+pat)
+		res := httptest.NewRecorder()
+		o.router.ServeHTTP(res, req)
+		if res.Code != http.StatusForbidden {
+			t.Fatalf("%s %s with a personal access token returned %d, want 403: %s", route.method, route.path, res.Code, res.Body)
+		}
+	}
+
+	if len(o.identities.identities) != 0 {
+		t.Fatalf("identity linked with a personal access token: %+v", o.identities.identities)
+	}
+}
@@ -0,0 +1,555 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/middlewares"
+)
+
+type PayeeCtx struct{}
+
+// payeeSearchLimit caps the number of autocomplete suggestions.
+const payeeSearchLimit = 10
+
+func (a api) PayeeRoutes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middlewares.Auth)
+	r.Use(middlewares.Scope("payees"))
+	r.Use(a.Idempotency)
+
+	r.Group(func(r chi.Router) {
+		r.Use(a.MemberCtx)
+
+		r.Get("/", a.payeeListHandler)
+		r.Post("/", a.payeeCreateHandler)
+		r.Get("/autocomplete", a.payeeAutocompleteHandler)
+	})
+
+	r.Route("/{id}", func(r chi.Router) {
+		r.Use(a.PayeeCtx)
+
+		r.Get("/", a.payeeGetHandler)
+		r.With(a.IfMatch(PayeeCtx{})).Put("/", a.payeeUpdateHandler)
+		r.With(a.IfMatch(PayeeCtx{})).Delete("/", a.payeeDeleteHandler)
+		r.Get("/history", a.payeeHistoryHandler)
+		r.Post("/aliases", a.payeeAliasCreateHandler)
+		r.With(a.IfMatch(PayeeCtx{})).Delete("/aliases/{alias}", a.payeeAliasDeleteHandler)
+		r.Post("/merge", a.payeeMergeHandler)
+	})
+
+	return r
+}
+
+func (a api) PayeeCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+
+		item, err := a.payeeRepo.GetByID(ctx, uint(id))
+		if err != nil {
+			status := 500
+			if err.Error() == domain.ErrNotFound.Error() {
+				status = 404
+			}
+			a.errorResponse(w, r, status, err)
+			return
+		}
+
+		if _, ok := a.authorizeMember(w, r, item.HouseholdID); !ok {
+			return
+		}
+
+		ctx = context.WithValue(ctx, PayeeCtx{}, item)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// householdPayee loads a payee of householdID.
+func (a api) householdPayee(ctx context.Context, id uint, householdID uint) (domain.Payee, error) {
+	payee, err := a.payeeRepo.GetByID(ctx, id)
+	if err != nil {
+		return domain.Payee{}, err
+	}
+
+	if payee.HouseholdID != householdID {
+		return domain.Payee{}, domain.ErrForbidden
+	}
+	return payee, nil
+}
+
+// resolvePayee loads the payee a transaction refers to, by id or by name,
+// returning nil when it refers to none. Names match existing payees and
+// aliases first; unknown names create a new payee.
+func (a api) resolvePayee(ctx context.Context, mem domain.HouseholdMember, id *uint, name string) (*domain.Payee, error) {
+	if id != nil {
+		payee, err := a.householdPayee(ctx, *id, mem.HouseholdID)
+		if err != nil {
+			return nil, err
+		}
+		return &payee, nil
+	}
+
+	if domain.NormalizePayee(name) == "" {
+		return nil, nil
+	}
+
+	payee, err := a.payeeRepo.GetByName(ctx, mem.HouseholdID, name)
+	if err == nil {
+		return &payee, nil
+	}
+	if err.Error() != domain.ErrNotFound.Error() {
+		return nil, err
+	}
+
+	return a.payeeRepo.Create(ctx, &domain.Payee{
+		HouseholdID: mem.HouseholdID,
+		Name:        strings.TrimSpace(name),
+		CreatedBy:   mem.UserID,
+	})
+}
+
+// payeeNameFree answers 400 when name already resolves to a payee of
+// householdID other than except.
+func (a api) payeeNameFree(w http.ResponseWriter, r *http.Request, householdID uint, name string, except uint) bool {
+	payee, err := a.payeeRepo.GetByName(r.Context(), householdID, name)
+	if err == nil && payee.ID != except {
+		a.errorResponse(w, r, 400, domain.ErrPayeeExists)
+		return false
+	}
+	if err != nil && err.Error() != domain.ErrNotFound.Error() {
+		a.errorResponse(w, r, 500, err)
+		return false
+	}
+	return true
+}
+
+type createPayeeRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type createPayeeAliasRequest struct {
+	Alias string `json:"alias" validate:"required"`
+}
+
+type mergePayeeRequest struct {
+	IntoID uint `json:"into_id" validate:"required"`
+}
+
+func (a api) payeeListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	payees, err := a.payeeRepo.GetByHouseholdID(ctx, mem.HouseholdID)
+	if err != nil {
+		a.logger.Error("failed to fetch payees from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(payees)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) payeeCreateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	reqBody := createPayeeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	reqBody.Name = strings.TrimSpace(reqBody.Name)
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	if !a.payeeNameFree(w, r, mem.HouseholdID, reqBody.Name, 0) {
+		return
+	}
+
+	newPayee := domain.Payee{
+		HouseholdID: mem.HouseholdID,
+		Name:        reqBody.Name,
+		CreatedBy:   mem.UserID,
+	}
+
+	payee, err := a.payeeRepo.Create(ctx, &newPayee)
+	if err != nil {
+		a.logger.Error("failed to create payee", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(payee)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, payee)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+// payeeAutocompleteHandler suggests payees whose name or an alias starts
+// with ?q, most used first.
+func (a api) payeeAutocompleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > payeeSearchLimit {
+		limit = payeeSearchLimit
+	}
+
+	payees, err := a.payeeRepo.Search(ctx, mem.HouseholdID, r.URL.Query().Get("q"), limit)
+	if err != nil {
+		a.logger.Error("failed to search payees", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(payees)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) payeeGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(PayeeCtx{}).(domain.Payee)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	resJSON, err := json.Marshal(item)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, item)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+// payeeUpdateHandler renames a payee.
+func (a api) payeeUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(PayeeCtx{}).(domain.Payee)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	reqBody := createPayeeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	reqBody.Name = strings.TrimSpace(reqBody.Name)
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	if !a.payeeNameFree(w, r, item.HouseholdID, reqBody.Name, item.ID) {
+		return
+	}
+
+	item.Name = reqBody.Name
+
+	payee, err := a.payeeRepo.Update(ctx, &item)
+	if err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.payeeRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to update payee", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(payee)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, payee)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) payeeDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(PayeeCtx{}).(domain.Payee)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := a.payeeRepo.Delete(ctx, item.ID, item.Version); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.payeeRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to delete payee", zap.Error(err))
+		status := 500
+		if err.Error() == domain.ErrNotFound.Error() {
+			status = 404
+		}
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	data := map[string]string{
+		"message": "Item deleted successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// payeeHistoryHandler returns the monthly totals and the transactions of a
+// payee.
+func (a api) payeeHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(PayeeCtx{}).(domain.Payee)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	months, err := a.payeeRepo.GetHistory(ctx, item.ID)
+	if err != nil {
+		a.logger.Error("failed to fetch payee history from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	trns, err := a.transactionRepo.GetByHouseholdID(ctx, item.HouseholdID, domain.TransactionFilter{PayeeID: &item.ID})
+	if err != nil {
+		a.logger.Error("failed to fetch transactions from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	data := map[string]interface{}{
+		"payee":        item,
+		"months":       months,
+		"transactions": trns,
+	}
+
+	resJSON, err := json.Marshal(data)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) payeeAliasCreateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(PayeeCtx{}).(domain.Payee)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	reqBody := createPayeeAliasRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	reqBody.Alias = domain.NormalizePayee(reqBody.Alias)
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	if !a.payeeNameFree(w, r, item.HouseholdID, reqBody.Alias, 0) {
+		return
+	}
+
+	if err := a.payeeRepo.AddAlias(ctx, &item, reqBody.Alias); err != nil {
+		a.logger.Error("failed to add payee alias", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	payee, err := a.payeeRepo.GetByID(ctx, item.ID)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(payee)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, payee)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) payeeAliasDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(PayeeCtx{}).(domain.Payee)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := a.payeeRepo.RemoveAlias(ctx, item.ID, item.Version, chi.URLParam(r, "alias")); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.payeeRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to delete payee alias", zap.Error(err))
+		status := 500
+		if err.Error() == domain.ErrNotFound.Error() {
+			status = 404
+		}
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	data := map[string]string{
+		"message": "Item deleted successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// payeeMergeHandler folds a duplicate payee into into_id.
+func (a api) payeeMergeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(PayeeCtx{}).(domain.Payee)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	reqBody := mergePayeeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	if reqBody.IntoID == item.ID {
+		a.errorResponse(w, r, 400, domain.ErrPayeeMergeSelf)
+		return
+	}
+
+	if _, err := a.householdPayee(ctx, reqBody.IntoID, item.HouseholdID); err != nil {
+		a.errorResponse(w, r, referenceStatus(err), err)
+		return
+	}
+
+	if err := a.payeeRepo.Merge(ctx, item.ID, reqBody.IntoID); err != nil {
+		a.logger.Error("failed to merge payee", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	payee, err := a.payeeRepo.GetByID(ctx, reqBody.IntoID)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(payee)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
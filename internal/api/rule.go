@@ -0,0 +1,406 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/middlewares"
+)
+
+type RuleCtx struct{}
+
+func (a api) RuleRoutes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middlewares.Auth)
+	r.Use(middlewares.Scope("rules"))
+	r.Use(a.Idempotency)
+
+	r.Group(func(r chi.Router) {
+		r.Use(a.MemberCtx)
+
+		r.Get("/", a.ruleListHandler)
+		r.Post("/", a.ruleCreateHandler)
+	})
+
+	r.Route("/{id}", func(r chi.Router) {
+		r.Use(a.RuleCtx)
+
+		r.Get("/", a.ruleGetHandler)
+		r.With(a.IfMatch(RuleCtx{})).Put("/", a.ruleUpdateHandler)
+		r.With(a.IfMatch(RuleCtx{})).Delete("/", a.ruleDeleteHandler)
+		r.Get("/preview", a.rulePreviewHandler)
+		r.Post("/apply", a.ruleApplyHandler)
+	})
+
+	return r
+}
+
+func (a api) RuleCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+
+		item, err := a.ruleRepo.GetByID(ctx, uint(id))
+		if err != nil {
+			status := 500
+			if err.Error() == domain.ErrNotFound.Error() {
+				status = 404
+			}
+			a.errorResponse(w, r, status, err)
+			return
+		}
+
+		if _, ok := a.authorizeMember(w, r, item.HouseholdID); !ok {
+			return
+		}
+
+		ctx = context.WithValue(ctx, RuleCtx{}, item)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type createRuleRequest struct {
+	Name       string                `json:"name" validate:"required"`
+	Priority   int                   `json:"priority"`
+	IsActive   *bool                 `json:"is_active,omitempty"`
+	Conditions domain.RuleConditions `json:"conditions"`
+	Actions    domain.RuleActions    `json:"actions"`
+}
+
+// applyRules runs the household's rules over a transaction that is about to
+// be created. Every way of adding transactions goes through it.
+func (a api) applyRules(ctx context.Context, trn *domain.Transaction) error {
+	rules, err := a.ruleRepo.GetByHouseholdID(ctx, trn.HouseholdID)
+	if err != nil {
+		return err
+	}
+
+	domain.ApplyRules(rules, trn)
+	return nil
+}
+
+// ruleChanges works out what rule would change in the existing transactions
+// of its household, whether or not it is active.
+func (a api) ruleChanges(ctx context.Context, rule domain.Rule) ([]domain.RuleChange, error) {
+	trns, err := a.transactionRepo.GetByHouseholdID(ctx, rule.HouseholdID, domain.TransactionFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	rule.IsActive = true
+	changes := []domain.RuleChange{}
+	for _, trn := range trns {
+		after := trn
+		fields := domain.ApplyRules([]domain.Rule{rule}, &after)
+		if len(fields) == 0 {
+			continue
+		}
+		changes = append(changes, domain.RuleChange{
+			TransactionID: trn.ID,
+			Fields:        fields,
+			Before:        trn,
+			After:         after,
+		})
+	}
+	return changes, nil
+}
+
+// checkRule validates the request and the household references in it.
+func (a api) checkRule(w http.ResponseWriter, r *http.Request, householdID uint, reqBody createRuleRequest) bool {
+	ctx := r.Context()
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return false
+	}
+
+	rule := domain.Rule{Conditions: reqBody.Conditions, Actions: reqBody.Actions}
+	if err := rule.Check(); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return false
+	}
+
+	var err error
+	if id := reqBody.Conditions.AccountID; id != nil {
+		_, err = a.householdAccount(ctx, *id, householdID)
+	}
+	if id := reqBody.Actions.CategoryID; err == nil && id != nil {
+		_, err = a.householdCategory(ctx, *id, householdID)
+	}
+	if id := reqBody.Actions.PayeeID; err == nil && id != nil {
+		_, err = a.householdPayee(ctx, *id, householdID)
+	}
+	if err != nil {
+		a.errorResponse(w, r, referenceStatus(err), err)
+		return false
+	}
+
+	return true
+}
+
+func (a api) ruleListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	rules, err := a.ruleRepo.GetByHouseholdID(ctx, mem.HouseholdID)
+	if err != nil {
+		a.logger.Error("failed to fetch rules from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(rules)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) ruleCreateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	reqBody := createRuleRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	if !a.checkRule(w, r, mem.HouseholdID, reqBody) {
+		return
+	}
+
+	newRule := domain.Rule{
+		HouseholdID: mem.HouseholdID,
+		Name:        reqBody.Name,
+		Priority:    reqBody.Priority,
+		IsActive:    reqBody.IsActive == nil || *reqBody.IsActive,
+		Conditions:  reqBody.Conditions,
+		Actions:     reqBody.Actions,
+		CreatedBy:   mem.UserID,
+	}
+	newRule.Actions.Tags = domain.NormalizeTags(newRule.Actions.Tags)
+
+	rule, err := a.ruleRepo.Create(ctx, &newRule)
+	if err != nil {
+		a.logger.Error("failed to create rule", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(rule)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, rule)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) ruleGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(RuleCtx{}).(domain.Rule)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	resJSON, err := json.Marshal(item)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, item)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) ruleUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(RuleCtx{}).(domain.Rule)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	reqBody := createRuleRequest{
+		Name:       item.Name,
+		Priority:   item.Priority,
+		IsActive:   &item.IsActive,
+		Conditions: item.Conditions,
+		Actions:    item.Actions,
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	if !a.checkRule(w, r, item.HouseholdID, reqBody) {
+		return
+	}
+
+	item.Name = reqBody.Name
+	item.Priority = reqBody.Priority
+	item.IsActive = reqBody.IsActive == nil || *reqBody.IsActive
+	item.Conditions = reqBody.Conditions
+	item.Actions = reqBody.Actions
+	item.Actions.Tags = domain.NormalizeTags(item.Actions.Tags)
+
+	rule, err := a.ruleRepo.Update(ctx, &item)
+	if err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.ruleRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to update rule", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(rule)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, rule)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) ruleDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(RuleCtx{}).(domain.Rule)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := a.ruleRepo.Delete(ctx, item.ID, item.Version); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.ruleRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to delete rule", zap.Error(err))
+		status := 500
+		if err.Error() == domain.ErrNotFound.Error() {
+			status = 404
+		}
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	data := map[string]string{
+		"message": "Item deleted successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// rulePreviewHandler is a dry run: it lists the existing transactions the
+// rule would change without saving anything.
+func (a api) rulePreviewHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(RuleCtx{}).(domain.Rule)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	changes, err := a.ruleChanges(ctx, item)
+	if err != nil {
+		a.logger.Error("failed to preview rule", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(changes)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+// ruleApplyHandler applies the rule to the household's existing
+// transactions and returns what changed.
+func (a api) ruleApplyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(RuleCtx{}).(domain.Rule)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	changes, err := a.ruleChanges(ctx, item)
+	if err != nil {
+		a.logger.Error("failed to apply rule", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	for i := range changes {
+		if _, err := a.transactionRepo.Update(ctx, &changes[i].After); err != nil {
+			a.logger.Error("failed to apply rule", zap.Error(err))
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+	}
+
+	resJSON, err := json.Marshal(changes)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
@@ -0,0 +1,493 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/middlewares"
+	"github.com/Brix101/budgetto-backend/internal/password"
+)
+
+// SharePasscodeHeader carries the passcode for protected share links, so it
+// stays out of URLs and access logs.
+const SharePasscodeHeader = "X-Share-Passcode"
+
+// shareLinkExp is used when a share link is created without expires_in_days.
+const shareLinkExp = 7
+
+// sharedPath is where SharedRoutes is mounted.
+const sharedPath = "/api/v1/shared/"
+
+var (
+	errShareResourceID = errors.New("resource_id is required for account_transactions links.")
+	errDateRange       = errors.New("from and to must be dates (YYYY-MM-DD) with from on or before to.")
+)
+
+type ShareCtx struct{}
+
+func (a api) ShareRoutes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middlewares.Auth)
+	// "shares" is never a grantable scope, so only session tokens can create
+	// or revoke share links.
+	r.Use(middlewares.Scope("shares"))
+	r.Use(a.Idempotency)
+
+	r.Group(func(r chi.Router) {
+		r.Use(a.MemberCtx)
+
+		r.Get("/", a.shareListHandler)
+		r.Post("/", a.shareCreateHandler)
+	})
+
+	r.Route("/{id}", func(r chi.Router) {
+		r.Use(a.ShareCtx)
+
+		r.Get("/", a.shareGetHandler)
+		r.With(a.IfMatch(ShareCtx{})).Delete("/", a.shareRevokeHandler)
+		r.Get("/accesses", a.shareAccessListHandler)
+	})
+
+	return r
+}
+
+// SharedRoutes serves share links to anyone holding the token; it is
+// deliberately outside middlewares.Auth.
+func (a api) SharedRoutes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/{token}", a.sharedGetHandler)
+
+	return r
+}
+
+// redactShareToken hides share link tokens from the access log, which prints
+// the request URI. Routing uses the URL path, so it is left as is.
+func redactShareToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.RequestURI, sharedPath) {
+			r = r.WithContext(r.Context())
+			r.RequestURI = sharedPath + "[redacted]"
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a api) ShareCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+
+		item, err := a.shareLinkRepo.GetByID(ctx, uint(id))
+		if err != nil {
+			status := 500
+			if err.Error() == domain.ErrNotFound.Error() {
+				status = 404
+			}
+			a.errorResponse(w, r, status, err)
+			return
+		}
+
+		if _, ok := a.authorizeMember(w, r, item.HouseholdID); !ok {
+			return
+		}
+
+		ctx = context.WithValue(ctx, ShareCtx{}, item)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type createShareRequest struct {
+	Resource   string `json:"resource" validate:"required,oneof=budgets category_report account_transactions"`
+	ResourceID *uint  `json:"resource_id,omitempty"`
+	From       string `json:"from,omitempty"`
+	To         string `json:"to,omitempty"`
+	Passcode   string `json:"passcode,omitempty" validate:"omitempty,min=4"`
+	ExpiresIn  *int   `json:"expires_in_days,omitempty" validate:"omitempty,gte=1,lte=365"`
+}
+
+type sharedResponse struct {
+	Resource  string      `json:"resource"`
+	StartsAt  *time.Time  `json:"starts_at,omitempty"`
+	EndsAt    *time.Time  `json:"ends_at,omitempty"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	Data      interface{} `json:"data"`
+}
+
+func (a api) shareListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	links, err := a.shareLinkRepo.GetByHouseholdID(ctx, mem.HouseholdID)
+	if err != nil {
+		a.logger.Error("failed to fetch share links from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(links)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) shareCreateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	reqBody := createShareRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	expiresIn := shareLinkExp
+	if reqBody.ExpiresIn != nil {
+		expiresIn = *reqBody.ExpiresIn
+	}
+
+	newLink := domain.ShareLink{
+		HouseholdID: mem.HouseholdID,
+		Resource:    reqBody.Resource,
+		ExpiresAt:   time.Now().AddDate(0, 0, expiresIn),
+		CreatedBy:   mem.UserID,
+	}
+
+	switch reqBody.Resource {
+	case domain.ShareAccountTransactions:
+		if reqBody.ResourceID == nil {
+			a.errorResponse(w, r, 400, errShareResourceID)
+			return
+		}
+		if _, err := a.householdAccount(ctx, *reqBody.ResourceID, mem.HouseholdID); err != nil {
+			a.errorResponse(w, r, referenceStatus(err), err)
+			return
+		}
+		newLink.ResourceID = reqBody.ResourceID
+
+	case domain.ShareCategoryReport:
+		from, to, err := parseDateRange(reqBody.From, reqBody.To)
+		if err != nil {
+			a.errorResponse(w, r, 400, err)
+			return
+		}
+		newLink.StartsAt = &from
+		newLink.EndsAt = &to
+	}
+
+	if reqBody.Passcode != "" {
+		hash, err := password.Default().Hash(reqBody.Passcode)
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+		newLink.PasscodeHash = &hash
+	}
+
+	if err := newLink.GenerateToken(); err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	link, err := a.shareLinkRepo.Create(ctx, &newLink)
+	if err != nil {
+		a.logger.Error("failed to create share link", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(link)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) shareGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(ShareCtx{}).(domain.ShareLink)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	resJSON, err := json.Marshal(item)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, item)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) shareRevokeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(ShareCtx{}).(domain.ShareLink)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	if item.RevokedAt != nil {
+		a.errorResponse(w, r, 404, domain.ErrNotFound)
+		return
+	}
+
+	if err := a.shareLinkRepo.Revoke(ctx, item.ID, item.Version); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.shareLinkRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to revoke share link", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	data := map[string]string{
+		"message": "Share link revoked successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func (a api) shareAccessListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(ShareCtx{}).(domain.ShareLink)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	accs, err := a.shareAccessRepo.GetByShareLinkID(ctx, item.ID)
+	if err != nil {
+		a.logger.Error("failed to fetch share link accesses from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(accs)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+// parseDateRange parses an inclusive YYYY-MM-DD range and returns it as
+// [from, to) so the whole of the last day is included.
+func parseDateRange(from string, to string) (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return time.Time{}, time.Time{}, errDateRange
+	}
+
+	end, err := time.Parse("2006-01-02", to)
+	if err != nil || end.Before(start) {
+		return time.Time{}, time.Time{}, errDateRange
+	}
+
+	return start, end.AddDate(0, 0, 1), nil
+}
+
+// logShareAccess records an attempt to open a share link. Failures are only
+// logged so they never block the response.
+func (a api) logShareAccess(ctx context.Context, r *http.Request, link domain.ShareLink, reason string) {
+	if _, err := a.shareAccessRepo.Create(ctx, &domain.ShareAccess{
+		ShareLinkID: link.ID,
+		IP:          clientIP(r),
+		UserAgent:   r.UserAgent(),
+		Granted:     reason == domain.ShareGranted,
+		Reason:      reason,
+	}); err != nil {
+		a.logger.Error("failed to record share link access", zap.Error(err))
+	}
+}
+
+// shareData loads the read-only payload a link exposes.
+func (a api) shareData(ctx context.Context, link domain.ShareLink) (interface{}, error) {
+	switch link.Resource {
+	case domain.ShareBudgets:
+		return a.budgetRepo.GetByHouseholdID(ctx, link.HouseholdID)
+	case domain.ShareCategoryReport:
+		return a.transactionRepo.GetCategoryTotals(ctx, link.HouseholdID, *link.StartsAt, *link.EndsAt)
+	case domain.ShareAccountTransactions:
+		// The account may have moved or been deleted since the link was made.
+		if _, err := a.householdAccount(ctx, *link.ResourceID, link.HouseholdID); err != nil {
+			return nil, err
+		}
+		return a.transactionRepo.GetByAccountID(ctx, *link.ResourceID)
+	}
+	return nil, domain.ErrNotFound
+}
+
+// checkSharePasscode verifies the passcode sent for link, throttling wrong
+// ones per IP and per link. It answers the request itself when the passcode
+// is missing, wrong or the caller has to back off.
+func (a api) checkSharePasscode(w http.ResponseWriter, r *http.Request, link domain.ShareLink) bool {
+	ctx := r.Context()
+	ip := clientIP(r)
+	subject := strconv.Itoa(int(link.ID))
+
+	wait, locked, err := a.shareLimiter.Check(ctx, ip, subject)
+	if err != nil {
+		a.logger.Error("failed to check share passcode throttle", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return false
+	}
+	if wait > 0 {
+		a.logShareAccess(ctx, r, link, domain.ShareThrottled)
+		a.sharePasscodeThrottled(w, r, wait, locked)
+		return false
+	}
+
+	passcode := r.Header.Get(SharePasscodeHeader)
+	if passcode == "" {
+		a.logShareAccess(ctx, r, link, domain.SharePasscodeMissing)
+		a.errorResponse(w, r, 401, domain.ErrPasscodeRequired)
+		return false
+	}
+
+	ok, err := password.Default().Verify(passcode, *link.PasscodeHash)
+	if err != nil || !ok {
+		a.logShareAccess(ctx, r, link, domain.SharePasscodeWrong)
+
+		wait, locked, err := a.shareLimiter.Fail(ctx, ip, subject)
+		if err != nil {
+			a.logger.Error("failed to record share passcode failure", zap.Error(err))
+		}
+		if wait > 0 {
+			a.sharePasscodeThrottled(w, r, wait, locked)
+			return false
+		}
+		a.errorResponse(w, r, 401, domain.ErrWrongPasscode)
+		return false
+	}
+
+	if err := a.shareLimiter.Reset(ctx, subject); err != nil {
+		a.logger.Error("failed to reset share passcode throttle", zap.Error(err))
+	}
+	return true
+}
+
+func (a api) sharePasscodeThrottled(w http.ResponseWriter, r *http.Request, wait time.Duration, locked bool) {
+	if locked {
+		a.tooManyRequests(w, r, wait, domain.ErrPasscodeLocked)
+		return
+	}
+	a.tooManyRequests(w, r, wait, domain.ErrTooManyPasscodes)
+}
+
+func (a api) sharedGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	token := chi.URLParam(r, "token")
+	if !domain.VerifyShareToken(token) {
+		a.errorResponse(w, r, 404, domain.ErrNotFound)
+		return
+	}
+
+	link, err := a.shareLinkRepo.GetByHash(ctx, domain.HashToken(token))
+	if err != nil {
+		status := 500
+		if err.Error() == domain.ErrNotFound.Error() {
+			status = 404
+		}
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	if err := link.Valid(); err != nil {
+		reason := domain.ShareExpired
+		if err.Error() == domain.ErrShareRevoked.Error() {
+			reason = domain.ShareRevoked
+		}
+		a.logShareAccess(ctx, r, link, reason)
+		a.errorResponse(w, r, 410, err)
+		return
+	}
+
+	if link.PasscodeHash != nil && !a.checkSharePasscode(w, r, link) {
+		return
+	}
+
+	data, err := a.shareData(ctx, link)
+	if err != nil {
+		a.logger.Error("failed to load shared resource", zap.Error(err))
+		a.errorResponse(w, r, referenceStatus(err), err)
+		return
+	}
+
+	a.logShareAccess(ctx, r, link, domain.ShareGranted)
+	if err := a.shareLinkRepo.Touch(ctx, link.ID); err != nil {
+		a.logger.Error("failed to track share link usage", zap.Error(err))
+	}
+
+	resJSON, err := json.Marshal(sharedResponse{
+		Resource:  link.Resource,
+		StartsAt:  link.StartsAt,
+		EndsAt:    link.EndsAt,
+		ExpiresAt: link.ExpiresAt,
+		Data:      data,
+	})
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
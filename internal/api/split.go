@@ -0,0 +1,385 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/middlewares"
+	"github.com/Brix101/budgetto-backend/internal/util"
+)
+
+func (a api) SplitRoutes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middlewares.Auth)
+	r.Use(middlewares.Scope("splits"))
+	r.Use(a.Idempotency)
+	r.Use(a.MemberCtx)
+
+	r.Get("/balances", a.splitBalanceHandler)
+	r.Get("/settlements", a.settlementListHandler)
+	r.Post("/settlements", a.settlementCreateHandler)
+
+	return r
+}
+
+type splitShareRequest struct {
+	ContactID *uint   `json:"contact_id,omitempty"`
+	Value     float64 `json:"value"`
+}
+
+type saveSplitRequest struct {
+	Method string              `json:"method" validate:"required,oneof=amount percentage equal"`
+	PaidBy *uint               `json:"paid_by,omitempty"`
+	Shares []splitShareRequest `json:"shares" validate:"required,min=1"`
+}
+
+type createSettlementRequest struct {
+	FromContactID *uint   `json:"from_contact_id,omitempty"`
+	ToContactID   *uint   `json:"to_contact_id,omitempty"`
+	Amount        float64 `json:"amount" validate:"gt=0"`
+	Note          string  `json:"note,omitempty"`
+	// AccountID and CategoryID are required when the household pays or
+	// receives the settlement.
+	AccountID  uint `json:"account_id,omitempty"`
+	CategoryID uint `json:"category_id,omitempty"`
+}
+
+// checkContacts makes sure every non nil contact id belongs to householdID.
+func (a api) checkContacts(ctx context.Context, householdID uint, ids ...*uint) error {
+	for _, id := range ids {
+		if id == nil {
+			continue
+		}
+		if _, err := a.householdContact(ctx, *id, householdID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a api) splitGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(TransactionCtx{}).(domain.Transaction)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	split, err := a.splitRepo.GetByTransactionID(ctx, item.ID)
+	if err != nil {
+		status := 500
+		if err.Error() == domain.ErrNotFound.Error() {
+			status = 404
+		}
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(split)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, item)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) splitSaveHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(TransactionCtx{}).(domain.Transaction)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	reqBody := saveSplitRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	ids := []*uint{reqBody.PaidBy}
+	for _, sh := range reqBody.Shares {
+		ids = append(ids, sh.ContactID)
+	}
+	if err := a.checkContacts(ctx, item.HouseholdID, ids...); err != nil {
+		a.errorResponse(w, r, referenceStatus(err), err)
+		return
+	}
+
+	sub, err := util.GetSub(ctx)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	split := domain.Split{
+		TransactionID: item.ID,
+		HouseholdID:   item.HouseholdID,
+		Method:        reqBody.Method,
+		PaidBy:        reqBody.PaidBy,
+		CreatedBy:     sub,
+	}
+	for _, sh := range reqBody.Shares {
+		split.Shares = append(split.Shares, domain.SplitShare{
+			ContactID: sh.ContactID,
+			Value:     sh.Value,
+		})
+	}
+
+	if err := split.Allocate(item.Amount); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	saved, err := a.splitRepo.Save(ctx, &split, item.Version)
+	if err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.transactionRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to save split", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	trn, err := a.transactionRepo.GetByID(ctx, item.ID)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(saved)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, trn)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) splitDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(TransactionCtx{}).(domain.Transaction)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := a.splitRepo.Delete(ctx, item.ID, item.Version); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.transactionRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to delete split", zap.Error(err))
+		status := 500
+		if err.Error() == domain.ErrNotFound.Error() {
+			status = 404
+		}
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	data := map[string]string{
+		"message": "Item deleted successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// splitBalanceHandler returns who owes whom in the household and the fewest
+// payments that settle everything up.
+func (a api) splitBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	balances, err := a.splitRepo.GetBalances(ctx, mem.HouseholdID)
+	if err != nil {
+		a.logger.Error("failed to fetch split balances from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	data := map[string]interface{}{
+		"balances": balances,
+		"payments": domain.SimplifyDebts(balances),
+	}
+
+	resJSON, err := json.Marshal(data)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) settlementListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	stls, err := a.settlementRepo.GetByHouseholdID(ctx, mem.HouseholdID)
+	if err != nil {
+		a.logger.Error("failed to fetch settlements from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(stls)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+// settlementCreateHandler records a settle-up payment. When the household is
+// one of the two sides, the payment is also booked as a transaction against
+// the given account: an expense when paying, an income when receiving.
+func (a api) settlementCreateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	reqBody := createSettlementRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	from, to := reqBody.FromContactID, reqBody.ToContactID
+	if (from == nil && to == nil) || (from != nil && to != nil && *from == *to) {
+		a.errorResponse(w, r, 400, domain.ErrSettlementSelf)
+		return
+	}
+
+	contacts := map[uint]domain.Contact{}
+	for _, id := range []*uint{from, to} {
+		if id == nil {
+			continue
+		}
+		con, err := a.householdContact(ctx, *id, mem.HouseholdID)
+		if err != nil {
+			a.errorResponse(w, r, referenceStatus(err), err)
+			return
+		}
+		contacts[con.ID] = con
+	}
+
+	stl := domain.Settlement{
+		HouseholdID:   mem.HouseholdID,
+		FromContactID: from,
+		ToContactID:   to,
+		Amount:        reqBody.Amount,
+		Note:          reqBody.Note,
+		CreatedBy:     mem.UserID,
+	}
+
+	if from == nil || to == nil {
+		if reqBody.AccountID == 0 || reqBody.CategoryID == 0 {
+			a.errorResponse(w, r, 400, domain.ErrSettlementParty)
+			return
+		}
+
+		if _, err := a.householdAccount(ctx, reqBody.AccountID, mem.HouseholdID); err != nil {
+			a.errorResponse(w, r, referenceStatus(err), err)
+			return
+		}
+
+		if _, err := a.householdCategory(ctx, reqBody.CategoryID, mem.HouseholdID); err != nil {
+			a.errorResponse(w, r, referenceStatus(err), err)
+			return
+		}
+
+		operation, other := "Expense", to
+		if from != nil {
+			operation, other = "Income", from
+		}
+
+		note := reqBody.Note
+		if note == "" {
+			note = fmt.Sprintf("Settle up with %s", contacts[*other].Name)
+		}
+
+		trn, err := a.transactionRepo.Create(ctx, &domain.Transaction{
+			Amount:      reqBody.Amount,
+			Note:        note,
+			Operation:   operation,
+			AccountID:   reqBody.AccountID,
+			CategoryID:  reqBody.CategoryID,
+			CreatedBy:   mem.UserID,
+			HouseholdID: mem.HouseholdID,
+		})
+		if err != nil {
+			a.logger.Error("failed to create settlement transaction", zap.Error(err))
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+		stl.TransactionID = &trn.ID
+	}
+
+	newStl, err := a.settlementRepo.Create(ctx, &stl)
+	if err != nil {
+		a.logger.Error("failed to create settlement", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(newStl)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
@@ -0,0 +1,624 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/middlewares"
+)
+
+const (
+	syncPageSize = 500
+	// syncPushLimit caps the mutations of one push.
+	syncPushLimit = 200
+)
+
+var (
+	errSyncLimit    = errors.New("limit must be a positive number.")
+	errSyncRefField = errors.New("refs can only point account_id, category_id and parent_id at client ids.")
+	errSyncCreate   = errors.New("A client_id is required to create an item.")
+	errSyncPush     = errors.New("A push can hold at most 200 mutations.")
+)
+
+// syncRefTypes says what kind of record each field of pushed data that refs
+// may fill in points at.
+var syncRefTypes = map[string]string{
+	"account_id":  domain.SyncAccount,
+	"category_id": domain.SyncCategory,
+	"parent_id":   domain.SyncCategory,
+}
+
+func (a api) SyncRoutes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middlewares.Auth)
+	r.Use(middlewares.Scope("sync"))
+	r.Use(a.Idempotency)
+	r.Use(a.MemberCtx)
+
+	r.Get("/", a.syncPullHandler)
+	r.Post("/", a.syncPushHandler)
+
+	return r
+}
+
+type syncFeed struct {
+	Changes []domain.SyncChange `json:"changes"`
+	// Cursor is passed back as ?since to get what changed afterwards.
+	Cursor  string `json:"cursor"`
+	HasMore bool   `json:"has_more"`
+}
+
+type syncPushRequest struct {
+	Mutations []syncMutation `json:"mutations" validate:"required,dive"`
+}
+
+// syncMutation is one change a client made offline. Items are addressed by
+// the client_id they were created with or, for items created online, by id.
+type syncMutation struct {
+	ClientID string `json:"client_id,omitempty" validate:"omitempty,uuid"`
+	ID       uint   `json:"id,omitempty"`
+	Type     string `json:"type" validate:"oneof=account category budget transaction"`
+	Op       string `json:"op" validate:"oneof=upsert delete"`
+	// Version is the version of the item the client changed. It is needed
+	// to update or delete an existing item.
+	Version *int `json:"version,omitempty"`
+	// Data is the item as the create and update endpoints take it.
+	Data json.RawMessage `json:"data,omitempty"`
+	// Refs fill fields of Data with the ids of items known by client id,
+	// e.g. {"account_id": "<client id>"}.
+	Refs map[string]string `json:"refs,omitempty" validate:"omitempty,dive,uuid"`
+}
+
+type syncResult struct {
+	ClientID string `json:"client_id,omitempty"`
+	ID       uint   `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	// Item is the saved item, or the current one on a conflict.
+	Item interface{} `json:"item,omitempty"`
+}
+
+// syncPullHandler returns what changed in the household after ?since,
+// oldest first, a page of ?limit changes at a time.
+func (a api) syncPullHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	var since int64
+	if v := r.URL.Query().Get("since"); v != "" {
+		cursor, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || cursor < 0 {
+			a.errorResponse(w, r, 400, domain.ErrSyncCursor)
+			return
+		}
+		since = cursor
+	}
+
+	limit := syncPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			a.errorResponse(w, r, 400, errSyncLimit)
+			return
+		}
+		limit = min(n, syncPageSize)
+	}
+
+	changes, err := a.syncRepo.GetChanges(ctx, mem.HouseholdID, mem.UserID, since, limit+1)
+	if err != nil {
+		a.logger.Error("failed to fetch sync changes from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	feed := syncFeed{Changes: changes, Cursor: strconv.FormatInt(since, 10)}
+	if len(changes) > limit {
+		feed.Changes = changes[:limit]
+		feed.HasMore = true
+	}
+	if n := len(feed.Changes); n > 0 {
+		feed.Cursor = strconv.FormatInt(feed.Changes[n-1].Seq, 10)
+	}
+
+	resJSON, err := json.Marshal(feed)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+// syncPushHandler applies the mutations in order, each on its own. One
+// failing or conflicting does not stop the rest; the response reports the
+// outcome of every mutation.
+func (a api) syncPushHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	reqBody := syncPushRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	if len(reqBody.Mutations) > syncPushLimit {
+		a.errorResponse(w, r, 400, errSyncPush)
+		return
+	}
+
+	results := make([]syncResult, len(reqBody.Mutations))
+	for i, m := range reqBody.Mutations {
+		results[i] = a.applySyncMutation(ctx, mem, m)
+	}
+
+	resJSON, err := json.Marshal(results)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) applySyncMutation(ctx context.Context, mem domain.HouseholdMember, m syncMutation) syncResult {
+	res := syncResult{ClientID: m.ClientID, ID: m.ID, Type: m.Type}
+
+	data, status, err := a.resolveSyncRefs(ctx, mem.HouseholdID, m)
+	if err != nil {
+		return a.syncFailed(res, status, err)
+	}
+
+	id := m.ID
+	if m.ClientID != "" {
+		cid, err := a.syncRepo.GetClientID(ctx, m.ClientID)
+		switch {
+		case err == nil:
+			if cid.HouseholdID != mem.HouseholdID {
+				return a.syncFailed(res, 403, domain.ErrForbidden)
+			}
+			if cid.Type != m.Type {
+				return a.syncFailed(res, 400, domain.ErrSyncClientID)
+			}
+			if cid.EntityID == nil {
+				// Still being created, or left by a create that never
+				// finished, which syncCreate may take over.
+				if m.Op == domain.SyncDelete {
+					return a.syncFailed(res, 409, domain.ErrSyncPending)
+				}
+				break
+			}
+			id = *cid.EntityID
+		case err.Error() != domain.ErrNotFound.Error():
+			return a.syncFailed(res, 500, err)
+		}
+	}
+	res.ID = id
+
+	if id == 0 {
+		if m.Op == domain.SyncDelete {
+			// Never reached the server, so there is nothing to delete.
+			res.Status = domain.SyncApplied
+			return res
+		}
+		return a.syncCreate(ctx, mem, m, data, res)
+	}
+
+	current, householdID, err := a.syncLoad(ctx, m.Type, id)
+	if err != nil {
+		if err.Error() == domain.ErrNotFound.Error() && m.Op == domain.SyncDelete {
+			res.Status = domain.SyncApplied
+			return res
+		}
+		return a.syncFailed(res, referenceStatus(err), err)
+	}
+
+	if householdID == nil || *householdID != mem.HouseholdID {
+		return a.syncFailed(res, 403, domain.ErrForbidden)
+	}
+
+	if m.Version == nil {
+		return a.syncFailed(res, 428, domain.ErrPreconditionNeeded)
+	}
+	if *m.Version != current.CurrentVersion() {
+		res.Status = domain.SyncConflict
+		res.Error = domain.ErrVersionConflict.Error()
+		res.Item = current
+		return res
+	}
+
+	if m.Op == domain.SyncDelete {
+		if status, err := a.syncDelete(ctx, current); err != nil {
+			if err.Error() == domain.ErrVersionConflict.Error() {
+				res.Status = domain.SyncConflict
+				res.Error = err.Error()
+				res.Item, _, _ = a.syncLoad(ctx, m.Type, id)
+				return res
+			}
+			return a.syncFailed(res, status, err)
+		}
+		res.Status = domain.SyncApplied
+		return res
+	}
+
+	item, status, err := a.syncSave(ctx, mem, m.Type, current, data)
+	if err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			res.Status = domain.SyncConflict
+			res.Error = err.Error()
+			res.Item, _, _ = a.syncLoad(ctx, m.Type, id)
+			return res
+		}
+		return a.syncFailed(res, status, err)
+	}
+
+	res.Status = domain.SyncApplied
+	res.Item = item
+	return res
+}
+
+// syncCreate creates the item of a mutation under its client id. The id is
+// reserved first so a retried push can't create the item twice. If the item
+// can't be saved, or can't be tied to the id, it is undone and the id let go
+// so the client can push it again; a reservation lost with the server is
+// claimed again by ReserveClientID once it is old enough.
+func (a api) syncCreate(ctx context.Context, mem domain.HouseholdMember, m syncMutation, data json.RawMessage, res syncResult) syncResult {
+	if m.ClientID == "" {
+		return a.syncFailed(res, 400, errSyncCreate)
+	}
+
+	reserved, err := a.syncRepo.ReserveClientID(ctx, domain.SyncClientID{
+		ClientID:    m.ClientID,
+		HouseholdID: mem.HouseholdID,
+		Type:        m.Type,
+	})
+	if err != nil {
+		return a.syncFailed(res, 500, err)
+	}
+	if !reserved {
+		return a.syncFailed(res, 409, domain.ErrSyncPending)
+	}
+
+	// Cleaning up must not depend on the client still waiting.
+	cleanup := context.WithoutCancel(ctx)
+	release := func() {
+		if err := a.syncRepo.ReleaseClientID(cleanup, m.ClientID); err != nil {
+			a.logger.Error("failed to release sync client id", zap.Error(err))
+		}
+	}
+
+	item, status, err := a.syncSave(ctx, mem, m.Type, nil, data)
+	if err != nil {
+		release()
+		return a.syncFailed(res, status, err)
+	}
+
+	id := syncItemID(item)
+	if err := a.syncRepo.SetEntityID(cleanup, m.ClientID, id); err != nil {
+		if _, err := a.syncDelete(cleanup, item); err != nil {
+			// Keep the reservation so a retry doesn't create it again.
+			a.logger.Error("failed to undo sync create", zap.Error(err))
+			return a.syncFailed(res, 500, err)
+		}
+		release()
+		return a.syncFailed(res, 500, err)
+	}
+
+	res.ID = id
+	res.Status = domain.SyncApplied
+	res.Item = item
+	return res
+}
+
+// syncFailed reports a rejected mutation. Server errors are logged and kept
+// from the client.
+func (a api) syncFailed(res syncResult, status int, err error) syncResult {
+	res.Status = domain.SyncRejected
+	res.Error = err.Error()
+	if status >= 500 {
+		a.logger.Error("failed to apply sync mutation", zap.Error(err))
+		res.Error = "Something went wrong!"
+	}
+	return res
+}
+
+// resolveSyncRefs fills the fields named in the mutation's refs with the ids
+// of the items those client ids were created as.
+func (a api) resolveSyncRefs(ctx context.Context, householdID uint, m syncMutation) (json.RawMessage, int, error) {
+	if len(m.Refs) == 0 {
+		return m.Data, 0, nil
+	}
+
+	fields := map[string]json.RawMessage{}
+	if len(m.Data) > 0 {
+		if err := json.Unmarshal(m.Data, &fields); err != nil {
+			return nil, 422, err
+		}
+	}
+
+	for field, clientID := range m.Refs {
+		kind, ok := syncRefTypes[field]
+		if !ok {
+			return nil, 400, errSyncRefField
+		}
+
+		cid, err := a.syncRepo.GetClientID(ctx, clientID)
+		if err != nil {
+			if err.Error() == domain.ErrNotFound.Error() {
+				return nil, 400, domain.ErrSyncRef
+			}
+			return nil, 500, err
+		}
+		if cid.HouseholdID != householdID || cid.Type != kind || cid.EntityID == nil {
+			return nil, 400, domain.ErrSyncRef
+		}
+
+		fields[field] = json.RawMessage(strconv.FormatUint(uint64(*cid.EntityID), 10))
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, 500, err
+	}
+	return data, 0, nil
+}
+
+// syncLoad loads an item of the given kind along with its household, which
+// is nil for global categories.
+func (a api) syncLoad(ctx context.Context, kind string, id uint) (versioned, *uint, error) {
+	switch kind {
+	case domain.SyncAccount:
+		acc, err := a.accountRepo.GetByID(ctx, id)
+		return acc, &acc.HouseholdID, err
+	case domain.SyncCategory:
+		cat, err := a.categoryRepo.GetByID(ctx, id)
+		return cat, cat.HouseholdID, err
+	case domain.SyncBudget:
+		bud, err := a.budgetRepo.GetByID(ctx, id)
+		return bud, &bud.HouseholdID, err
+	default:
+		trn, err := a.transactionRepo.GetByID(ctx, id)
+		return trn, &trn.HouseholdID, err
+	}
+}
+
+func syncItemID(item versioned) uint {
+	switch item := item.(type) {
+	case domain.Account:
+		return item.ID
+	case domain.Category:
+		return item.ID
+	case domain.Budget:
+		return item.ID
+	case domain.Transaction:
+		return item.ID
+	}
+	return 0
+}
+
+func (a api) syncDelete(ctx context.Context, current versioned) (int, error) {
+	var err error
+	switch item := current.(type) {
+	case domain.Account:
+		err = a.accountRepo.Delete(ctx, int64(item.ID), item.Version)
+	case domain.Category:
+		if status, err := a.deleteCategory(ctx, item, ""); err != nil {
+			return status, err
+		}
+	case domain.Budget:
+		err = a.budgetRepo.Delete(ctx, item.ID, item.Version)
+	case domain.Transaction:
+		err = a.transactionRepo.Delete(ctx, item.ID, item.Version)
+	}
+	if err != nil {
+		if err.Error() == domain.ErrNotFound.Error() {
+			return 404, err
+		}
+		return 500, err
+	}
+	return 200, nil
+}
+
+// syncSave creates an item from data, or updates current with it, checking
+// data the way the create endpoint of its kind does. Transactions are
+// updated with only the fields data holds, as their update endpoint does.
+func (a api) syncSave(ctx context.Context, mem domain.HouseholdMember, kind string, current versioned, data json.RawMessage) (versioned, int, error) {
+	validate := validator.New()
+
+	switch kind {
+	case domain.SyncAccount:
+		req := createAccountRequest{}
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, 422, err
+		}
+		if err := validate.Struct(req); err != nil {
+			return nil, 400, err
+		}
+		if req.FamilyMemberID != nil {
+			if _, err := a.householdFamilyMember(ctx, *req.FamilyMemberID, mem.HouseholdID); err != nil {
+				return nil, referenceStatus(err), err
+			}
+		}
+
+		acc := domain.Account{CreatedBy: mem.UserID, HouseholdID: mem.HouseholdID}
+		if current != nil {
+			acc = current.(domain.Account)
+		}
+		acc.Name = req.Name
+		acc.Balance = req.Balance
+		acc.Note = req.Note
+		acc.FamilyMemberID = req.FamilyMemberID
+
+		var saved *domain.Account
+		var err error
+		if current == nil {
+			saved, err = a.accountRepo.Create(ctx, &acc)
+		} else {
+			saved, err = a.accountRepo.Update(ctx, &acc)
+		}
+		if err != nil {
+			return nil, 500, err
+		}
+		return *saved, 200, nil
+
+	case domain.SyncCategory:
+		req := createCategoryRequest{}
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, 422, err
+		}
+		if err := validate.Struct(req); err != nil {
+			return nil, 400, err
+		}
+
+		cat := domain.Category{CreatedBy: &mem.UserID, HouseholdID: &mem.HouseholdID}
+		if current != nil {
+			cat = current.(domain.Category)
+		}
+		if cat.HouseholdID == nil {
+			return nil, 403, domain.ErrForbidden
+		}
+		cat.Name = req.Name
+		cat.Note = req.Note
+		cat.ParentID = req.ParentID
+
+		if status, err := a.checkCategoryParent(ctx, cat, cat.ParentID); err != nil {
+			return nil, status, err
+		}
+
+		var saved *domain.Category
+		var err error
+		if current == nil {
+			saved, err = a.categoryRepo.Create(ctx, &cat)
+		} else {
+			saved, err = a.categoryRepo.Update(ctx, &cat)
+		}
+		if err != nil {
+			if err.Error() == domain.ErrCategoryCycle.Error() {
+				return nil, 400, err
+			}
+			return nil, 500, err
+		}
+		return *saved, 200, nil
+
+	case domain.SyncBudget:
+		req := createBudgetRequest{}
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, 422, err
+		}
+		if err := validate.Struct(req); err != nil {
+			return nil, 400, err
+		}
+		if _, err := a.householdCategory(ctx, req.CategoryID, mem.HouseholdID); err != nil {
+			return nil, referenceStatus(err), err
+		}
+
+		bud := domain.Budget{CreatedBy: mem.UserID, HouseholdID: mem.HouseholdID}
+		if current != nil {
+			bud = current.(domain.Budget)
+		}
+		bud.Amount = req.Amount
+		bud.CategoryID = req.CategoryID
+
+		var saved *domain.Budget
+		var err error
+		if current == nil {
+			saved, err = a.budgetRepo.Create(ctx, &bud)
+		} else {
+			saved, err = a.budgetRepo.Update(ctx, &bud)
+		}
+		if err != nil {
+			return nil, 500, err
+		}
+
+		// Reloaded for the category and what has been spent.
+		reloaded, err := a.budgetRepo.GetByID(ctx, saved.ID)
+		if err != nil {
+			return nil, 500, err
+		}
+		return reloaded, 200, nil
+
+	default:
+		// As with the update endpoint, fields left out of data keep their
+		// current values.
+		req := createTransactionRequest{}
+		if current != nil {
+			req = transactionRequest(current.(domain.Transaction))
+		}
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, 422, err
+		}
+		if current != nil && req.PayeeID == nil && req.Payee == "" {
+			req.PayeeID = current.(domain.Transaction).PayeeID
+		}
+		if err := validate.Struct(req); err != nil {
+			return nil, 400, err
+		}
+
+		trn, err := a.buildTransaction(ctx, mem, req)
+		if err != nil {
+			return nil, referenceStatus(err), err
+		}
+
+		if current == nil {
+			if err := a.applyRules(ctx, &trn); err != nil {
+				return nil, 500, err
+			}
+		} else {
+			cur := current.(domain.Transaction)
+			trn.ID = cur.ID
+			trn.Version = cur.Version
+			trn.CreatedBy = cur.CreatedBy
+		}
+
+		if err := trn.CheckLines(); err != nil {
+			return nil, 400, err
+		}
+		if err := a.checkLineCategories(ctx, mem.HouseholdID, trn.Lines); err != nil {
+			return nil, referenceStatus(err), err
+		}
+
+		var saved *domain.Transaction
+		if current == nil {
+			saved, err = a.transactionRepo.Create(ctx, &trn)
+		} else {
+			saved, err = a.transactionRepo.Update(ctx, &trn)
+		}
+		if err != nil {
+			if err.Error() == domain.ErrSplitMismatch.Error() {
+				return nil, 400, err
+			}
+			return nil, 500, err
+		}
+
+		reloaded, err := a.transactionRepo.GetByID(ctx, saved.ID)
+		if err != nil {
+			return nil, 500, err
+		}
+		return reloaded, 200, nil
+	}
+}
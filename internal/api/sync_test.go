@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+func (f *fakeAccounts) Create(ctx context.Context, acc *domain.Account) (*domain.Account, error) {
+	acc.ID = uint(len(f.accounts) + 1)
+	acc.Version = 1
+	f.accounts = append(f.accounts, *acc)
+	return acc, nil
+}
+
+func (f *fakeAccounts) Delete(ctx context.Context, id int64, version int) error {
+	for i, acc := range f.accounts {
+		if acc.ID == uint(id) && acc.Version == version {
+			f.accounts = append(f.accounts[:i], f.accounts[i+1:]...)
+			return nil
+		}
+	}
+	return domain.ErrVersionConflict
+}
+
+// fakeSync keeps client ids in memory and fails SetEntityID when told to.
+type fakeSync struct {
+	domain.SyncRepository
+	ids       map[string]domain.SyncClientID
+	setFailed bool
+}
+
+func (f *fakeSync) GetClientID(ctx context.Context, clientID string) (domain.SyncClientID, error) {
+	if cid, ok := f.ids[clientID]; ok {
+		return cid, nil
+	}
+	return domain.SyncClientID{}, domain.ErrNotFound
+}
+
+func (f *fakeSync) ReserveClientID(ctx context.Context, id domain.SyncClientID) (bool, error) {
+	if _, ok := f.ids[id.ClientID]; ok {
+		return false, nil
+	}
+	f.ids[id.ClientID] = id
+	return true, nil
+}
+
+func (f *fakeSync) SetEntityID(ctx context.Context, clientID string, entityID uint) error {
+	if f.setFailed {
+		return errors.New("connection reset")
+	}
+	cid := f.ids[clientID]
+	cid.EntityID = &entityID
+	f.ids[clientID] = cid
+	return nil
+}
+
+func (f *fakeSync) ReleaseClientID(ctx context.Context, clientID string) error {
+	if cid, ok := f.ids[clientID]; ok && cid.EntityID == nil {
+		delete(f.ids, clientID)
+	}
+	return nil
+}
+
+func syncPush(router http.Handler, token string, body string) ([]syncResult, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(HouseholdHeader, "1")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	var results []syncResult
+	json.Unmarshal(res.Body.Bytes(), &results)
+	return results, res
+}
+
+func TestSyncCreateLetsGoOfClientIDWhenNotTied(t *testing.T) {
+	accounts := &fakeAccounts{}
+	sync := &fakeSync{ids: map[string]domain.SyncClientID{}, setFailed: true}
+	members := &fakeMembers{}
+
+	a := &api{
+		logger:              zap.NewNop(),
+		redis:               fakeRedis(t),
+		accountRepo:         accounts,
+		syncRepo:            sync,
+		householdMemberRepo: members,
+	}
+	router := a.SyncRoutes()
+	token := sessionToken(t, members, domain.User{Base: domain.Base{ID: 1}}, 1)
+
+	push := `{"mutations":[{"client_id":"0b6c3c52-3f1a-4d3e-9a55-1f1a8f0e7c11","type":"account","op":"upsert","data":{"name":"Cash"}}]}`
+
+	results, res := syncPush(router, token, push)
+	if res.Code != http.StatusOK || len(results) != 1 || results[0].Status != domain.SyncRejected {
+		t.Fatalf("failed create returned %d: %s", res.Code, res.Body)
+	}
+	if len(accounts.accounts) != 0 {
+		t.Fatalf("account of the failed create was kept: %+v", accounts.accounts)
+	}
+	if len(sync.ids) != 0 {
+		t.Fatalf("client id is still reserved: %+v", sync.ids)
+	}
+
+	sync.setFailed = false
+	results, res = syncPush(router, token, push)
+	if len(results) != 1 || results[0].Status != domain.SyncApplied {
+		t.Fatalf("retried create returned %d: %s", res.Code, res.Body)
+	}
+	if len(accounts.accounts) != 1 {
+		t.Fatalf("retry made %d accounts, want 1", len(accounts.accounts))
+	}
+}
+
+func TestSyncTransactionUpsertKeepsFieldsLeftOut(t *testing.T) {
+	tt := newTransactionTest(t, domain.Split{})
+	delete(tt.transactions.splits, 1)
+	tt.transactions.transactions[0].Tags = []string{"food"}
+	tt.transactions.transactions[0].Lines = []domain.TransactionLine{{CategoryID: 1, Amount: 100}}
+	members := &fakeMembers{}
+
+	a := &api{
+		logger:              zap.NewNop(),
+		redis:               fakeRedis(t),
+		transactionRepo:     tt.transactions,
+		accountRepo:         &fakeAccounts{accounts: []domain.Account{{Base: domain.Base{ID: 1}, HouseholdID: 1}}},
+		categoryRepo:        &fakeCategories{categories: []domain.Category{{Base: domain.Base{ID: 1}, Name: "Food"}}},
+		householdMemberRepo: members,
+	}
+	token := sessionToken(t, members, domain.User{Base: domain.Base{ID: 1}}, 1)
+
+	results, res := syncPush(a.SyncRoutes(), token, `{"mutations":[{"id":1,"type":"transaction","op":"upsert","version":3,"data":{"note":"Lunch"}}]}`)
+	if len(results) != 1 || results[0].Status != domain.SyncApplied {
+		t.Fatalf("upsert returned %d: %s", res.Code, res.Body)
+	}
+
+	stored, _ := tt.transactions.GetByID(context.Background(), 1)
+	if stored.Note != "Lunch" {
+		t.Fatalf("note not saved: %+v", stored)
+	}
+	if stored.Amount != 100 || len(stored.Tags) != 1 || len(stored.Lines) != 1 {
+		t.Fatalf("fields left out were cleared: %+v", stored)
+	}
+}
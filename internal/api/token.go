@@ -0,0 +1,279 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/middlewares"
+	"github.com/Brix101/budgetto-backend/internal/util"
+)
+
+type TokenCtx struct{}
+
+func (a api) TokenRoutes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middlewares.Auth)
+	// "tokens" is never a grantable scope, so only session tokens can manage
+	// personal access tokens.
+	r.Use(middlewares.Scope("tokens"))
+	r.Use(a.Idempotency)
+
+	r.Get("/", a.tokenListHandler)
+	r.Post("/", a.tokenCreateHandler)
+
+	r.Route("/{id}", func(r chi.Router) {
+		r.Use(a.TokenCtx)
+
+		r.Get("/", a.tokenGetHandler)
+		r.With(a.IfMatch(TokenCtx{})).Delete("/", a.tokenRevokeHandler)
+	})
+
+	return r
+}
+
+func (a api) TokenCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		sub, err := util.GetSub(ctx)
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+
+		item, err := a.tokenRepo.GetByID(ctx, uint(id))
+		if err != nil {
+			status := 500
+			if err.Error() == domain.ErrNotFound.Error() {
+				status = 404
+			}
+			a.errorResponse(w, r, status, err)
+			return
+		}
+
+		if item.CreatedBy != sub {
+			a.errorResponse(w, r, 403, domain.ErrForbidden)
+			return
+		}
+
+		ctx = context.WithValue(ctx, TokenCtx{}, item)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// verifyPersonalAccessToken is registered with middlewares.Auth so scripts can
+// authenticate with a personal access token instead of a JWT.
+func (a api) verifyPersonalAccessToken(ctx context.Context, token string) (jwt.MapClaims, error) {
+	tok, err := a.tokenRepo.GetByHash(ctx, domain.HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tok.Valid(); err != nil {
+		return nil, err
+	}
+
+	// Tokens are cut off as soon as their owner is deactivated or asks for
+	// the account to be deleted.
+	usr, err := a.userRepo.GetByID(ctx, tok.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+	if !usr.IsActive {
+		return nil, domain.ErrAccountDisabled
+	}
+	if usr.DeleteAfter != nil {
+		return nil, domain.ErrAccountDeleting
+	}
+
+	if err := a.tokenRepo.Touch(ctx, tok.ID); err != nil {
+		a.logger.Error("failed to track personal access token usage", zap.Error(err))
+	}
+
+	return jwt.MapClaims{
+		"sub":   fmt.Sprintf("%d", tok.CreatedBy),
+		"jti":   fmt.Sprintf("pat:%d", tok.ID),
+		"scope": tok.Scopes,
+	}, nil
+}
+
+type createTokenRequest struct {
+	Name      string   `json:"name" validate:"required"`
+	Scopes    []string `json:"scopes" validate:"required,min=1,dive,required"`
+	ExpiresIn *int     `json:"expires_in_days,omitempty" validate:"omitempty,gte=1"`
+}
+
+func (a api) tokenListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	sub, err := util.GetSub(ctx)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	toks, err := a.tokenRepo.GetByUserSUB(ctx, sub)
+	if err != nil {
+		a.logger.Error("failed to fetch personal access tokens from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(toks)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) tokenCreateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	sub, err := util.GetSub(ctx)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	reqBody := createTokenRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	for _, scope := range reqBody.Scopes {
+		if !validScope(scope) {
+			a.errorResponse(w, r, 400, fmt.Errorf("Unknown scope: %s.", scope))
+			return
+		}
+	}
+
+	newTok := domain.PersonalAccessToken{
+		Name:      reqBody.Name,
+		Scopes:    reqBody.Scopes,
+		CreatedBy: sub,
+	}
+
+	if reqBody.ExpiresIn != nil {
+		expiresAt := time.Now().AddDate(0, 0, *reqBody.ExpiresIn)
+		newTok.ExpiresAt = &expiresAt
+	}
+
+	if err := newTok.GenerateToken(); err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	tok, err := a.tokenRepo.Create(ctx, &newTok)
+	if err != nil {
+		a.logger.Error("failed to create personal access token", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(tok)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) tokenGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(TokenCtx{}).(domain.PersonalAccessToken)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	resJSON, err := json.Marshal(item)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, item)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) tokenRevokeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(TokenCtx{}).(domain.PersonalAccessToken)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	if item.RevokedAt != nil {
+		a.errorResponse(w, r, 404, domain.ErrNotFound)
+		return
+	}
+
+	if err := a.tokenRepo.Revoke(ctx, item.ID, item.Version); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.tokenRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to revoke personal access token", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	data := map[string]string{
+		"message": "Token revoked successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func validScope(scope string) bool {
+	for _, s := range domain.TokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator"
@@ -12,7 +13,6 @@ import (
 
 	"github.com/Brix101/budgetto-backend/internal/domain"
 	"github.com/Brix101/budgetto-backend/internal/middlewares"
-	"github.com/Brix101/budgetto-backend/internal/util"
 )
 
 type TransactionCtx struct{}
@@ -21,17 +21,32 @@ func (a api) TransactionRoutes() chi.Router {
 	r := chi.NewRouter()
 
 	r.Use(middlewares.Auth)
+	r.Use(middlewares.Scope("transactions"))
+	r.Use(a.Idempotency)
 
-	r.Get("/", a.transactionListHandler)
-	r.Post("/", a.transactionCreateHandler)
 	r.Get("/operations", a.transactionOpListHandler)
 
+	r.Group(func(r chi.Router) {
+		r.Use(a.MemberCtx)
+
+		r.Get("/", a.transactionListHandler)
+		r.Post("/", a.transactionCreateHandler)
+		r.Post("/bulk", a.transactionBulkHandler)
+	})
+
 	r.Route("/{id}", func(r chi.Router) {
 		r.Use(a.TransctionCtx)
 
 		r.Get("/", a.transactionGetHandler)
-		r.Put("/", a.transactionUpdateHandler)
-		r.Delete("/", a.transactionDeleteHandler)
+		r.With(a.IfMatch(TransactionCtx{})).Put("/", a.transactionUpdateHandler)
+		r.With(a.IfMatch(TransactionCtx{})).Delete("/", a.transactionDeleteHandler)
+
+		r.Get("/history", a.transactionHistoryHandler)
+		r.Post("/revert/{version}", a.transactionRevertHandler)
+
+		r.Get("/split", a.splitGetHandler)
+		r.With(a.IfMatch(TransactionCtx{})).Put("/split", a.splitSaveHandler)
+		r.With(a.IfMatch(TransactionCtx{})).Delete("/split", a.splitDeleteHandler)
 	})
 
 	return r
@@ -41,12 +56,6 @@ func (a api) TransctionCtx(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
-		sub, err := util.GetSub(ctx)
-		if err != nil {
-			a.errorResponse(w, r, 500, err)
-			return
-		}
-
 		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
 			a.errorResponse(w, r, 500, err)
@@ -63,12 +72,13 @@ func (a api) TransctionCtx(next http.Handler) http.Handler {
 			return
 		}
 
-		if item.CreatedBy != sub {
-			a.errorResponse(w, r, 403, domain.ErrForbidden)
+		mem, ok := a.authorizeMember(w, r, item.HouseholdID)
+		if !ok {
 			return
 		}
 
-		ctx = context.WithValue(ctx, CatCtx{}, item)
+		ctx = context.WithValue(ctx, MemberCtx{}, mem)
+		ctx = context.WithValue(ctx, TransactionCtx{}, item)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -79,6 +89,104 @@ type createTransactionRequest struct {
 	Operation  string  `json:"operation" validate:"oneof=Expense Income Transfer Refund"`
 	AccountID  uint    `json:"account_id" validate:"required"`
 	CategoryID uint    `json:"category_id" validate:"required"`
+	// FamilyMemberID defaults to the account's family member.
+	FamilyMemberID *uint                          `json:"family_member_id,omitempty"`
+	Lines          []createTransactionLineRequest `json:"lines,omitempty" validate:"omitempty,dive"`
+	Tags           []string                       `json:"tags,omitempty"`
+	// PayeeID wins over Payee, a name matched against payees and their
+	// aliases or else created.
+	PayeeID *uint  `json:"payee_id,omitempty"`
+	Payee   string `json:"payee,omitempty"`
+}
+
+type createTransactionLineRequest struct {
+	CategoryID uint    `json:"category_id" validate:"required"`
+	Amount     float64 `json:"amount" validate:"gte=0"`
+	Note       string  `json:"note,omitempty"`
+}
+
+// transactionRequest is trn as a request, for updates to decode over so
+// the fields they leave out stay as they are. The payee is left for the
+// caller to keep when neither payee_id nor payee is sent.
+func transactionRequest(trn domain.Transaction) createTransactionRequest {
+	req := createTransactionRequest{
+		Amount:         trn.Amount,
+		Note:           trn.Note,
+		Operation:      trn.Operation,
+		AccountID:      trn.AccountID,
+		CategoryID:     trn.CategoryID,
+		FamilyMemberID: trn.FamilyMemberID,
+		Tags:           trn.Tags,
+	}
+	for _, line := range trn.Lines {
+		req.Lines = append(req.Lines, createTransactionLineRequest{
+			CategoryID: line.CategoryID,
+			Amount:     line.Amount,
+			Note:       line.Note,
+		})
+	}
+	return req
+}
+
+// checkLineCategories makes sure every line uses a category householdID may
+// reference.
+func (a api) checkLineCategories(ctx context.Context, householdID uint, lines []domain.TransactionLine) error {
+	for _, line := range lines {
+		if _, err := a.householdCategory(ctx, line.CategoryID, householdID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildTransaction turns req into a transaction of the member's household,
+// checking everything it points at.
+func (a api) buildTransaction(ctx context.Context, mem domain.HouseholdMember, req createTransactionRequest) (domain.Transaction, error) {
+	acc, err := a.householdAccount(ctx, req.AccountID, mem.HouseholdID)
+	if err != nil {
+		return domain.Transaction{}, err
+	}
+
+	if _, err := a.householdCategory(ctx, req.CategoryID, mem.HouseholdID); err != nil {
+		return domain.Transaction{}, err
+	}
+
+	familyMemberID := acc.FamilyMemberID
+	if req.FamilyMemberID != nil {
+		if _, err := a.householdFamilyMember(ctx, *req.FamilyMemberID, mem.HouseholdID); err != nil {
+			return domain.Transaction{}, err
+		}
+		familyMemberID = req.FamilyMemberID
+	}
+
+	payee, err := a.resolvePayee(ctx, mem, req.PayeeID, req.Payee)
+	if err != nil {
+		return domain.Transaction{}, err
+	}
+
+	trn := domain.Transaction{
+		Amount:         req.Amount,
+		Note:           req.Note,
+		Operation:      req.Operation,
+		CategoryID:     req.CategoryID,
+		AccountID:      req.AccountID,
+		CreatedBy:      mem.UserID,
+		HouseholdID:    mem.HouseholdID,
+		FamilyMemberID: familyMemberID,
+		Tags:           req.Tags,
+	}
+	if payee != nil {
+		trn.PayeeID = &payee.ID
+		trn.PayeeName = payee.Name
+	}
+	for _, line := range req.Lines {
+		trn.Lines = append(trn.Lines, domain.TransactionLine{
+			CategoryID: line.CategoryID,
+			Amount:     line.Amount,
+			Note:       line.Note,
+		})
+	}
+	return trn, nil
 }
 
 func (a api) transactionOpListHandler(w http.ResponseWriter, r *http.Request) {
@@ -107,14 +215,40 @@ func (a api) transactionListHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
-	user := ctx.Value(middlewares.AuthCtx{}).(*domain.UserClaims)
-	sub, err := user.GetSubject()
-	if err != nil {
-		a.errorResponse(w, r, 500, err)
-		return
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	filter := domain.TransactionFilter{}
+	if v := r.URL.Query().Get("family_member_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			a.errorResponse(w, r, 400, err)
+			return
+		}
+		fmID := uint(id)
+		filter.FamilyMemberID = &fmID
+	}
+	if v := r.URL.Query().Get("payee_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			a.errorResponse(w, r, 400, err)
+			return
+		}
+		payeeID := uint(id)
+		filter.PayeeID = &payeeID
+	}
+	if v := r.URL.Query().Get("tags"); v != "" {
+		filter.Tags = strings.Split(v, ",")
+		filter.TagMatch = r.URL.Query().Get("tag_match")
+		if filter.TagMatch == "" {
+			filter.TagMatch = domain.TagMatchAny
+		}
+		if filter.TagMatch != domain.TagMatchAny && filter.TagMatch != domain.TagMatchAll {
+			a.errorResponse(w, r, 400, errTagMatch)
+			return
+		}
 	}
 
-	trns, err := a.transactionRepo.GetByUserSUB(ctx, sub)
+	trns, err := a.transactionRepo.GetByHouseholdID(ctx, mem.HouseholdID, filter)
 	if err != nil {
 		a.logger.Error("failed to fetch transactions from database", zap.Error(err))
 		a.errorResponse(w, r, 500, err)
@@ -136,11 +270,7 @@ func (a api) transactionCreateHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
-	sub, err := util.GetSub(ctx)
-	if err != nil {
-		a.errorResponse(w, r, 500, err)
-		return
-	}
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
 
 	reqBody := createTransactionRequest{}
 
@@ -157,11 +287,26 @@ func (a api) transactionCreateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	trnReq := domain.Transaction{
-		Amount:     reqBody.Amount,
-		CategoryID: reqBody.CategoryID,
-		AccountID:  reqBody.AccountID,
-		CreatedBy:  sub,
+	trnReq, err := a.buildTransaction(ctx, mem, reqBody)
+	if err != nil {
+		a.errorResponse(w, r, referenceStatus(err), err)
+		return
+	}
+
+	if err := a.applyRules(ctx, &trnReq); err != nil {
+		a.logger.Error("failed to apply rules", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	if err := trnReq.CheckLines(); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	if err := a.checkLineCategories(ctx, mem.HouseholdID, trnReq.Lines); err != nil {
+		a.errorResponse(w, r, referenceStatus(err), err)
+		return
 	}
 
 	newTrn, err := a.transactionRepo.Create(ctx, &trnReq)
@@ -182,6 +327,7 @@ func (a api) transactionCreateHandler(w http.ResponseWriter, r *http.Request) {
 		a.errorResponse(w, r, 500, err)
 		return
 	}
+	setETag(w, trn)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(resJSON)
@@ -203,6 +349,7 @@ func (a api) transactionGetHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, item)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(resJSON)
@@ -218,21 +365,63 @@ func (a api) transactionUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	// Fields left out of the body keep their current values.
+	reqBody := transactionRequest(item)
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
 		a.logger.Error("failed to parse request json", zap.Error(err))
 		a.errorResponse(w, r, 422, err)
 		return
 	}
 	defer r.Body.Close()
+	if reqBody.PayeeID == nil && reqBody.Payee == "" {
+		reqBody.PayeeID = item.PayeeID
+	}
 
-	upTrn, err := a.transactionRepo.Update(ctx, &item)
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	trn, err := a.buildTransaction(ctx, mem, reqBody)
+	if err != nil {
+		a.errorResponse(w, r, referenceStatus(err), err)
+		return
+	}
+	trn.ID = item.ID
+	trn.Version = item.Version
+	trn.CreatedBy = item.CreatedBy
+
+	if err := trn.CheckLines(); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	if err := a.checkLineCategories(ctx, item.HouseholdID, trn.Lines); err != nil {
+		a.errorResponse(w, r, referenceStatus(err), err)
+		return
+	}
+
+	upTrn, err := a.transactionRepo.Update(ctx, &trn)
 	if err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.transactionRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		// A split with fixed share amounts can't follow a new amount.
+		if err.Error() == domain.ErrSplitMismatch.Error() {
+			a.errorResponse(w, r, 400, err)
+			return
+		}
 		a.logger.Error("failed to update transaction", zap.Error(err))
 		a.errorResponse(w, r, 500, err)
 		return
 	}
 
-	trn, err := a.transactionRepo.GetByID(ctx, upTrn.ID)
+	trn, err = a.transactionRepo.GetByID(ctx, upTrn.ID)
 	if err != nil {
 		a.errorResponse(w, r, 500, err)
 		return
@@ -244,6 +433,7 @@ func (a api) transactionUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, trn)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(resJSON)
@@ -259,13 +449,19 @@ func (a api) transactionDeleteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := a.transactionRepo.Delete(ctx, item.ID); err != nil {
+	if err := a.transactionRepo.Delete(ctx, item.ID, item.Version); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.transactionRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
 		a.logger.Error("failed to delete transactiond", zap.Error(err))
 		status := 500
 		if err.Error() == domain.ErrNotFound.Error() {
 			status = 404
 		}
 		a.errorResponse(w, r, status, err)
+		return
 	}
 
 	data := map[string]string{
@@ -276,3 +472,136 @@ func (a api) transactionDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(data)
 }
+
+func (a api) transactionHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(TransactionCtx{}).(domain.Transaction)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	versions, err := a.transactionRepo.GetVersions(ctx, item.ID)
+	if err != nil {
+		a.logger.Error("failed to fetch transaction history from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(domain.TransactionHistory(versions))
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+// transactionRevertHandler puts a transaction back the way it was at an
+// earlier version. The revert is saved as a new version, so it can be
+// reverted in turn, and the account balances follow through the
+// transactions_balance trigger.
+func (a api) transactionRevertHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(TransactionCtx{}).(domain.Transaction)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	v, err := a.transactionRepo.GetVersion(ctx, item.ID, version)
+	if err != nil {
+		status := 500
+		if err.Error() == domain.ErrNotFound.Error() {
+			status = 404
+		}
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	// What the version points at may have been deleted or moved since.
+	if _, err := a.householdAccount(ctx, v.AccountID, item.HouseholdID); err != nil {
+		a.errorResponse(w, r, referenceStatus(err), err)
+		return
+	}
+
+	if _, err := a.householdCategory(ctx, v.CategoryID, item.HouseholdID); err != nil {
+		a.errorResponse(w, r, referenceStatus(err), err)
+		return
+	}
+
+	if err := a.checkLineCategories(ctx, item.HouseholdID, v.Lines); err != nil {
+		a.errorResponse(w, r, referenceStatus(err), err)
+		return
+	}
+
+	if v.FamilyMemberID != nil {
+		if _, err := a.householdFamilyMember(ctx, *v.FamilyMemberID, item.HouseholdID); err != nil {
+			a.errorResponse(w, r, referenceStatus(err), err)
+			return
+		}
+	}
+
+	if v.PayeeID != nil {
+		if _, err := a.householdPayee(ctx, *v.PayeeID, item.HouseholdID); err != nil {
+			a.errorResponse(w, r, referenceStatus(err), err)
+			return
+		}
+	}
+
+	item.Amount = v.Amount
+	item.Note = v.Note
+	item.Operation = v.Operation
+	item.AccountID = v.AccountID
+	item.CategoryID = v.CategoryID
+	item.FamilyMemberID = v.FamilyMemberID
+	item.PayeeID = v.PayeeID
+	item.Lines = v.Lines
+	item.Tags = v.Tags
+
+	upTrn, err := a.transactionRepo.Update(ctx, &item)
+	if err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.transactionRepo.GetByID(ctx, item.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		// A split with fixed share amounts can't follow a new amount.
+		if err.Error() == domain.ErrSplitMismatch.Error() {
+			a.errorResponse(w, r, 400, err)
+			return
+		}
+		a.logger.Error("failed to revert transaction", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	trn, err := a.transactionRepo.GetByID(ctx, upTrn.ID)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(trn)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, trn)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
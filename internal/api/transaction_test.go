@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+// fakeTransactions keeps the splits of its transactions so Update can work
+// them out again for the new amount, as the repository does.
+type fakeTransactions struct {
+	domain.TransactionRepository
+	transactions []domain.Transaction
+	splits       map[uint]domain.Split
+}
+
+func (f *fakeTransactions) GetByID(ctx context.Context, id uint) (domain.Transaction, error) {
+	for _, trn := range f.transactions {
+		if trn.ID == id {
+			return trn, nil
+		}
+	}
+	return domain.Transaction{}, domain.ErrNotFound
+}
+
+func (f *fakeTransactions) Update(ctx context.Context, trn *domain.Transaction) (*domain.Transaction, error) {
+	for i, cur := range f.transactions {
+		if cur.ID != trn.ID {
+			continue
+		}
+		if cur.Version != trn.Version {
+			return nil, domain.ErrVersionConflict
+		}
+
+		split, ok := f.splits[trn.ID]
+		if ok {
+			split.Shares = append([]domain.SplitShare(nil), split.Shares...)
+			if err := split.Allocate(trn.Amount); err != nil {
+				return nil, err
+			}
+		}
+
+		trn.Version++
+		f.transactions[i] = *trn
+		if ok {
+			f.splits[trn.ID] = split
+		}
+		return trn, nil
+	}
+	return nil, domain.ErrVersionConflict
+}
+
+type fakeCategories struct {
+	domain.CategoryRepository
+	categories []domain.Category
+}
+
+func (f *fakeCategories) GetByID(ctx context.Context, id uint) (domain.Category, error) {
+	for _, cat := range f.categories {
+		if cat.ID == id {
+			return cat, nil
+		}
+	}
+	return domain.Category{}, domain.ErrNotFound
+}
+
+type transactionTest struct {
+	transactions *fakeTransactions
+	router       http.Handler
+	token        string
+}
+
+// friendID is the contact sharing the test transactions with the household.
+var friendID uint = 7
+
+// newTransactionTest serves the transaction routes over one transaction of
+// 100 with the given split.
+func newTransactionTest(t *testing.T, split domain.Split) *transactionTest {
+	t.Helper()
+
+	transactions := &fakeTransactions{
+		transactions: []domain.Transaction{{
+			Base:        domain.Base{ID: 1},
+			Versioned:   domain.Versioned{Version: 3},
+			Operation:   "Expense",
+			HouseholdID: 1,
+			Amount:      100,
+			AccountID:   1,
+			CategoryID:  1,
+		}},
+		splits: map[uint]domain.Split{1: split},
+	}
+	members := &fakeMembers{}
+
+	a := &api{
+		logger:              zap.NewNop(),
+		redis:               fakeRedis(t),
+		transactionRepo:     transactions,
+		accountRepo:         &fakeAccounts{accounts: []domain.Account{{Base: domain.Base{ID: 1}, HouseholdID: 1}}},
+		categoryRepo:        &fakeCategories{categories: []domain.Category{{Base: domain.Base{ID: 1}, Name: "Food"}}},
+		householdMemberRepo: members,
+	}
+
+	return &transactionTest{
+		transactions: transactions,
+		router:       a.TransactionRoutes(),
+		token:        sessionToken(t, members, domain.User{Base: domain.Base{ID: 1}}, 1),
+	}
+}
+
+func (tt *transactionTest) changeAmount(amount string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, "/1", strings.NewReader(`{"amount":`+amount+`}`))
+	req.Header.Set("Authorization", "Bearer "+tt.token)
+	req.Header.Set("If-Match", etag(3))
+	res := httptest.NewRecorder()
+	tt.router.ServeHTTP(res, req)
+	return res
+}
+
+func TestTransactionAmountChangeWithAmountSplit(t *testing.T) {
+	tt := newTransactionTest(t, domain.Split{
+		Method: domain.SplitAmount,
+		Shares: []domain.SplitShare{{ID: 1, Value: 60, Amount: 60}, {ID: 2, ContactID: &friendID, Value: 40, Amount: 40}},
+	})
+
+	res := tt.changeAmount("120")
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("amount change returned %d, want 400: %s", res.Code, res.Body)
+	}
+
+	var body domain.ErrResponse
+	if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Message != domain.ErrSplitMismatch.Error() {
+		t.Fatalf("error doesn't explain the split mismatch: %s", res.Body)
+	}
+
+	stored, _ := tt.transactions.GetByID(context.Background(), 1)
+	if stored.Amount != 100 || stored.Version != 3 {
+		t.Fatalf("transaction was saved: %+v", stored)
+	}
+	if shares := tt.transactions.splits[1].Shares; shares[0].Amount != 60 || shares[1].Amount != 40 {
+		t.Fatalf("split was changed: %+v", shares)
+	}
+}
+
+func TestTransactionAmountChangeWithPercentageSplit(t *testing.T) {
+	tt := newTransactionTest(t, domain.Split{
+		Method: domain.SplitPercentage,
+		Shares: []domain.SplitShare{{ID: 1, Value: 50, Amount: 50}, {ID: 2, ContactID: &friendID, Value: 50, Amount: 50}},
+	})
+
+	res := tt.changeAmount("120")
+	if res.Code != http.StatusOK {
+		t.Fatalf("amount change returned %d: %s", res.Code, res.Body)
+	}
+
+	if shares := tt.transactions.splits[1].Shares; shares[0].Amount != 60 || shares[1].Amount != 60 {
+		t.Fatalf("split not reallocated: %+v", shares)
+	}
+}
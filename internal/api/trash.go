@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/middlewares"
+)
+
+type TrashCtx struct{}
+
+func (a api) TrashRoutes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middlewares.Auth)
+	r.Use(middlewares.Scope("trash"))
+	r.Use(a.Idempotency)
+
+	r.With(a.MemberCtx).Get("/", a.trashListHandler)
+
+	r.Route("/{type}/{id}", func(r chi.Router) {
+		r.Use(a.TrashCtx)
+
+		r.Get("/", a.trashGetHandler)
+		r.Post("/restore", a.trashRestoreHandler)
+	})
+
+	return r
+}
+
+func (a api) TrashCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		kind := chi.URLParam(r, "type")
+		if !domain.IsTrashType(kind) {
+			a.errorResponse(w, r, 400, domain.ErrTrashType)
+			return
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			a.errorResponse(w, r, 500, err)
+			return
+		}
+
+		item, err := a.trashRepo.GetByID(ctx, kind, uint(id))
+		if err != nil {
+			status := 500
+			if err.Error() == domain.ErrNotFound.Error() {
+				status = 404
+			}
+			a.errorResponse(w, r, status, err)
+			return
+		}
+
+		// Deleted global categories are only restored by an admin.
+		if item.HouseholdID == nil {
+			a.errorResponse(w, r, 403, domain.ErrForbidden)
+			return
+		}
+
+		if _, ok := a.authorizeMember(w, r, *item.HouseholdID); !ok {
+			return
+		}
+
+		ctx = context.WithValue(ctx, TrashCtx{}, item)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// trashListHandler lists the household's deleted records, optionally only
+// those of ?type.
+func (a api) trashListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mem := ctx.Value(MemberCtx{}).(domain.HouseholdMember)
+
+	kind := r.URL.Query().Get("type")
+	if kind != "" && !domain.IsTrashType(kind) {
+		a.errorResponse(w, r, 400, domain.ErrTrashType)
+		return
+	}
+
+	items, err := a.trashRepo.GetByHouseholdID(ctx, mem.HouseholdID, kind)
+	if err != nil {
+		a.logger.Error("failed to fetch trash from database", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(items)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) trashGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(TrashCtx{}).(domain.TrashItem)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	res, err := json.Marshal(item)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(res)
+}
+
+// trashRestoreHandler restores a deleted record. A restored budget or
+// transaction also brings back its deleted account and categories, so it
+// counts towards balances, budgets and reports again.
+func (a api) trashRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	item, ok := ctx.Value(TrashCtx{}).(domain.TrashItem)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := a.trashRepo.Restore(ctx, item.Type, item.ID); err != nil {
+		a.logger.Error("failed to restore "+item.Type, zap.Error(err))
+		status := 500
+		if err.Error() == domain.ErrNotFound.Error() {
+			status = 404
+		}
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	data := map[string]string{
+		"message": "Item restored successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(data)
+}
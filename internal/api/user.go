@@ -3,42 +3,453 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"os"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+	"github.com/Brix101/budgetto-backend/internal/middlewares"
+	"github.com/Brix101/budgetto-backend/internal/util"
 )
 
+type UserCtx struct{}
+
+// emailVerificationExp is how long an email change link stays valid.
+const emailVerificationExp = time.Hour * 24
+
 func (a api) UserRoutes() chi.Router {
 	r := chi.NewRouter()
 
-	r.Get("/", a.userListHandler)
+	// The verification link is followed from an inbox, possibly signed out,
+	// so the token itself is the credential.
+	r.Post("/email/verify", a.userVerifyEmailHandler)
+
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares.Auth)
+		r.Use(middlewares.Scope("users"))
+		r.Use(a.Idempotency)
+
+		r.Get("/", a.userGetHandler)
+		r.Post("/restore", a.userRestoreHandler)
+		r.Post("/email", a.userEmailHandler)
+
+		r.Group(func(r chi.Router) {
+			r.Use(a.UserCtx)
+			r.Use(a.IfMatch(UserCtx{}))
+
+			r.Put("/", a.userUpdateHandler)
+			r.Delete("/", a.userDeleteHandler)
+			r.Put("/password", a.userPasswordHandler)
+		})
+	})
 
 	return r
 }
 
-type userRegisterRequest struct {
-	Name     string `json:"name" validate:"required"`
+type updateUserRequest struct {
+	Name  *string `json:"name,omitempty" validate:"omitempty,min=1"`
+	Bio   *string `json:"bio,omitempty"`
+	Image *string `json:"image,omitempty" validate:"omitempty,url"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=6"` // Minimum length: 6
+}
+
+type changeEmailRequest struct {
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"` // Minimum length: 6
+	Password string `json:"password" validate:"required"`
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
 }
 
-func (a api) userListHandler(w http.ResponseWriter, r *http.Request) {
+type deleteUserRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// currentUser loads the authenticated user.
+func (a api) currentUser(ctx context.Context) (domain.User, error) {
+	sub, err := util.GetSub(ctx)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	return a.userRepo.GetByID(ctx, sub)
+}
+
+func (a api) UserCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		usr, err := a.currentUser(ctx)
+		if err != nil {
+			status := 500
+			if err.Error() == domain.ErrNotFound.Error() {
+				status = 404
+			}
+			a.errorResponse(w, r, status, err)
+			return
+		}
+
+		ctx = context.WithValue(ctx, UserCtx{}, usr)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (a api) userGetHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
-	user, err := a.userRepo.GetByEmail(ctx, "brixterporras@gmail.com")
+	usr, err := a.currentUser(ctx)
 	if err != nil {
 		a.errorResponse(w, r, 500, err)
 		return
 	}
 
-	resJSON, err := json.Marshal(user)
+	resJSON, err := json.Marshal(usr)
 	if err != nil {
 		a.errorResponse(w, r, 500, err)
 		return
 	}
 
+	setETag(w, usr)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(resJSON)
 }
+
+func (a api) userUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	usr, ok := ctx.Value(UserCtx{}).(domain.User)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	reqBody := updateUserRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	if reqBody.Name != nil {
+		usr.Name = *reqBody.Name
+	}
+	if reqBody.Bio != nil {
+		usr.Bio = reqBody.Bio
+	}
+	if reqBody.Image != nil {
+		usr.Image = reqBody.Image
+	}
+
+	upUsr, err := a.userRepo.Update(ctx, &usr)
+	if err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.userRepo.GetByID(ctx, usr.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to update user", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(upUsr)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	setETag(w, upUsr)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) userPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	usr, ok := ctx.Value(UserCtx{}).(domain.User)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	reqBody := changePasswordRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	if !usr.CheckPassword(reqBody.CurrentPassword) {
+		a.errorResponse(w, r, 400, domain.ErrWrongPassword)
+		return
+	}
+
+	usr.Password = reqBody.NewPassword
+	if err := usr.HashPassword(); err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	if _, err := a.userRepo.Update(ctx, &usr); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.userRepo.GetByID(ctx, usr.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to change user password", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	// Everything signed in with the old password is signed out; this
+	// session gets a new refresh token.
+	if err := a.revokeCredentials(ctx, usr.ID); err != nil {
+		a.logger.Error("failed to revoke user credentials", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	if err := setRefreshCookie(w, usr); err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	data := map[string]string{
+		"message": "Password changed successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func (a api) userEmailHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	usr, err := a.currentUser(ctx)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	reqBody := changeEmailRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.logger.Error("failed to parse request json", zap.Error(err))
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	if !usr.CheckPassword(reqBody.Password) {
+		a.errorResponse(w, r, 400, domain.ErrWrongPassword)
+		return
+	}
+
+	if _, err := a.userRepo.GetByEmail(ctx, reqBody.Email); err == nil {
+		a.errorResponse(w, r, 400, domain.ErrEmailTaken)
+		return
+	}
+
+	token, err := domain.NewSecret()
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	ver := domain.EmailVerification{
+		UserID:    usr.ID,
+		Email:     reqBody.Email,
+		TokenHash: domain.HashToken(token),
+		ExpiresAt: time.Now().Add(emailVerificationExp),
+	}
+
+	if _, err := a.emailVerificationRepo.Create(ctx, &ver); err != nil {
+		a.logger.Error("failed to create email verification", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	appURL := os.Getenv("APP_URL")
+	if appURL == "" {
+		appURL = "http://localhost:5173"
+	}
+	link := fmt.Sprintf("%s/verify-email?token=%s", appURL, url.QueryEscape(token))
+
+	if err := a.mailer.Send(ctx, reqBody.Email, "Confirm your new email address",
+		fmt.Sprintf("Hi %s,\n\nConfirm your new Budgetto email address by opening %s\n\nThe link expires in 24 hours.", usr.Name, link),
+	); err != nil {
+		a.logger.Error("failed to send email verification", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	data := map[string]string{
+		"message": "Check your new inbox to confirm the change",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func (a api) userVerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	reqBody := verifyEmailRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	ver, err := a.emailVerificationRepo.GetByHash(ctx, domain.HashToken(reqBody.Token))
+	if err != nil || ver.UsedAt != nil || time.Now().After(ver.ExpiresAt) {
+		a.errorResponse(w, r, 400, domain.ErrInvalidToken)
+		return
+	}
+
+	if err := a.emailVerificationRepo.Confirm(ctx, ver.ID); err != nil {
+		switch err.Error() {
+		case domain.ErrInvalidToken.Error(), domain.ErrEmailTaken.Error():
+			a.errorResponse(w, r, 400, err)
+		default:
+			a.logger.Error("failed to change user email", zap.Error(err))
+			a.errorResponse(w, r, 500, err)
+		}
+		return
+	}
+
+	upUsr, err := a.userRepo.GetByID(ctx, ver.UserID)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	resJSON, err := json.Marshal(upUsr)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJSON)
+}
+
+func (a api) userDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	usr, ok := ctx.Value(UserCtx{}).(domain.User)
+	if !ok {
+		http.Error(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	reqBody := deleteUserRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		a.errorResponse(w, r, 422, err)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(reqBody); err != nil {
+		a.errorResponse(w, r, 400, err)
+		return
+	}
+
+	if !usr.CheckPassword(reqBody.Password) {
+		a.errorResponse(w, r, 400, domain.ErrWrongPassword)
+		return
+	}
+
+	deleteAfter := time.Now().Add(domain.AccountDeletionGrace())
+	if err := a.userRepo.ScheduleDeletion(ctx, usr.ID, usr.Version, deleteAfter); err != nil {
+		if err.Error() == domain.ErrVersionConflict.Error() {
+			current, err := a.userRepo.GetByID(ctx, usr.ID)
+			a.preconditionFailed(w, r, current, err)
+			return
+		}
+		a.logger.Error("failed to schedule user deletion", zap.Error(err))
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	data := map[string]string{
+		"message":      "Account scheduled for deletion",
+		"delete_after": deleteAfter.Format(time.RFC3339),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func (a api) userRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	sub, err := util.GetSub(ctx)
+	if err != nil {
+		a.errorResponse(w, r, 500, err)
+		return
+	}
+
+	if err := a.userRepo.CancelDeletion(ctx, sub); err != nil {
+		a.logger.Error("failed to cancel user deletion", zap.Error(err))
+		status := 500
+		if err.Error() == domain.ErrNotFound.Error() {
+			status = 404
+		}
+		a.errorResponse(w, r, status, err)
+		return
+	}
+
+	data := map[string]string{
+		"message": "Account deletion cancelled",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(data)
+}
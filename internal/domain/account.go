@@ -4,20 +4,24 @@ import "context"
 
 type Account struct {
 	Base
-	Name      string  `json:"name"`
-	Note      string  `json:"note,omitempty"`
-	CreatedBy uint    `json:"created_by"`
-	Balance   float64 `json:"balance"`
+	Versioned
+	Name        string `json:"name"`
+	Note        string `json:"note,omitempty"`
+	CreatedBy   uint   `json:"created_by"`
+	HouseholdID uint   `json:"household_id"`
+	// FamilyMemberID optionally marks whose account this is.
+	FamilyMemberID *uint   `json:"family_member_id,omitempty"`
+	Balance        float64 `json:"balance"`
 }
 
 // AccountRepository represents the account's repository contract
 type AccountRepository interface {
 	GetByID(ctx context.Context, id uint) (Account, error)
-	GetByUserSUB(ctx context.Context, sub string) ([]Account, error)
+	GetByHouseholdID(ctx context.Context, householdID uint) ([]Account, error)
 	// GetAll(ctx context.Context) ([]Account, error)
 	//
 	// CreateOrUpdate(ctx context.Context, acc *Account) error
 	Create(ctx context.Context, acc *Account) (*Account, error)
 	Update(ctx context.Context, acc *Account) (*Account, error)
-	Delete(ctx context.Context, id int64) error
+	Delete(ctx context.Context, id int64, version int) error
 }
@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+)
+
+// Audit actions. Deletes are soft unless purged.
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+)
+
+// AuditEvent is one append-only record of a change. Before and After only
+// hold the fields that changed on updates.
+type AuditEvent struct {
+	ID          uint            `json:"id"`
+	HouseholdID *uint           `json:"household_id,omitempty"`
+	ActorID     *uint           `json:"actor_id,omitempty"`
+	EntityType  string          `json:"entity_type"`
+	EntityID    uint            `json:"entity_id"`
+	Action      string          `json:"action"`
+	Before      json.RawMessage `json:"before,omitempty"`
+	After       json.RawMessage `json:"after,omitempty"`
+	RequestID   string          `json:"request_id,omitempty"`
+	IP          string          `json:"ip,omitempty"`
+	PrevHash    string          `json:"prev_hash,omitempty"`
+	Hash        string          `json:"hash,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+type AuditFilter struct {
+	EntityType string
+	EntityID   *uint
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+}
+
+// AuditVerification is the result of checking the hash chain.
+type AuditVerification struct {
+	Valid   bool `json:"valid"`
+	Checked int  `json:"checked"`
+	// BrokenAt is the first event whose hash doesn't match.
+	BrokenAt *uint `json:"broken_at,omitempty"`
+}
+
+// AuditInfo describes who is making a change, recorded with the audit
+// events it causes.
+type AuditInfo struct {
+	ActorID   *uint
+	RequestID string
+	IP        string
+}
+
+// AuditHashChain reports whether audit events are hash chained, read from
+// AUDIT_HASH_CHAIN.
+func AuditHashChain() bool {
+	return strings.EqualFold(os.Getenv("AUDIT_HASH_CHAIN"), "true")
+}
+
+// AuditRepository represents the audit log's repository contract
+type AuditRepository interface {
+	GetByHouseholdID(ctx context.Context, householdID uint, filter AuditFilter) ([]AuditEvent, error)
+	// Verify walks the hash chain and reports the first event that was
+	// changed or removed.
+	Verify(ctx context.Context) (AuditVerification, error)
+}
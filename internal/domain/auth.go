@@ -1,12 +1,12 @@
 package domain
 
 import (
-	"encoding/base64"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Brix101/budgetto-backend/internal/keys"
 )
 
 var AccessExp = time.Hour * 1
@@ -16,16 +16,11 @@ type UserClaims struct {
 	jwt.RegisteredClaims
 	Name  string `json:"name"`
 	Email string `json:"email"`
+	Role  string `json:"role"`
 }
 
 func (u User) GenerateClaims() (string, error) {
-	privateKey := os.Getenv("ACCESS_PRIVATE_KEY")
-	keyData, err := base64.StdEncoding.DecodeString(privateKey)
-	if err != nil {
-		return "", err
-	}
-
-	parsedKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(keyData))
+	manager, err := keys.Access()
 	if err != nil {
 		return "", err
 	}
@@ -41,18 +36,11 @@ func (u User) GenerateClaims() (string, error) {
 		},
 		u.Name,
 		u.Email,
+		u.Role,
 	}
 
-	// Create token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-
-	// Generate encoded token and send it as response.
-	t, err := token.SignedString(parsedKey)
-	if err != nil {
-		return "", err
-	}
-
-	return t, nil
+	// Sign with the active key, stamping its kid in the header.
+	return manager.Sign(claims)
 }
 
 func (u User) GenerateRefreshToken() (string, error) {
@@ -65,27 +53,13 @@ func (u User) GenerateRefreshToken() (string, error) {
 		Subject:   fmt.Sprintf("%d", u.ID),
 	}
 
-	privateKey := os.Getenv("REFRESH_PRIVATE_KEY")
-	keyData, err := base64.StdEncoding.DecodeString(privateKey)
-	if err != nil {
-		return "", err
-	}
-
-	parsedKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(keyData))
-	if err != nil {
-		return "", err
-	}
-
-	// Create token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-
-	// Generate encoded token and send it as response.
-	t, err := token.SignedString(parsedKey)
+	manager, err := keys.Refresh()
 	if err != nil {
 		return "", err
 	}
 
-	return t, nil
+	// Sign with the active key, stamping its kid in the header.
+	return manager.Sign(claims)
 }
 
 type UserWithToken struct {
@@ -2,23 +2,175 @@ package domain
 
 import (
 	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrCategoryCycle  = errors.New("A category can't be moved under itself or one of its subcategories.")
+	ErrCategoryParent = errors.New("A global category can only have a global parent.")
+	ErrNotGlobal      = errors.New("Only global categories can be overridden.")
+	ErrCategoryInUse  = errors.New("The category is still in use, choose a replacement category to move it to.")
+	ErrCategoryMerge  = errors.New("A category can't be merged into itself or one of its subcategories.")
+	ErrCategoryGlobal = errors.New("A global category can only be merged into another global category.")
 )
 
 type Category struct {
 	Base
-	CreatedBy *uint  `json:"created_by,omitempty"`
-	Name      string `json:"name" validate:"required"`
-	Note      string `json:"note,omitempty"`
+	Versioned
+	CreatedBy   *uint  `json:"created_by,omitempty"`
+	HouseholdID *uint  `json:"household_id,omitempty"`
+	ParentID    *uint  `json:"parent_id,omitempty"`
+	Name        string `json:"name" validate:"required"`
+	Note        string `json:"note,omitempty"`
+	// Children is only filled by CategoryTree.
+	Children []Category `json:"children,omitempty"`
+
+	// Presentation fields, set by a user's CategoryOverride.
+	OriginalName string `json:"original_name,omitempty"`
+	Icon         string `json:"icon,omitempty"`
+	Color        string `json:"color,omitempty"`
+	SortOrder    *int   `json:"sort_order,omitempty"`
+	Hidden       bool   `json:"hidden,omitempty"`
+}
+
+// CategoryUsage counts what still points at a category.
+type CategoryUsage struct {
+	Transactions int `json:"transactions"`
+	Budgets      int `json:"budgets"`
+	Rules        int `json:"rules"`
+}
+
+// InUse reports whether anything points at the category.
+func (u CategoryUsage) InUse() bool {
+	return u.Transactions > 0 || u.Budgets > 0 || u.Rules > 0
+}
+
+// CategoryOverride is how one user sees a global category. It only changes
+// presentation; transactions keep pointing at the global category.
+type CategoryOverride struct {
+	Versioned
+	UserID     uint      `json:"user_id"`
+	CategoryID uint      `json:"category_id"`
+	Hidden     bool      `json:"hidden"`
+	Name       *string   `json:"name,omitempty"`
+	Icon       *string   `json:"icon,omitempty"`
+	Color      *string   `json:"color,omitempty"`
+	SortOrder  *int      `json:"sort_order,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Apply overlays o onto cat.
+func (o CategoryOverride) Apply(cat *Category) {
+	cat.Hidden = o.Hidden
+	if o.Name != nil && *o.Name != "" && *o.Name != cat.Name {
+		cat.OriginalName = cat.Name
+		cat.Name = *o.Name
+	}
+	if o.Icon != nil {
+		cat.Icon = *o.Icon
+	}
+	if o.Color != nil {
+		cat.Color = *o.Color
+	}
+	cat.SortOrder = o.SortOrder
+}
+
+// ApplyOverrides overlays overrides onto the global categories in cats and
+// orders them by sort order, then name. Hidden categories are dropped unless
+// withHidden is set.
+func ApplyOverrides(cats []Category, overrides []CategoryOverride, withHidden bool) []Category {
+	byID := map[uint]CategoryOverride{}
+	for _, o := range overrides {
+		byID[o.CategoryID] = o
+	}
+
+	res := []Category{}
+	for _, cat := range cats {
+		if o, ok := byID[cat.ID]; ok && cat.HouseholdID == nil {
+			o.Apply(&cat)
+		}
+		if cat.Hidden && !withHidden {
+			continue
+		}
+		res = append(res, cat)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		a, b := res[i].SortOrder, res[j].SortOrder
+		if a != nil && b != nil && *a != *b {
+			return *a < *b
+		}
+		if (a == nil) != (b == nil) {
+			return a != nil
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// CategoryTree nests a flat list of categories under their parents. A
+// category whose parent isn't in the list becomes a root.
+func CategoryTree(cats []Category) []Category {
+	children := map[uint][]Category{}
+	present := map[uint]bool{}
+	for _, cat := range cats {
+		present[cat.ID] = true
+	}
+
+	roots := []Category{}
+	for _, cat := range cats {
+		if cat.ParentID != nil && present[*cat.ParentID] && *cat.ParentID != cat.ID {
+			children[*cat.ParentID] = append(children[*cat.ParentID], cat)
+			continue
+		}
+		roots = append(roots, cat)
+	}
+
+	var build func(cat Category, seen map[uint]bool) Category
+	build = func(cat Category, seen map[uint]bool) Category {
+		seen[cat.ID] = true
+		for _, child := range children[cat.ID] {
+			if seen[child.ID] {
+				continue
+			}
+			cat.Children = append(cat.Children, build(child, seen))
+		}
+		return cat
+	}
+
+	tree := []Category{}
+	for _, root := range roots {
+		tree = append(tree, build(root, map[uint]bool{}))
+	}
+	return tree
+}
+
+// CategoryOverrideRepository represents the category override's repository contract
+type CategoryOverrideRepository interface {
+	GetByUserID(ctx context.Context, userID uint) ([]CategoryOverride, error)
+	Get(ctx context.Context, userID uint, categoryID uint) (CategoryOverride, error)
+	Save(ctx context.Context, o *CategoryOverride) (*CategoryOverride, error)
+	Delete(ctx context.Context, userID uint, categoryID uint, version int) error
 }
 
 // CategoryRepository represents the categories repository contract
 type CategoryRepository interface {
 	GetByID(ctx context.Context, id uint) (Category, error)
-	GetByUserSUB(ctx context.Context, sub uint) ([]Category, error)
+	// GetByHouseholdID returns the household's categories and the global ones.
+	GetByHouseholdID(ctx context.Context, householdID uint) ([]Category, error)
+	GetGlobal(ctx context.Context) ([]Category, error)
+	// GetAncestorIDs returns id and the ids of all the categories above it.
+	GetAncestorIDs(ctx context.Context, id uint) ([]uint, error)
 	// GetAll(ctx context.Context) ([]Category, error)
 
 	// CreateOrUpdate(ctx context.Context, cat *Category) error
 	Update(ctx context.Context, cat *Category) (*Category, error)
 	Create(ctx context.Context, cat *Category) (*Category, error)
-	Delete(ctx context.Context, id uint) error
+	Delete(ctx context.Context, id uint, version int) error
+	GetUsage(ctx context.Context, id uint) (CategoryUsage, error)
+	// Merge moves everything pointing at id over to intoID, adding budget
+	// amounts together, and then deletes id.
+	Merge(ctx context.Context, id uint, intoID uint) error
 }
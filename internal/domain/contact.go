@@ -0,0 +1,23 @@
+package domain
+
+import "context"
+
+// Contact is someone outside the household expenses are split with.
+type Contact struct {
+	Base
+	Versioned
+	HouseholdID uint   `json:"household_id"`
+	Name        string `json:"name"`
+	Email       string `json:"email,omitempty"`
+	CreatedBy   uint   `json:"created_by"`
+}
+
+// ContactRepository represents the contact's repository contract
+type ContactRepository interface {
+	GetByID(ctx context.Context, id uint) (Contact, error)
+	GetByHouseholdID(ctx context.Context, householdID uint) ([]Contact, error)
+
+	Create(ctx context.Context, con *Contact) (*Contact, error)
+	Update(ctx context.Context, con *Contact) (*Contact, error)
+	Delete(ctx context.Context, id uint, version int) error
+}
@@ -7,3 +7,13 @@ type Base struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+// Versioned carries the optimistic concurrency version of an entity. It is
+// bumped by the database on every update.
+type Versioned struct {
+	Version int `json:"version"`
+}
+
+func (v Versioned) CurrentVersion() int {
+	return v.Version
+}
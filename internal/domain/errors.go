@@ -6,6 +6,13 @@ var (
 	ErrNotFound           = errors.New("Requested item was not found.")
 	ErrForbidden          = errors.New("You don't have permission to access the requested resource.")
 	ErrInvalidCredentials = errors.New("Invalid credentials. Please try again.")
+	ErrWrongPassword      = errors.New("The current password you entered is incorrect.")
+	ErrEmailTaken         = errors.New("The email you entered is already taken.")
+	ErrInvalidToken       = errors.New("The link is invalid or has expired.")
+	ErrAccountDisabled    = errors.New("This account has been deactivated.")
+	ErrAccountDeleting    = errors.New("This account is scheduled for deletion.")
+	ErrVersionConflict    = errors.New("The item was changed by someone else. Reload it and try again.")
+	ErrPreconditionNeeded = errors.New("An If-Match header with the item's ETag is required.")
 )
 
 type ErrResponse struct {
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// FamilyMember is a person spending is attributed to who doesn't have their
+// own login, such as a partner or a child.
+type FamilyMember struct {
+	Base
+	Versioned
+	HouseholdID  uint   `json:"household_id"`
+	Name         string `json:"name"`
+	Relationship string `json:"relationship,omitempty"`
+	CreatedBy    uint   `json:"created_by"`
+}
+
+// FamilyMemberTotal sums a household's transactions per family member,
+// category and operation. FamilyMemberID is nil for unattributed spending.
+type FamilyMemberTotal struct {
+	FamilyMemberID   *uint   `json:"family_member_id"`
+	FamilyMemberName string  `json:"family_member_name"`
+	CategoryID       uint    `json:"category_id"`
+	CategoryName     string  `json:"category_name"`
+	Operation        string  `json:"operation"`
+	Total            float64 `json:"total"`
+	Count            int     `json:"count"`
+}
+
+// FamilyMemberRepository represents the family member's repository contract
+type FamilyMemberRepository interface {
+	GetByID(ctx context.Context, id uint) (FamilyMember, error)
+	GetByHouseholdID(ctx context.Context, householdID uint) ([]FamilyMember, error)
+	// GetTotals sums transactions created in [from, to).
+	GetTotals(ctx context.Context, householdID uint, from time.Time, to time.Time) ([]FamilyMemberTotal, error)
+
+	Create(ctx context.Context, fm *FamilyMember) (*FamilyMember, error)
+	Update(ctx context.Context, fm *FamilyMember) (*FamilyMember, error)
+	Delete(ctx context.Context, id uint, version int) error
+}
@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+// Household member roles, from most to least privileged.
+const (
+	HouseholdOwner  = "owner"
+	HouseholdEditor = "editor"
+	HouseholdViewer = "viewer"
+)
+
+var (
+	ErrAlreadyMember     = errors.New("That user is already a member of this household.")
+	ErrLastOwner         = errors.New("A household needs at least one owner.")
+	ErrPersonalHousehold = errors.New("Your personal household can't be deleted or shared.")
+)
+
+// Household owns accounts, categories, budgets and transactions shared by
+// its members. Every user has exactly one personal household.
+type Household struct {
+	Base
+	Versioned
+	Name       string `json:"name"`
+	IsPersonal bool   `json:"is_personal"`
+	CreatedBy  *uint  `json:"created_by,omitempty"`
+	// Role is the requesting user's role, filled in when listing.
+	Role string `json:"role,omitempty"`
+}
+
+type HouseholdMember struct {
+	Base
+	Versioned
+	HouseholdID uint   `json:"household_id"`
+	UserID      uint   `json:"user_id"`
+	Name        string `json:"name,omitempty"`
+	Email       string `json:"email,omitempty"`
+	Role        string `json:"role" validate:"required,oneof=owner editor viewer"`
+}
+
+// CanWrite reports whether the member may change the household's data.
+func (m HouseholdMember) CanWrite() bool {
+	return m.Role == HouseholdOwner || m.Role == HouseholdEditor
+}
+
+// CanManage reports whether the member may rename the household and manage
+// its members.
+func (m HouseholdMember) CanManage() bool {
+	return m.Role == HouseholdOwner
+}
+
+// HouseholdRepository represents the household's repository contract
+type HouseholdRepository interface {
+	GetByID(ctx context.Context, id uint) (Household, error)
+	GetByUserSUB(ctx context.Context, sub uint) ([]Household, error)
+	GetPersonal(ctx context.Context, sub uint) (Household, error)
+
+	// Create inserts the household and makes its creator the owner.
+	Create(ctx context.Context, hh *Household) (*Household, error)
+	Update(ctx context.Context, hh *Household) (*Household, error)
+	Delete(ctx context.Context, id uint, version int) error
+}
+
+// HouseholdMemberRepository represents the household member's repository contract
+type HouseholdMemberRepository interface {
+	GetByHouseholdUser(ctx context.Context, householdID uint, userID uint) (HouseholdMember, error)
+	GetByHouseholdID(ctx context.Context, householdID uint) ([]HouseholdMember, error)
+	CountOwners(ctx context.Context, householdID uint) (int, error)
+
+	Create(ctx context.Context, mem *HouseholdMember) (*HouseholdMember, error)
+	Update(ctx context.Context, mem *HouseholdMember) (*HouseholdMember, error)
+	Delete(ctx context.Context, householdID uint, userID uint, version int) error
+}
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrEmailNotVerified = errors.New("The provider did not verify this email address.")
+	// ErrIdentityLinkRequired is returned instead of linking a provider
+	// account to a user who never proved they own the shared email.
+	ErrIdentityLinkRequired = errors.New("An account with this email already exists. Sign in and link the provider from your profile.")
+	ErrIdentityTaken        = errors.New("This provider account is already linked to another user.")
+)
+
+// UserIdentity links a user to an account at an external OpenID Connect provider.
+type UserIdentity struct {
+	Base
+	UserID   uint   `json:"user_id"`
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"`
+	Email    string `json:"email,omitempty"`
+}
+
+// UserIdentityRepository represents the user identity's repository contract
+type UserIdentityRepository interface {
+	GetByProviderSubject(ctx context.Context, provider string, subject string) (UserIdentity, error)
+	GetByUserSUB(ctx context.Context, sub uint) ([]UserIdentity, error)
+
+	Create(ctx context.Context, idt *UserIdentity) (*UserIdentity, error)
+	Delete(ctx context.Context, id uint) error
+}
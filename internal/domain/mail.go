@@ -0,0 +1,8 @@
+package domain
+
+import "context"
+
+// Mailer delivers transactional email such as address verification links.
+type Mailer interface {
+	Send(ctx context.Context, to string, subject string, body string) error
+}
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrPayeeExists    = errors.New("A payee with that name or alias already exists.")
+	ErrPayeeMergeSelf = errors.New("A payee can't be merged into itself.")
+)
+
+// Payee is a merchant or person a household pays or gets paid by. Aliases
+// are other spellings, like the ones bank imports use, that resolve to it.
+type Payee struct {
+	Base
+	Versioned
+	HouseholdID uint     `json:"household_id"`
+	Name        string   `json:"name"`
+	Aliases     []string `json:"aliases"`
+	CreatedBy   uint     `json:"created_by"`
+}
+
+// PayeeMonth sums a payee's transactions for one month and operation.
+type PayeeMonth struct {
+	Month     time.Time `json:"month"`
+	Operation string    `json:"operation"`
+	Total     float64   `json:"total"`
+	Count     int       `json:"count"`
+}
+
+// NormalizePayee lower cases name and collapses everything but letters and
+// digits into single spaces, so "ACME Corp." and "acme  corp" match.
+func NormalizePayee(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// PayeeRepository represents the payee's repository contract
+type PayeeRepository interface {
+	GetByID(ctx context.Context, id uint) (Payee, error)
+	// GetByName finds the payee whose name or one of whose aliases
+	// normalizes to the same value as name.
+	GetByName(ctx context.Context, householdID uint, name string) (Payee, error)
+	GetByHouseholdID(ctx context.Context, householdID uint) ([]Payee, error)
+	// Search returns up to limit payees whose name or an alias starts with q.
+	Search(ctx context.Context, householdID uint, q string, limit int) ([]Payee, error)
+	GetHistory(ctx context.Context, id uint) ([]PayeeMonth, error)
+
+	Create(ctx context.Context, payee *Payee) (*Payee, error)
+	Update(ctx context.Context, payee *Payee) (*Payee, error)
+	Delete(ctx context.Context, id uint, version int) error
+	AddAlias(ctx context.Context, payee *Payee, alias string) error
+	RemoveAlias(ctx context.Context, id uint, version int, alias string) error
+	// Merge moves the transactions and aliases of payee id onto intoID, keeps
+	// its name as an alias and deletes it.
+	Merge(ctx context.Context, id uint, intoID uint) error
+}
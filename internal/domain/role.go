@@ -0,0 +1,36 @@
+package domain
+
+const (
+	RoleUser    = "user"
+	RoleAdmin   = "admin"
+	RoleSupport = "support"
+)
+
+const (
+	PermUsersRead        = "users:read"
+	PermUsersWrite       = "users:write"
+	PermGlobalCategories = "categories:global"
+	PermAuditVerify      = "audit:verify"
+)
+
+// rolePermissions lists what each role may do beyond owning its own data.
+var rolePermissions = map[string][]string{
+	RoleUser:    {},
+	RoleSupport: {PermUsersRead},
+	RoleAdmin:   {PermUsersRead, PermUsersWrite, PermGlobalCategories, PermAuditVerify},
+}
+
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether role grants perm.
+func HasPermission(role string, perm string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrRuleNoCondition = errors.New("A rule needs at least one condition.")
+	ErrRuleNoAction    = errors.New("A rule needs at least one action.")
+)
+
+// RuleConditions are all required to match; empty fields are ignored.
+type RuleConditions struct {
+	NoteContains  string   `json:"note_contains,omitempty"`
+	PayeeContains string   `json:"payee_contains,omitempty"`
+	AmountMin     *float64 `json:"amount_min,omitempty"`
+	AmountMax     *float64 `json:"amount_max,omitempty"`
+	AccountID     *uint    `json:"account_id,omitempty"`
+	Operation     string   `json:"operation,omitempty" validate:"omitempty,oneof=Expense Income Transfer Refund"`
+}
+
+// RuleActions are applied to a matching transaction; empty fields are left
+// alone and tags are added to the ones it already has.
+type RuleActions struct {
+	CategoryID *uint    `json:"category_id,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	PayeeID    *uint    `json:"payee_id,omitempty"`
+	Note       *string  `json:"note,omitempty"`
+}
+
+// Rule categorizes transactions automatically. Rules run by ascending
+// Priority and the first rule to set a field wins.
+type Rule struct {
+	Base
+	Versioned
+	HouseholdID uint           `json:"household_id"`
+	Name        string         `json:"name"`
+	Priority    int            `json:"priority"`
+	IsActive    bool           `json:"is_active"`
+	Conditions  RuleConditions `json:"conditions"`
+	Actions     RuleActions    `json:"actions"`
+	CreatedBy   uint           `json:"created_by"`
+}
+
+// RuleChange is what applying a rule does to one transaction.
+type RuleChange struct {
+	TransactionID uint        `json:"transaction_id"`
+	Fields        []string    `json:"fields"`
+	Before        Transaction `json:"before"`
+	After         Transaction `json:"after"`
+}
+
+// Check reports whether the rule has something to match on and something
+// to do.
+func (r Rule) Check() error {
+	c := r.Conditions
+	if c.NoteContains == "" && c.PayeeContains == "" && c.AmountMin == nil &&
+		c.AmountMax == nil && c.AccountID == nil && c.Operation == "" {
+		return ErrRuleNoCondition
+	}
+
+	act := r.Actions
+	if act.CategoryID == nil && len(act.Tags) == 0 && act.PayeeID == nil && act.Note == nil {
+		return ErrRuleNoAction
+	}
+	return nil
+}
+
+// Matches reports whether trn meets every condition of the rule.
+func (r Rule) Matches(trn Transaction) bool {
+	c := r.Conditions
+	if c.NoteContains != "" && !strings.Contains(strings.ToLower(trn.Note), strings.ToLower(c.NoteContains)) {
+		return false
+	}
+	if c.PayeeContains != "" && !strings.Contains(NormalizePayee(trn.PayeeName), NormalizePayee(c.PayeeContains)) {
+		return false
+	}
+	if c.AmountMin != nil && trn.Amount < *c.AmountMin {
+		return false
+	}
+	if c.AmountMax != nil && trn.Amount > *c.AmountMax {
+		return false
+	}
+	if c.AccountID != nil && trn.AccountID != *c.AccountID {
+		return false
+	}
+	if c.Operation != "" && trn.Operation != c.Operation {
+		return false
+	}
+	return true
+}
+
+// ApplyRules runs the active rules over trn in order and returns the names of
+// the fields they changed. Fields in locked are left alone, which is how a
+// higher priority rule keeps a lower one from overriding it.
+func ApplyRules(rules []Rule, trn *Transaction) []string {
+	changed := []string{}
+	locked := map[string]bool{}
+
+	for _, rule := range rules {
+		if !rule.IsActive || !rule.Matches(*trn) {
+			continue
+		}
+
+		act := rule.Actions
+		if act.CategoryID != nil && !locked["category_id"] {
+			locked["category_id"] = true
+			if trn.CategoryID != *act.CategoryID {
+				trn.CategoryID = *act.CategoryID
+				changed = append(changed, "category_id")
+			}
+		}
+		if act.PayeeID != nil && !locked["payee_id"] {
+			locked["payee_id"] = true
+			if trn.PayeeID == nil || *trn.PayeeID != *act.PayeeID {
+				id := *act.PayeeID
+				trn.PayeeID = &id
+				changed = append(changed, "payee_id")
+			}
+		}
+		if act.Note != nil && !locked["note"] {
+			locked["note"] = true
+			if trn.Note != *act.Note {
+				trn.Note = *act.Note
+				changed = append(changed, "note")
+			}
+		}
+		if len(act.Tags) > 0 {
+			tags := NormalizeTags(append(append([]string{}, trn.Tags...), act.Tags...))
+			if len(tags) != len(NormalizeTags(trn.Tags)) {
+				trn.Tags = tags
+				if !contains(changed, "tags") {
+					changed = append(changed, "tags")
+				}
+			}
+		}
+	}
+	return changed
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleRepository represents the rule's repository contract
+type RuleRepository interface {
+	GetByID(ctx context.Context, id uint) (Rule, error)
+	// GetByHouseholdID returns the rules of a household in the order they run.
+	GetByHouseholdID(ctx context.Context, householdID uint) ([]Rule, error)
+
+	Create(ctx context.Context, rule *Rule) (*Rule, error)
+	Update(ctx context.Context, rule *Rule) (*Rule, error)
+	Delete(ctx context.Context, id uint, version int) error
+}
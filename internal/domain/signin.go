@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrTooManyAttempts = errors.New("Too many sign in attempts. Please try again later.")
+	ErrAccountLocked   = errors.New("This account is temporarily locked after repeated failed sign in attempts.")
+)
+
+const (
+	SignInUnknownEmail = "unknown_email"
+	SignInBadPassword  = "bad_password"
+	SignInThrottled    = "throttled"
+	SignInLocked       = "locked"
+)
+
+// SignInAttempt is an audit record of a failed sign in.
+type SignInAttempt struct {
+	ID        uint      `json:"id"`
+	Email     string    `json:"email"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Reason    string    `json:"reason"`
+	UserID    *uint     `json:"user_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SignInAttemptRepository represents the sign in attempt's repository contract
+type SignInAttemptRepository interface {
+	GetByEmail(ctx context.Context, email string, since time.Time) ([]SignInAttempt, error)
+	Create(ctx context.Context, att *SignInAttempt) (*SignInAttempt, error)
+}
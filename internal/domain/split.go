@@ -0,0 +1,306 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// How a split divides a transaction's amount between its shares.
+const (
+	SplitAmount     = "amount"
+	SplitPercentage = "percentage"
+	SplitEqual      = "equal"
+)
+
+var (
+	ErrSplitMismatch   = errors.New("The shares don't add up to the transaction amount.")
+	ErrSplitPercentage = errors.New("The share percentages must add up to 100.")
+	ErrSplitDuplicate  = errors.New("A person can only have one share in a split.")
+	ErrSettlementSelf  = errors.New("A settlement needs two different people.")
+	ErrSettlementParty = errors.New("An account is required when you pay or receive a settlement.")
+	ErrSplitNoShares   = errors.New("A split needs at least one share.")
+	ErrSplitNegative   = errors.New("Share values can't be negative.")
+)
+
+// Split divides a transaction between people. A nil contact id, for the payer
+// or a share, stands for the household itself.
+type Split struct {
+	Base
+	TransactionID uint         `json:"transaction_id"`
+	HouseholdID   uint         `json:"household_id"`
+	Method        string       `json:"method"`
+	PaidBy        *uint        `json:"paid_by,omitempty"`
+	Shares        []SplitShare `json:"shares"`
+	CreatedBy     uint         `json:"created_by"`
+}
+
+// SplitShare is one person's part of a split. Value is what was entered for
+// the split method, Amount the resulting part of the transaction.
+type SplitShare struct {
+	ID        uint    `json:"id"`
+	SplitID   uint    `json:"split_id"`
+	ContactID *uint   `json:"contact_id,omitempty"`
+	Value     float64 `json:"value"`
+	Amount    float64 `json:"amount"`
+}
+
+// Allocate fills the share amounts for total according to the split method.
+// Amounts are worked out in cents and any rounding remainder goes to the
+// first shares.
+func (s *Split) Allocate(total float64) error {
+	if len(s.Shares) == 0 {
+		return ErrSplitNoShares
+	}
+
+	seen := map[uint]bool{}
+	self := false
+	for _, sh := range s.Shares {
+		if sh.Value < 0 {
+			return ErrSplitNegative
+		}
+		if sh.ContactID == nil {
+			if self {
+				return ErrSplitDuplicate
+			}
+			self = true
+			continue
+		}
+		if seen[*sh.ContactID] {
+			return ErrSplitDuplicate
+		}
+		seen[*sh.ContactID] = true
+	}
+
+	cents := toCents(total)
+	parts := make([]int64, len(s.Shares))
+
+	switch s.Method {
+	case SplitAmount:
+		var sum int64
+		for i, sh := range s.Shares {
+			parts[i] = toCents(sh.Value)
+			sum += parts[i]
+		}
+		if sum != cents {
+			return ErrSplitMismatch
+		}
+	case SplitPercentage:
+		var pct float64
+		for _, sh := range s.Shares {
+			pct += sh.Value
+		}
+		if math.Abs(pct-100) > 0.001 {
+			return ErrSplitPercentage
+		}
+		var sum int64
+		for i, sh := range s.Shares {
+			parts[i] = int64(math.Floor(float64(cents) * sh.Value / 100))
+			sum += parts[i]
+		}
+		spread(parts, cents-sum)
+	default:
+		n := int64(len(s.Shares))
+		for i := range parts {
+			parts[i] = cents / n
+		}
+		spread(parts, cents%n)
+	}
+
+	for i := range s.Shares {
+		s.Shares[i].Amount = float64(parts[i]) / 100
+	}
+	return nil
+}
+
+// Settlement records a payment that settles split debts between two people.
+// A nil contact id stands for the household itself, in which case
+// TransactionID points at the transaction recorded against its account.
+type Settlement struct {
+	ID            uint      `json:"id"`
+	HouseholdID   uint      `json:"household_id"`
+	FromContactID *uint     `json:"from_contact_id,omitempty"`
+	ToContactID   *uint     `json:"to_contact_id,omitempty"`
+	Amount        float64   `json:"amount"`
+	Note          string    `json:"note,omitempty"`
+	TransactionID *uint     `json:"transaction_id,omitempty"`
+	CreatedBy     uint      `json:"created_by"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// SplitBalance is what a person is owed across all splits and settlements of
+// a household; negative when they owe.
+type SplitBalance struct {
+	ContactID *uint   `json:"contact_id"`
+	Name      string  `json:"name"`
+	Amount    float64 `json:"amount"`
+}
+
+// SettleUp is a payment that clears debts.
+type SettleUp struct {
+	FromContactID *uint   `json:"from_contact_id"`
+	FromName      string  `json:"from_name"`
+	ToContactID   *uint   `json:"to_contact_id"`
+	ToName        string  `json:"to_name"`
+	Amount        float64 `json:"amount"`
+}
+
+// simplifyExactLimit caps how many people SimplifyDebts settles exactly; the
+// search is exponential, so bigger groups fall back to greedy payments.
+const simplifyExactLimit = 16
+
+type debtor struct {
+	SplitBalance
+	cents int64
+}
+
+// SimplifyDebts turns balances into the fewest payments that clear them all.
+// People are split into as many groups that even out among themselves as
+// possible, since a group of n always needs n-1 payments, and each group is
+// then settled by letting the biggest debtor pay the biggest creditor. With
+// more than simplifyExactLimit people owing or owed the groups are skipped
+// and the result is greedy rather than minimal.
+func SimplifyDebts(balances []SplitBalance) []SettleUp {
+	var people []*debtor
+	for _, b := range balances {
+		if p := (&debtor{SplitBalance: b, cents: toCents(b.Amount)}); p.cents != 0 {
+			people = append(people, p)
+		}
+	}
+
+	payments := []SettleUp{}
+	for _, group := range zeroSumGroups(people) {
+		payments = append(payments, settleGreedy(group)...)
+	}
+	return payments
+}
+
+// zeroSumGroups partitions people into the largest number of groups whose
+// balances sum to zero.
+func zeroSumGroups(people []*debtor) [][]*debtor {
+	n := len(people)
+	if n > simplifyExactLimit {
+		return [][]*debtor{people}
+	}
+
+	full := 1<<n - 1
+	sum := make([]int64, full+1)
+	groups := make([]int, full+1)
+	for mask := 1; mask <= full; mask++ {
+		low := mask & -mask
+		i := bits.TrailingZeros(uint(low))
+		sum[mask] = sum[mask^low] + people[i].cents
+
+		for j := 0; j < n; j++ {
+			if bit := 1 << j; mask&bit != 0 && groups[mask^bit] > groups[mask] {
+				groups[mask] = groups[mask^bit]
+			}
+		}
+		if sum[mask] == 0 {
+			groups[mask]++
+		}
+	}
+
+	// Walk back from everyone, peeling people off while keeping the group
+	// count; a group closes each time the remaining people even out.
+	var out [][]*debtor
+	var group []*debtor
+	for mask := full; mask != 0; {
+		rest := groups[mask]
+		if sum[mask] == 0 {
+			rest--
+		}
+		for j := 0; j < n; j++ {
+			if bit := 1 << j; mask&bit != 0 && groups[mask^bit] == rest {
+				group = append(group, people[j])
+				mask ^= bit
+				break
+			}
+		}
+		if sum[mask] == 0 {
+			out = append(out, group)
+			group = nil
+		}
+	}
+	return out
+}
+
+// settleGreedy clears a group's balances by repeatedly letting the biggest
+// debtor pay the biggest creditor.
+func settleGreedy(people []*debtor) []SettleUp {
+	var debtors, creditors []*debtor
+	for _, p := range people {
+		switch {
+		case p.cents < 0:
+			p.cents = -p.cents
+			debtors = append(debtors, p)
+		case p.cents > 0:
+			creditors = append(creditors, p)
+		}
+	}
+
+	var payments []SettleUp
+	for len(debtors) > 0 && len(creditors) > 0 {
+		sort.SliceStable(debtors, func(i, j int) bool { return debtors[i].cents > debtors[j].cents })
+		sort.SliceStable(creditors, func(i, j int) bool { return creditors[i].cents > creditors[j].cents })
+
+		from, to := debtors[0], creditors[0]
+		amount := from.cents
+		if to.cents < amount {
+			amount = to.cents
+		}
+
+		payments = append(payments, SettleUp{
+			FromContactID: from.ContactID,
+			FromName:      from.Name,
+			ToContactID:   to.ContactID,
+			ToName:        to.Name,
+			Amount:        float64(amount) / 100,
+		})
+
+		from.cents -= amount
+		to.cents -= amount
+		if from.cents == 0 {
+			debtors = debtors[1:]
+		}
+		if to.cents == 0 {
+			creditors = creditors[1:]
+		}
+	}
+	return payments
+}
+
+func toCents(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
+
+// spread hands out rest cents, one each, starting from the first part.
+func spread(parts []int64, rest int64) {
+	for i := 0; rest > 0; i = (i + 1) % len(parts) {
+		parts[i]++
+		rest--
+	}
+}
+
+// SplitRepository represents the split's repository contract
+type SplitRepository interface {
+	GetByTransactionID(ctx context.Context, transactionID uint) (Split, error)
+	// GetBalances nets the splits of live transactions and the settlements
+	// of a household per person.
+	GetBalances(ctx context.Context, householdID uint) ([]SplitBalance, error)
+
+	// Save and Delete change the split of a transaction at version, moving
+	// the transaction's version on, and fail with ErrVersionConflict when the
+	// transaction has changed since.
+	Save(ctx context.Context, split *Split, version int) (*Split, error)
+	Delete(ctx context.Context, transactionID uint, version int) error
+}
+
+// SettlementRepository represents the settlement's repository contract
+type SettlementRepository interface {
+	GetByHouseholdID(ctx context.Context, householdID uint) ([]Settlement, error)
+	Create(ctx context.Context, stl *Settlement) (*Settlement, error)
+}
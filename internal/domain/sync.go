@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// Kinds of records the sync feed carries.
+const (
+	SyncAccount     = "account"
+	SyncCategory    = "category"
+	SyncBudget      = "budget"
+	SyncTransaction = "transaction"
+)
+
+// Operations a client can push.
+const (
+	SyncUpsert = "upsert"
+	SyncDelete = "delete"
+)
+
+// Outcomes of a pushed mutation.
+const (
+	SyncApplied  = "applied"
+	SyncConflict = "conflict"
+	SyncRejected = "rejected"
+)
+
+var (
+	ErrSyncCursor   = errors.New("since must be a cursor returned by a previous sync.")
+	ErrSyncClientID = errors.New("The client_id is already used for another item.")
+	ErrSyncPending  = errors.New("The item with this client_id is still being created.")
+	ErrSyncRef      = errors.New("A referenced client_id is not known.")
+)
+
+// SyncChange is one record created, updated or deleted after a cursor. Data
+// holds the record's columns and is left out for deletions.
+type SyncChange struct {
+	Type     string          `json:"type"`
+	ID       uint            `json:"id"`
+	ClientID *string         `json:"client_id,omitempty"`
+	Deleted  bool            `json:"deleted"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	Seq      int64           `json:"-"`
+}
+
+// SyncClientID ties an id a client generated offline to the record created
+// for it. EntityID is nil while the record is being created.
+type SyncClientID struct {
+	ClientID    string
+	HouseholdID uint
+	Type        string
+	EntityID    *uint
+}
+
+// SyncRepository represents the sync's repository contract
+type SyncRepository interface {
+	// GetChanges returns up to limit changes of the household with a
+	// sequence number above since, oldest first. A zero since leaves out
+	// what is already deleted. Categories carry userID's override, and
+	// come again when it changes.
+	GetChanges(ctx context.Context, householdID uint, userID uint, since int64, limit int) ([]SyncChange, error)
+
+	GetClientID(ctx context.Context, clientID string) (SyncClientID, error)
+	// ReserveClientID claims a client id before its record is created. It
+	// reports false when the id is already taken, unless it is a reservation
+	// of the same household and type whose record was never saved and that
+	// has been left for longer than a create takes.
+	ReserveClientID(ctx context.Context, id SyncClientID) (bool, error)
+	SetEntityID(ctx context.Context, clientID string, entityID uint) error
+	ReleaseClientID(ctx context.Context, clientID string) error
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Ways a transaction list filter matches several tags.
+const (
+	TagMatchAny = "any"
+	TagMatchAll = "all"
+)
+
+var (
+	ErrTagExists    = errors.New("A tag with that name already exists, merge them instead.")
+	ErrTagMergeSelf = errors.New("A tag can't be merged into itself.")
+)
+
+// Tag is a free-form label transactions of a household can carry.
+type Tag struct {
+	Base
+	Versioned
+	HouseholdID uint   `json:"household_id"`
+	Name        string `json:"name"`
+	CreatedBy   uint   `json:"created_by"`
+}
+
+// TagTotal sums a household's transactions carrying one tag per operation.
+type TagTotal struct {
+	TagID     uint    `json:"tag_id"`
+	Name      string  `json:"name"`
+	Operation string  `json:"operation"`
+	Total     float64 `json:"total"`
+	Count     int     `json:"count"`
+}
+
+// NormalizeTag trims and lower cases a tag name so "Vacation " and
+// "vacation" are the same tag.
+func NormalizeTag(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// NormalizeTags normalizes names, dropping blanks and duplicates.
+func NormalizeTags(names []string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = NormalizeTag(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, name)
+	}
+	return tags
+}
+
+// TagRepository represents the tag's repository contract
+type TagRepository interface {
+	GetByID(ctx context.Context, id uint) (Tag, error)
+	GetByName(ctx context.Context, householdID uint, name string) (Tag, error)
+	GetByHouseholdID(ctx context.Context, householdID uint) ([]Tag, error)
+	// GetTotals sums transactions created in [from, to).
+	GetTotals(ctx context.Context, householdID uint, from time.Time, to time.Time) ([]TagTotal, error)
+
+	Create(ctx context.Context, tag *Tag) (*Tag, error)
+	Update(ctx context.Context, tag *Tag) (*Tag, error)
+	Delete(ctx context.Context, id uint, version int) error
+	// Merge moves every transaction of tag id onto intoID and deletes it.
+	Merge(ctx context.Context, id uint, intoID uint) error
+}
@@ -0,0 +1,119 @@
+package domain
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// TokenPrefix marks a bearer token as a personal access token rather than a JWT.
+const TokenPrefix = "bgt_"
+
+// TokenScopes lists every scope a personal access token can be granted.
+var TokenScopes = []string{
+	"accounts:read",
+	"accounts:write",
+	"audit:read",
+	"budgets:read",
+	"budgets:write",
+	"categories:read",
+	"categories:write",
+	"contacts:read",
+	"contacts:write",
+	"family_members:read",
+	"family_members:write",
+	"households:read",
+	"payees:read",
+	"payees:write",
+	"rules:read",
+	"rules:write",
+	"splits:read",
+	"splits:write",
+	"sync:read",
+	"sync:write",
+	"tags:read",
+	"tags:write",
+	"trash:read",
+	"trash:write",
+	"transactions:read",
+	"transactions:write",
+}
+
+var (
+	ErrTokenExpired = errors.New("The token has expired.")
+	ErrTokenRevoked = errors.New("The token has been revoked.")
+)
+
+type PersonalAccessToken struct {
+	Base
+	Versioned
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedBy  uint       `json:"created_by"`
+	TokenHash  string     `json:"-"`
+	// Token holds the plain text value and is only populated right after creation.
+	Token string `json:"token,omitempty"`
+}
+
+// GenerateToken fills Token, Prefix and TokenHash with a new random secret.
+func (t *PersonalAccessToken) GenerateToken() error {
+	secret, err := NewSecret()
+	if err != nil {
+		return err
+	}
+
+	t.Token = TokenPrefix + secret
+	t.Prefix = t.Token[:len(TokenPrefix)+6]
+	t.TokenHash = HashToken(t.Token)
+	return nil
+}
+
+// Valid reports whether the token can still be used to authenticate.
+func (t PersonalAccessToken) Valid() error {
+	if t.RevokedAt != nil {
+		return ErrTokenRevoked
+	}
+	if t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt) {
+		return ErrTokenExpired
+	}
+	return nil
+}
+
+// NewSecret returns 32 random bytes encoded as unpadded base64url.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, TokenPrefix)
+}
+
+// PersonalAccessTokenRepository represents the personal access token's repository contract
+type PersonalAccessTokenRepository interface {
+	GetByID(ctx context.Context, id uint) (PersonalAccessToken, error)
+	GetByHash(ctx context.Context, hash string) (PersonalAccessToken, error)
+	GetByUserSUB(ctx context.Context, sub uint) ([]PersonalAccessToken, error)
+
+	Create(ctx context.Context, tok *PersonalAccessToken) (*PersonalAccessToken, error)
+	Touch(ctx context.Context, id uint) error
+	Revoke(ctx context.Context, id uint, version int) error
+	RevokeByUserSUB(ctx context.Context, sub uint) error
+}
@@ -2,29 +2,236 @@ package domain
 
 import (
 	"context"
+	"errors"
+	"time"
 )
 
+var ErrLinesMismatch = errors.New("The category lines don't add up to the transaction amount.")
+
 type Transaction struct {
 	Base
-	Category   Category `json:"category,omitempty"`
-	Note       string   `json:"note,omitempty"`
-	Operation  string   `json:"operation"`
-	CreatedBy  uint     `json:"created_by"`
-	Account    Account  `json:"account,omitempty"`
-	Amount     float64  `json:"amount"`
-	AccountID  uint     `json:"-"`
-	CategoryID uint     `json:"-"`
+	Versioned
+	Category    Category `json:"category,omitempty"`
+	Note        string   `json:"note,omitempty"`
+	Operation   string   `json:"operation"`
+	CreatedBy   uint     `json:"created_by"`
+	HouseholdID uint     `json:"household_id"`
+	// FamilyMemberID attributes the spending to a family member profile.
+	FamilyMemberID *uint   `json:"family_member_id,omitempty"`
+	PayeeID        *uint   `json:"payee_id,omitempty"`
+	PayeeName      string  `json:"payee_name,omitempty"`
+	Account        Account `json:"account,omitempty"`
+	Amount         float64 `json:"amount"`
+	AccountID      uint    `json:"-"`
+	CategoryID     uint    `json:"-"`
+	// Lines spread the amount over several categories. Reports and budgets
+	// use them instead of Category when there are any.
+	Lines []TransactionLine `json:"lines,omitempty"`
+	// Tags are names; saving a transaction creates the ones that are new.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// TransactionLine is the part of a transaction that belongs to one category.
+type TransactionLine struct {
+	ID            uint    `json:"id"`
+	TransactionID uint    `json:"transaction_id"`
+	CategoryID    uint    `json:"category_id"`
+	CategoryName  string  `json:"category_name"`
+	Amount        float64 `json:"amount"`
+	Note          string  `json:"note,omitempty"`
+}
+
+// CheckLines reports whether the lines, if any, add up to the amount.
+func (t Transaction) CheckLines() error {
+	if len(t.Lines) == 0 {
+		return nil
+	}
+
+	var sum int64
+	for _, line := range t.Lines {
+		sum += toCents(line.Amount)
+	}
+	if sum != toCents(t.Amount) {
+		return ErrLinesMismatch
+	}
+	return nil
+}
+
+// TransactionFilter narrows a household's transaction list. Nil fields
+// don't filter.
+type TransactionFilter struct {
+	FamilyMemberID *uint
+	PayeeID        *uint
+	AccountID      *uint
+	CategoryID     *uint
+	// Operation keeps one operation; empty keeps them all.
+	Operation string
+	// From and To keep transactions created in [From, To).
+	From *time.Time
+	To   *time.Time
+	// Tags keeps transactions with any of the tags, or all of them when
+	// TagMatch is TagMatchAll.
+	Tags     []string
+	TagMatch string
+}
+
+const (
+	BulkRecategorize = "recategorize"
+	BulkMove         = "move"
+	BulkTag          = "tag"
+	BulkDelete       = "delete"
+)
+
+const (
+	BulkSkipNotFound  = "not_found"
+	BulkSkipForbidden = "forbidden"
+	BulkSkipUnchanged = "unchanged"
+)
+
+// BulkResult summarizes a bulk operation: which of the matched transactions
+// changed and why the others were left alone.
+type BulkResult struct {
+	Action  string     `json:"action"`
+	Matched int        `json:"matched"`
+	Changed []uint     `json:"changed"`
+	Skipped []BulkSkip `json:"skipped"`
+}
+
+type BulkSkip struct {
+	ID     uint   `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// CategoryTotal sums a household's transactions for one category and
+// operation, counting category lines towards their own category. Total only
+// counts the category itself, Rollup adds all of its subcategories.
+type CategoryTotal struct {
+	CategoryID uint    `json:"category_id"`
+	ParentID   *uint   `json:"parent_id,omitempty"`
+	Name       string  `json:"name"`
+	Operation  string  `json:"operation"`
+	Total      float64 `json:"total"`
+	Rollup     float64 `json:"rollup"`
+	Count      int     `json:"count"`
+}
+
+// TransactionVersion is how a transaction looked after one of its saves.
+type TransactionVersion struct {
+	ID             uint              `json:"id"`
+	TransactionID  uint              `json:"transaction_id"`
+	Version        int               `json:"version"`
+	Amount         float64           `json:"amount"`
+	Note           string            `json:"note,omitempty"`
+	Operation      string            `json:"operation"`
+	AccountID      uint              `json:"account_id"`
+	AccountName    string            `json:"account_name,omitempty"`
+	CategoryID     uint              `json:"category_id"`
+	CategoryName   string            `json:"category_name,omitempty"`
+	FamilyMemberID *uint             `json:"family_member_id,omitempty"`
+	PayeeID        *uint             `json:"payee_id,omitempty"`
+	Lines          []TransactionLine `json:"lines,omitempty"`
+	Tags           []string          `json:"tags,omitempty"`
+	EditedBy       *uint             `json:"edited_by,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	// Changes names the fields that differ from the version before.
+	Changes []string `json:"changes,omitempty"`
+}
+
+// TransactionHistory fills in the Changes of versions, which are ordered
+// from oldest to newest.
+func TransactionHistory(versions []TransactionVersion) []TransactionVersion {
+	for i := 1; i < len(versions); i++ {
+		prev, cur := versions[i-1], &versions[i]
+		cur.Changes = []string{}
+
+		if toCents(prev.Amount) != toCents(cur.Amount) {
+			cur.Changes = append(cur.Changes, "amount")
+		}
+		if prev.Note != cur.Note {
+			cur.Changes = append(cur.Changes, "note")
+		}
+		if prev.Operation != cur.Operation {
+			cur.Changes = append(cur.Changes, "operation")
+		}
+		if prev.AccountID != cur.AccountID {
+			cur.Changes = append(cur.Changes, "account")
+		}
+		if prev.CategoryID != cur.CategoryID {
+			cur.Changes = append(cur.Changes, "category")
+		}
+		if !sameID(prev.FamilyMemberID, cur.FamilyMemberID) {
+			cur.Changes = append(cur.Changes, "family_member")
+		}
+		if !sameID(prev.PayeeID, cur.PayeeID) {
+			cur.Changes = append(cur.Changes, "payee")
+		}
+		if !sameLines(prev.Lines, cur.Lines) {
+			cur.Changes = append(cur.Changes, "lines")
+		}
+		if !sameTags(prev.Tags, cur.Tags) {
+			cur.Changes = append(cur.Changes, "tags")
+		}
+	}
+	return versions
+}
+
+func sameID(a *uint, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func sameLines(a []TransactionLine, b []TransactionLine) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].CategoryID != b[i].CategoryID || toCents(a[i].Amount) != toCents(b[i].Amount) || a[i].Note != b[i].Note {
+			return false
+		}
+	}
+	return true
+}
+
+func sameTags(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
 }
 
 // TransactionRepository represents the transactions repository contract
 type TransactionRepository interface {
 	GetByID(ctx context.Context, id uint) (Transaction, error)
-	GetByUserSUB(ctx context.Context, sub string) ([]Transaction, error)
+	GetByHouseholdID(ctx context.Context, householdID uint, filter TransactionFilter) ([]Transaction, error)
+	GetByAccountID(ctx context.Context, accountID uint) ([]Transaction, error)
 	GetOperationType(ctx context.Context) ([]string, error)
+	// GetCategoryTotals sums transactions created in [from, to).
+	GetCategoryTotals(ctx context.Context, householdID uint, from time.Time, to time.Time) ([]CategoryTotal, error)
 	// GetAll(ctx context.Context) ([]Transaction, error)
 
 	// CreateOrUpdate(ctx context.Context, tra *Transaction) error
+
+	// Update also works the transaction's split out again for the new
+	// amount. When fixed share amounts no longer add up it fails with
+	// ErrSplitMismatch and saves nothing.
 	Update(ctx context.Context, tra *Transaction) (*Transaction, error)
 	Create(ctx context.Context, tra *Transaction) (*Transaction, error)
-	Delete(ctx context.Context, id uint) error
+	Delete(ctx context.Context, id uint, version int) error
+	// UpdateMany and DeleteMany change all of the transactions or none, each
+	// at the version it carries, and fail with ErrVersionConflict when any
+	// of them has changed since.
+	UpdateMany(ctx context.Context, trns []Transaction) error
+	DeleteMany(ctx context.Context, trns []Transaction) (int64, error)
+
+	// GetVersions returns the transaction's history, oldest first. Every
+	// Create and Update adds a version.
+	GetVersions(ctx context.Context, id uint) ([]TransactionVersion, error)
+	GetVersion(ctx context.Context, id uint, version int) (TransactionVersion, error)
 }
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+// Kinds of deleted records that can be restored from the trash.
+const (
+	TrashAccount     = "account"
+	TrashCategory    = "category"
+	TrashBudget      = "budget"
+	TrashTransaction = "transaction"
+)
+
+var ErrTrashType = errors.New("type should be one of account, category, budget or transaction.")
+
+// TrashItem is a soft-deleted record waiting to be restored or purged.
+type TrashItem struct {
+	Type        string    `json:"type"`
+	ID          uint      `json:"id"`
+	HouseholdID *uint     `json:"household_id,omitempty"`
+	Name        string    `json:"name"`
+	Amount      *float64  `json:"amount,omitempty"`
+	DeletedAt   time.Time `json:"deleted_at"`
+}
+
+// IsTrashType reports whether kind is one of the Trash* kinds.
+func IsTrashType(kind string) bool {
+	switch kind {
+	case TrashAccount, TrashCategory, TrashBudget, TrashTransaction:
+		return true
+	}
+	return false
+}
+
+// TrashRetention is how long deleted records stay in the trash before they
+// are purged, read from TRASH_RETENTION (default 30 days).
+func TrashRetention() time.Duration {
+	if retention, err := time.ParseDuration(os.Getenv("TRASH_RETENTION")); err == nil {
+		return retention
+	}
+	return time.Hour * 24 * 30
+}
+
+// TrashRepository represents the trash's repository contract
+type TrashRepository interface {
+	// GetByHouseholdID lists the household's deleted records, newest first.
+	// An empty kind lists every kind.
+	GetByHouseholdID(ctx context.Context, householdID uint, kind string) ([]TrashItem, error)
+	GetByID(ctx context.Context, kind string, id uint) (TrashItem, error)
+	// Restore brings a record back along with the deleted account and
+	// categories it needs to count again.
+	Restore(ctx context.Context, kind string, id uint) error
+	// Purge permanently removes records deleted before the given time that
+	// nothing else points at anymore.
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
@@ -2,18 +2,38 @@ package domain
 
 import (
 	"context"
+	"os"
 	"strings"
+	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/Brix101/budgetto-backend/internal/password"
 )
 
 type User struct {
 	Base
+	Versioned
 	Bio      *string `json:"bio,omitempty"`
 	Image    *string `json:"image,omitempty"`
 	Name     string  `json:"name"`
 	Email    string  `json:"email"`
 	Password string  `json:"-"`
+	Role     string  `json:"role"`
+	IsActive bool    `json:"is_active"`
+	// EmailVerifiedAt is set once the user proved they own Email.
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// DeleteAfter is set while the account is scheduled for deletion.
+	DeleteAfter *time.Time `json:"delete_after,omitempty"`
+	// SessionsRevokedAt invalidates refresh tokens issued before it.
+	SessionsRevokedAt *time.Time `json:"-"`
+}
+
+// AccountDeletionGrace is how long a deleted account can still be restored
+// before it is purged, read from ACCOUNT_DELETION_GRACE (default 30 days).
+func AccountDeletionGrace() time.Duration {
+	if grace, err := time.ParseDuration(os.Getenv("ACCOUNT_DELETION_GRACE")); err == nil {
+		return grace
+	}
+	return time.Hour * 24 * 30
 }
 
 func (u *User) NormalizedName() string {
@@ -21,27 +41,79 @@ func (u *User) NormalizedName() string {
 }
 
 func (u *User) HashPassword() error {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(u.Password), 14)
+	hash, err := password.Default().Hash(u.Password)
 	if err != nil {
 		return err
 	}
-	u.Password = string(bytes)
+	u.Password = hash
 	return nil
 }
 
-func (u User) CheckPassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
-	return err == nil
+func (u User) CheckPassword(plain string) bool {
+	ok, err := password.Default().Verify(plain, u.Password)
+	return err == nil && ok
+}
+
+// PasswordNeedsRehash reports whether the stored hash uses an outdated
+// algorithm or parameters and should be replaced after a successful sign in.
+func (u User) PasswordNeedsRehash() bool {
+	return password.Default().NeedsRehash(u.Password)
+}
+
+// UserFilter narrows the admin user listing.
+type UserFilter struct {
+	Query  string
+	Role   string
+	Limit  int
+	Offset int
 }
 
 // UserRepository represents the user's repository contract
 type UserRepository interface {
 	GetByID(ctx context.Context, id uint) (User, error)
 	GetByEmail(ctx context.Context, email string) (User, error)
+	List(ctx context.Context, filter UserFilter) ([]User, error)
 	// GetAll(ctx context.Context) ([]User, error)
 
 	// CreateOrUpdate(ctx context.Context, usr *User) error
 	Update(ctx context.Context, usr *User) (*User, error)
 	Create(ctx context.Context, usr *User) (*User, error)
 	Delete(ctx context.Context, id uint) error
+
+	// SetActive and SetRole change the user at version, failing with
+	// ErrVersionConflict when it has changed since.
+	SetActive(ctx context.Context, id uint, version int, active bool) error
+	// RevokeSessions signs the user out everywhere by rejecting every
+	// refresh token issued so far.
+	RevokeSessions(ctx context.Context, id uint) error
+	SetRole(ctx context.Context, id uint, version int, role string) error
+
+	ScheduleDeletion(ctx context.Context, id uint, version int, at time.Time) error
+	CancelDeletion(ctx context.Context, id uint) error
+	// PurgeScheduled permanently removes users whose grace period has passed,
+	// together with their personal households. What they created in shared
+	// households is handed to another member.
+	PurgeScheduled(ctx context.Context) (int64, error)
+}
+
+// EmailVerification is a pending change of a user's email address.
+type EmailVerification struct {
+	ID        uint       `json:"id"`
+	UserID    uint       `json:"user_id"`
+	Email     string     `json:"email"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// EmailVerificationRepository represents the email verification's repository contract
+type EmailVerificationRepository interface {
+	GetByHash(ctx context.Context, hash string) (EmailVerification, error)
+	Create(ctx context.Context, ver *EmailVerification) (*EmailVerification, error)
+	// Confirm uses the verification and moves its user to the new email in
+	// one transaction. It returns ErrInvalidToken when the verification was
+	// already used or has expired, and ErrEmailTaken when the email now
+	// belongs to someone else.
+	Confirm(ctx context.Context, id uint) error
 }
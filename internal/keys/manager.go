@@ -0,0 +1,253 @@
+package keys
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Brix101/budgetto-backend/internal/jwk"
+)
+
+var (
+	ErrNoActiveKey = errors.New("keys: no active signing key")
+	ErrUnknownKey  = errors.New("keys: token signed with unknown or retired key")
+)
+
+type Status string
+
+const (
+	// StatusActive keys sign new tokens. A manager has exactly one.
+	StatusActive Status = "active"
+	// StatusVerify keys still verify tokens they signed but sign nothing new.
+	StatusVerify Status = "verify"
+	// StatusRetired keys are neither used nor published.
+	StatusRetired Status = "retired"
+)
+
+// Key is a parsed RSA key pair. Private is nil for verify-only keys that were
+// configured with just a public key.
+type Key struct {
+	ID      string
+	Status  Status
+	Private *rsa.PrivateKey
+	Public  *rsa.PublicKey
+}
+
+// Manager signs tokens with its active key and verifies tokens against every
+// key that has not been retired.
+type Manager struct {
+	active *Key
+	keys   map[string]*Key
+	order  []string
+}
+
+func NewManager(keys []Key) (*Manager, error) {
+	m := &Manager{keys: map[string]*Key{}}
+
+	for i := range keys {
+		k := keys[i]
+		if k.Public == nil && k.Private != nil {
+			k.Public = &k.Private.PublicKey
+		}
+		if k.Public == nil {
+			return nil, fmt.Errorf("keys: key %q has no key material", k.ID)
+		}
+		if _, dup := m.keys[k.ID]; dup {
+			return nil, fmt.Errorf("keys: duplicate key id %q", k.ID)
+		}
+
+		if k.Status == StatusActive {
+			if k.Private == nil {
+				return nil, fmt.Errorf("keys: active key %q has no private key", k.ID)
+			}
+			if m.active != nil {
+				return nil, fmt.Errorf("keys: both %q and %q are active", m.active.ID, k.ID)
+			}
+			m.active = &k
+		}
+
+		m.keys[k.ID] = &k
+		m.order = append(m.order, k.ID)
+	}
+
+	if m.active == nil {
+		return nil, ErrNoActiveKey
+	}
+
+	return m, nil
+}
+
+// Sign creates an RS256 token for claims with the active key's kid in the header.
+func (m *Manager) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = m.active.ID
+	return token.SignedString(m.active.Private)
+}
+
+// Keyfunc resolves the verification key for a token. Tokens issued before
+// kids were stamped are checked against every non-retired key.
+func (m *Manager) Keyfunc(t *jwt.Token) (interface{}, error) {
+	if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("keys: unexpected signing method %v", t.Header["alg"])
+	}
+
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		set := jwt.VerificationKeySet{}
+		for _, id := range m.order {
+			if k := m.keys[id]; k.Status != StatusRetired {
+				set.Keys = append(set.Keys, k.Public)
+			}
+		}
+		return set, nil
+	}
+
+	k, ok := m.keys[kid]
+	if !ok || k.Status == StatusRetired {
+		return nil, ErrUnknownKey
+	}
+	return k.Public, nil
+}
+
+// Parse verifies a signed token against the manager's keys.
+func (m *Manager) Parse(raw string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(raw, claims, m.Keyfunc, jwt.WithValidMethods([]string{"RS256"}))
+}
+
+// JWKS returns the public half of every non-retired key.
+func (m *Manager) JWKS() jwk.Set {
+	set := jwk.Set{Keys: []jwk.Key{}}
+	for _, id := range m.order {
+		k := m.keys[id]
+		if k.Status == StatusRetired {
+			continue
+		}
+		if key, err := jwk.FromPublicKey(k.ID, "RS256", k.Public); err == nil {
+			set.Keys = append(set.Keys, key)
+		}
+	}
+	return set
+}
+
+// FromEnv builds a manager from environment variables named after prefix.
+//
+// <PREFIX>_KEYS is a comma separated list of kid:base64-pem entries, where
+// each PEM is a private key or, for verify-only keys, a public key.
+// <PREFIX>_ACTIVE_KID picks the signing key (defaulting to the first entry)
+// and <PREFIX>_RETIRED_KIDS is a comma separated list of keys to drop.
+//
+// Without <PREFIX>_KEYS the single <PREFIX>_PRIVATE_KEY/<PREFIX>_PUBLIC_KEY
+// pair is used, with a kid derived from the public key.
+func FromEnv(prefix string) (*Manager, error) {
+	entries := strings.Split(os.Getenv(prefix+"_KEYS"), ",")
+	if strings.TrimSpace(entries[0]) == "" {
+		return singleFromEnv(prefix)
+	}
+
+	active := os.Getenv(prefix + "_ACTIVE_KID")
+	retired := map[string]bool{}
+	for _, kid := range strings.Split(os.Getenv(prefix+"_RETIRED_KIDS"), ",") {
+		retired[strings.TrimSpace(kid)] = true
+	}
+
+	keys := []Key{}
+	for i, entry := range entries {
+		kid, data, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("keys: malformed %s_KEYS entry %d", prefix, i)
+		}
+
+		k, err := parseKey(kid, data)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case retired[kid]:
+			k.Status = StatusRetired
+		case kid == active || (active == "" && i == 0):
+			k.Status = StatusActive
+		default:
+			k.Status = StatusVerify
+		}
+		keys = append(keys, k)
+	}
+
+	return NewManager(keys)
+}
+
+func singleFromEnv(prefix string) (*Manager, error) {
+	k, err := parseKey("", os.Getenv(prefix+"_PRIVATE_KEY"))
+	if err != nil {
+		return nil, err
+	}
+
+	k.ID = Thumbprint(&k.Private.PublicKey)
+	k.Status = StatusActive
+	return NewManager([]Key{k})
+}
+
+func parseKey(kid string, data string) (Key, error) {
+	pemData, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return Key{}, fmt.Errorf("keys: key %q: %w", kid, err)
+	}
+
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return Key{}, fmt.Errorf("keys: key %q is not PEM encoded", kid)
+	}
+
+	if strings.Contains(block.Type, "PUBLIC") {
+		pub, err := jwt.ParseRSAPublicKeyFromPEM(pemData)
+		if err != nil {
+			return Key{}, fmt.Errorf("keys: key %q: %w", kid, err)
+		}
+		return Key{ID: kid, Public: pub}, nil
+	}
+
+	priv, err := jwt.ParseRSAPrivateKeyFromPEM(pemData)
+	if err != nil {
+		return Key{}, fmt.Errorf("keys: key %q: %w", kid, err)
+	}
+	return Key{ID: kid, Private: priv, Public: &priv.PublicKey}, nil
+}
+
+// Thumbprint derives a stable kid from a public key.
+func Thumbprint(pub *rsa.PublicKey) string {
+	der, _ := x509.MarshalPKIXPublicKey(pub)
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+var (
+	accessOnce  sync.Once
+	access      *Manager
+	accessErr   error
+	refreshOnce sync.Once
+	refresh     *Manager
+	refreshErr  error
+)
+
+// Access returns the process wide manager for access tokens, parsed once
+// from the ACCESS_* environment variables.
+func Access() (*Manager, error) {
+	accessOnce.Do(func() { access, accessErr = FromEnv("ACCESS") })
+	return access, accessErr
+}
+
+// Refresh returns the process wide manager for refresh tokens, parsed once
+// from the REFRESH_* environment variables.
+func Refresh() (*Manager, error) {
+	refreshOnce.Do(func() { refresh, refreshErr = FromEnv("REFRESH") })
+	return refresh, refreshErr
+}
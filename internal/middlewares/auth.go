@@ -2,18 +2,43 @@ package middlewares
 
 import (
 	"context"
-	"encoding/base64"
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Brix101/budgetto-backend/internal/keys"
 )
 
 const BudgetttoCookieKey = "x-budgetto-token"
 
 type AuthCtx struct{}
 
+// TokenVerifier resolves an opaque bearer token into the claims stored on the
+// request context.
+type TokenVerifier func(ctx context.Context, token string) (jwt.MapClaims, error)
+
+var tokenVerifiers = map[string]TokenVerifier{}
+
+// RegisterTokenVerifier makes Auth accept bearer tokens starting with prefix,
+// handing them to fn instead of parsing them as a JWT.
+func RegisterTokenVerifier(prefix string, fn TokenVerifier) {
+	tokenVerifiers[prefix] = fn
+}
+
+// SessionCheck runs on every verified session JWT. It can refuse the token,
+// e.g. for a user who has since been deactivated, or return the claims with
+// fields such as the role refreshed from the database.
+type SessionCheck func(ctx context.Context, claims jwt.MapClaims) (jwt.MapClaims, error)
+
+var sessionCheck SessionCheck
+
+// RegisterSessionCheck makes Auth pass session JWTs through fn before the
+// request goes on.
+func RegisterSessionCheck(fn SessionCheck) {
+	sessionCheck = fn
+}
+
 func Auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
@@ -24,32 +49,77 @@ func Auth(next http.Handler) http.Handler {
 
 		authToken := strings.Replace(authHeader, "Bearer ", "", 1)
 
-		publicKey := os.Getenv("ACCESS_PUBLIC_KEY")
-		keyData, err := base64.StdEncoding.DecodeString(publicKey)
-		if err != nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		for prefix, verify := range tokenVerifiers {
+			if !strings.HasPrefix(authToken, prefix) {
+				continue
+			}
+
+			claims, err := verify(r.Context(), authToken)
+			if err != nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), AuthCtx{}, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
 			return
 		}
 
-		parsedKey, err := jwt.ParseRSAPublicKeyFromPEM([]byte(keyData))
+		manager, err := keys.Access()
 		if err != nil {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		token, err := jwt.Parse(authToken, func(token *jwt.Token) (interface{}, error) {
-			// You should implement your own logic to validate the token and return the appropriate key
-			// For example, you could use a secret key or a public key
-			return parsedKey, nil
-		})
-
+		token, err := manager.Parse(authToken, jwt.MapClaims{})
 		if err != nil || !token.Valid {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), AuthCtx{}, token.Claims)
+		claims, _ := token.Claims.(jwt.MapClaims)
+		if sessionCheck != nil {
+			claims, err = sessionCheck(r.Context(), claims)
+			if err != nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), AuthCtx{}, claims)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// Scope limits requests authenticated with a scoped token to the given
+// resource, requiring "<resource>:read" for safe methods and
+// "<resource>:write" for everything else. Session JWTs carry no scope claim
+// and are allowed through unchanged.
+func Scope(resource string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, _ := r.Context().Value(AuthCtx{}).(jwt.MapClaims)
+
+			scopes, scoped := claims["scope"].([]string)
+			if !scoped {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			want := resource + ":write"
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				want = resource + ":read"
+			}
+
+			for _, s := range scopes {
+				if s == want {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+// Require lets the request through only when the caller's role grants perm.
+// It must run after Auth, which refreshes the role of session tokens from
+// the database. Personal access tokens carry no role and are always refused.
+func Require(perm string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, _ := r.Context().Value(AuthCtx{}).(jwt.MapClaims)
+			role, _ := claims["role"].(string)
+
+			if !domain.HasPermission(role, perm) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
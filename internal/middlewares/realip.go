@@ -0,0 +1,92 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// TrustedProxiesFromEnv reads TRUSTED_PROXIES, a comma separated list of
+// addresses or CIDR ranges of the proxies in front of the API. Entries that
+// don't parse are skipped.
+func TrustedProxiesFromEnv() []*net.IPNet {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			proxies = append(proxies, network)
+		}
+	}
+	return proxies
+}
+
+// RealIP replaces RemoteAddr with the client address from X-Forwarded-For or
+// X-Real-IP, but only when the request arrived from one of the trusted
+// proxies. Anyone else could set those headers to whatever they like, so
+// their requests keep the address they connected from.
+func RealIP(trusted []*net.IPNet) func(http.Handler) http.Handler {
+	isTrusted := func(addr string) bool {
+		ip := net.ParseIP(strings.TrimSpace(addr))
+		if ip == nil {
+			return false
+		}
+		for _, network := range trusted {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+
+			if isTrusted(host) {
+				if ip := forwardedFor(r, isTrusted); ip != "" {
+					r.RemoteAddr = ip
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedFor walks X-Forwarded-For from the nearest hop back, skipping our
+// own proxies, and returns the first address they didn't add themselves.
+// Anything further left was written by the client.
+func forwardedFor(r *http.Request, isTrusted func(string) bool) string {
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				return ""
+			}
+			if !isTrusted(hop) {
+				return hop
+			}
+		}
+		return ""
+	}
+
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	return ""
+}
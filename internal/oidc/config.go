@@ -0,0 +1,40 @@
+package oidc
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ProvidersFromEnv builds the providers listed in OIDC_PROVIDERS, a comma
+// separated list of names. Each name is configured through
+// OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET,
+// OIDC_<NAME>_REDIRECT_URL and the optional space separated OIDC_<NAME>_SCOPES.
+// Providers without an issuer or client id are skipped.
+func ProvidersFromEnv(client *http.Client) map[string]*Provider {
+	providers := map[string]*Provider{}
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		cfg := Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if cfg.Issuer == "" || cfg.ClientID == "" {
+			continue
+		}
+
+		providers[name] = NewProvider(client, cfg)
+	}
+
+	return providers
+}
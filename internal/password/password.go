@@ -0,0 +1,91 @@
+package password
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnknownHash   = errors.New("password: unrecognized hash format")
+	ErrMalformedHash = errors.New("password: malformed hash")
+)
+
+// Hasher hashes passwords into self-describing encoded strings.
+type Hasher interface {
+	// Hash encodes password with the hasher's current parameters.
+	Hash(password string) (string, error)
+	// Verify reports whether password matches an encoded hash this hasher
+	// understands.
+	Verify(password string, encoded string) (bool, error)
+	// Recognizes reports whether encoded was produced by this algorithm.
+	Recognizes(encoded string) bool
+	// NeedsRehash reports whether encoded uses weaker or different
+	// parameters than the hasher is configured with.
+	NeedsRehash(encoded string) bool
+}
+
+// Chain hashes with its first hasher and verifies with whichever hasher
+// recognizes the stored hash, so older algorithms keep working while every
+// successful sign in can upgrade to the preferred one.
+type Chain []Hasher
+
+func (c Chain) Hash(password string) (string, error) {
+	return c[0].Hash(password)
+}
+
+func (c Chain) Verify(password string, encoded string) (bool, error) {
+	for _, h := range c {
+		if h.Recognizes(encoded) {
+			return h.Verify(password, encoded)
+		}
+	}
+	return false, ErrUnknownHash
+}
+
+func (c Chain) Recognizes(encoded string) bool {
+	for _, h := range c {
+		if h.Recognizes(encoded) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Chain) NeedsRehash(encoded string) bool {
+	if !c[0].Recognizes(encoded) {
+		return true
+	}
+	return c[0].NeedsRehash(encoded)
+}
+
+var (
+	defaultOnce   sync.Once
+	defaultHasher Hasher
+)
+
+// Default returns the process wide hasher. PASSWORD_HASHER selects the
+// preferred algorithm ("argon2id", the default, or "bcrypt"); the other stays
+// available for verifying existing hashes.
+func Default() Hasher {
+	defaultOnce.Do(func() {
+		argon := NewArgon2id(Argon2idParamsFromEnv())
+		bc := NewBcrypt(envInt("PASSWORD_BCRYPT_COST", DefaultBcryptCost))
+
+		if strings.EqualFold(os.Getenv("PASSWORD_HASHER"), "bcrypt") {
+			defaultHasher = Chain{bc, argon}
+			return
+		}
+		defaultHasher = Chain{argon, bc}
+	})
+	return defaultHasher
+}
+
+func envInt(key string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+var writeQuery = regexp.MustCompile(`(?i)\b(INSERT|UPDATE|DELETE)\b`)
+
+// auditedConnection runs every write in a transaction that tells the audit
+// triggers who is making the change.
+type auditedConnection struct {
+	Connection
+	info func(context.Context) domain.AuditInfo
+}
+
+// NewAuditedConnection wraps conn so the audit events its writes cause are
+// recorded with the AuditInfo info returns for the write's context.
+func NewAuditedConnection(conn Connection, info func(context.Context) domain.AuditInfo) Connection {
+	return &auditedConnection{Connection: conn, info: info}
+}
+
+func (c *auditedConnection) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := c.Connection.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	info := c.info(ctx)
+
+	actorID := ""
+	if info.ActorID != nil {
+		actorID = strconv.FormatUint(uint64(*info.ActorID), 10)
+	}
+
+	chain := "off"
+	if domain.AuditHashChain() {
+		chain = "on"
+	}
+
+	query := `
+		SELECT
+			set_config('budgetto.actor_id', $1, TRUE),
+			set_config('budgetto.request_id', $2, TRUE),
+			set_config('budgetto.ip', $3, TRUE),
+			set_config('budgetto.audit_chain', $4, TRUE)`
+
+	if _, err := tx.Exec(ctx, query, actorID, info.RequestID, info.IP, chain); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+func (c *auditedConnection) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	if !writeQuery.MatchString(sql) {
+		return c.Connection.Exec(ctx, sql, args...)
+	}
+
+	tx, err := c.Begin(ctx)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return tag, err
+	}
+
+	return tag, tx.Commit(ctx)
+}
+
+func (c *auditedConnection) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if !writeQuery.MatchString(sql) {
+		return c.Connection.Query(ctx, sql, args...)
+	}
+
+	tx, err := c.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	return &auditedRows{Rows: rows, ctx: ctx, tx: tx}, nil
+}
+
+func (c *auditedConnection) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	if !writeQuery.MatchString(sql) {
+		return c.Connection.QueryRow(ctx, sql, args...)
+	}
+
+	tx, err := c.Begin(ctx)
+	if err != nil {
+		return errRow{err}
+	}
+
+	return &auditedRow{Row: tx.QueryRow(ctx, sql, args...), ctx: ctx, tx: tx}
+}
+
+// auditedRows commits its transaction once the rows have been read.
+type auditedRows struct {
+	pgx.Rows
+	ctx  context.Context
+	tx   pgx.Tx
+	done bool
+	err  error
+}
+
+func (r *auditedRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.finish()
+	return false
+}
+
+func (r *auditedRows) Close() {
+	r.Rows.Close()
+	r.finish()
+}
+
+func (r *auditedRows) Err() error {
+	if err := r.Rows.Err(); err != nil {
+		return err
+	}
+	return r.err
+}
+
+func (r *auditedRows) finish() {
+	if r.done {
+		return
+	}
+	r.done = true
+
+	if r.Rows.Err() != nil {
+		_ = r.tx.Rollback(r.ctx)
+		return
+	}
+	r.err = r.tx.Commit(r.ctx)
+}
+
+// auditedRow commits its transaction once the row has been scanned.
+type auditedRow struct {
+	pgx.Row
+	ctx context.Context
+	tx  pgx.Tx
+}
+
+func (r *auditedRow) Scan(dest ...any) error {
+	if err := r.Row.Scan(dest...); err != nil {
+		_ = r.tx.Rollback(r.ctx)
+		return err
+	}
+	return r.tx.Commit(r.ctx)
+}
+
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(...any) error {
+	return r.err
+}
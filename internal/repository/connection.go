@@ -2,17 +2,21 @@ package repository
 
 import (
 	"context"
+	"errors"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
 )
 
 type Connection interface {
 	Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error)
 	Query(context.Context, string, ...interface{}) (pgx.Rows, error)
 	QueryRow(context.Context, string, ...interface{}) pgx.Row
+	Begin(context.Context) (pgx.Tx, error)
 }
 
 func spanWithQuery(ctx context.Context, tracer trace.Tracer, query string) (context.Context, trace.Span) {
@@ -20,3 +24,12 @@ func spanWithQuery(ctx context.Context, tracer trace.Tracer, query string) (cont
 	span.SetAttributes(semconv.DBStatementKey.String(query))
 	return ctx, span
 }
+
+// versionError maps an update that matched no row, because the version it was
+// guarded with is stale, to domain.ErrVersionConflict.
+func versionError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrVersionConflict
+	}
+	return err
+}
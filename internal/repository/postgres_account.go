@@ -41,8 +41,11 @@ func (p *postgresAccountRepository) fetch(ctx context.Context, query string, arg
 			&acc.Balance,
 			&acc.Note,
 			&acc.CreatedBy,
+			&acc.HouseholdID,
+			&acc.FamilyMemberID,
 			&acc.CreatedAt,
 			&acc.UpdatedAt,
+			&acc.Version,
 		); err != nil {
 			return nil, err
 		}
@@ -59,8 +62,11 @@ func (p *postgresAccountRepository) GetByID(ctx context.Context, id uint) (domai
             balance,
 			note,
 			created_by,
+			household_id,
+			family_member_id,
 			created_at,
-			updated_at
+			updated_at,
+			version
 		FROM 
             accounts
 		WHERE 
@@ -78,7 +84,7 @@ func (p *postgresAccountRepository) GetByID(ctx context.Context, id uint) (domai
 	return accs[0], nil
 }
 
-func (p *postgresAccountRepository) GetByUserSUB(ctx context.Context, sub string) ([]domain.Account, error) {
+func (p *postgresAccountRepository) GetByHouseholdID(ctx context.Context, householdID uint) ([]domain.Account, error) {
 	query := `
 		SELECT
 			id,
@@ -86,17 +92,20 @@ func (p *postgresAccountRepository) GetByUserSUB(ctx context.Context, sub string
             balance,
 			note,
 			created_by,
+			household_id,
+			family_member_id,
 			created_at,
-			updated_at
+			updated_at,
+			version
 		FROM
 			accounts
 		WHERE
-			created_by = $1 AND
+			household_id = $1 AND
 			is_deleted = FALSE
 		ORDER BY
 			name ASC`
 
-	accs, err := p.fetch(ctx, query, sub)
+	accs, err := p.fetch(ctx, query, householdID)
 	if err != nil {
 		return []domain.Account{}, err
 	}
@@ -107,9 +116,9 @@ func (p *postgresAccountRepository) GetByUserSUB(ctx context.Context, sub string
 func (p *postgresAccountRepository) Create(ctx context.Context, acc *domain.Account) (*domain.Account, error) {
 	query := `
 		INSERT INTO accounts
-			(name, balance, note, created_by)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, created_at, updated_at`
+			(name, balance, note, created_by, household_id, family_member_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at, version`
 
 	ctx, span := spanWithQuery(ctx, p.tracer, query)
 	defer span.End()
@@ -121,10 +130,13 @@ func (p *postgresAccountRepository) Create(ctx context.Context, acc *domain.Acco
 		acc.Balance,
 		acc.Note,
 		acc.CreatedBy,
+		acc.HouseholdID,
+		acc.FamilyMemberID,
 	).Scan(
 		&acc.ID,
 		&acc.CreatedAt,
-		&acc.UpdatedAt); err != nil {
+		&acc.UpdatedAt,
+		&acc.Version); err != nil {
 		span.SetStatus(codes.Error, "failed inserting account")
 		span.RecordError(err)
 		return nil, err
@@ -140,10 +152,12 @@ func (p *postgresAccountRepository) Update(ctx context.Context, acc *domain.Acco
 			name = $2,
 			balance = $3,
 			note = $4,
+			family_member_id = $5,
 			updated_at = NOW()
 		WHERE 
 			id = $1
-		RETURNING updated_at`
+			AND version = $6
+		RETURNING updated_at, version`
 
 	ctx, span := spanWithQuery(ctx, p.tracer, query)
 	defer span.End()
@@ -155,30 +169,34 @@ func (p *postgresAccountRepository) Update(ctx context.Context, acc *domain.Acco
 		acc.Name,
 		acc.Balance,
 		acc.Note,
+		acc.FamilyMemberID,
+		acc.Version,
 	)
 
-	if err := row.Scan(&acc.UpdatedAt); err != nil {
+	if err := row.Scan(&acc.UpdatedAt, &acc.Version); err != nil {
 		span.SetStatus(codes.Error, "failed to update account")
 		span.RecordError(err)
-		return nil, err
+		return nil, versionError(err)
 	}
 
 	return acc, nil
 }
 
-func (p *postgresAccountRepository) Delete(ctx context.Context, id int64) error {
+func (p *postgresAccountRepository) Delete(ctx context.Context, id int64, version int) error {
 	query := `
 		UPDATE accounts
 		SET 
 			is_deleted = TRUE,
+			deleted_at = NOW(),
 			updated_at = NOW()
 		WHERE 
-			id = $1`
+			id = $1
+			AND version = $2`
 
 	ctx, span := spanWithQuery(ctx, p.tracer, query)
 	defer span.End()
 
-	result, err := p.conn.Exec(ctx, query, id)
+	result, err := p.conn.Exec(ctx, query, id, version)
 	if err != nil {
 		span.SetStatus(codes.Error, "failed to delete account")
 		span.RecordError(err)
@@ -187,7 +205,7 @@ func (p *postgresAccountRepository) Delete(ctx context.Context, id int64) error
 
 	rowsAffected := result.RowsAffected()
 	if rowsAffected == 0 {
-		return domain.ErrNotFound
+		return domain.ErrVersionConflict
 	}
 
 	return nil
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type postgresAuditRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresAudit(conn Connection) domain.AuditRepository {
+	tracer := otel.Tracer("db:postgres:audit_events")
+	return &postgresAuditRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresAuditRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.AuditEvent, error) {
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying audit events")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []domain.AuditEvent{}
+	for rows.Next() {
+		var e domain.AuditEvent
+		if err := rows.Scan(
+			&e.ID,
+			&e.HouseholdID,
+			&e.ActorID,
+			&e.EntityType,
+			&e.EntityID,
+			&e.Action,
+			&e.Before,
+			&e.After,
+			&e.RequestID,
+			&e.IP,
+			&e.PrevHash,
+			&e.Hash,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+func (p *postgresAuditRepository) GetByHouseholdID(ctx context.Context, householdID uint, filter domain.AuditFilter) ([]domain.AuditEvent, error) {
+	query := `
+		SELECT
+			id,
+			household_id,
+			actor_id,
+			entity_type,
+			entity_id,
+			action,
+			before,
+			after,
+			request_id,
+			ip,
+			prev_hash,
+			hash,
+			created_at
+		FROM
+			audit_events
+		WHERE
+			household_id = $1
+			AND ($2 = '' OR entity_type = $2)
+			AND ($3::INTEGER IS NULL OR entity_id = $3)
+			AND ($4::TIMESTAMPTZ IS NULL OR created_at >= $4)
+			AND ($5::TIMESTAMPTZ IS NULL OR created_at < $5)
+		ORDER BY
+			id DESC
+		LIMIT $6`
+
+	events, err := p.fetch(
+		ctx,
+		query,
+		householdID,
+		filter.EntityType,
+		filter.EntityID,
+		filter.From,
+		filter.To,
+		filter.Limit,
+	)
+	if err != nil {
+		return []domain.AuditEvent{}, err
+	}
+
+	return events, nil
+}
+
+func (p *postgresAuditRepository) Verify(ctx context.Context) (domain.AuditVerification, error) {
+	// Every chained event must hash to its stored hash and point at the
+	// chained event before it.
+	query := `
+		SELECT
+			id,
+			hash = audit_hash(E)
+				AND prev_hash = COALESCE(LAG(hash) OVER (ORDER BY id), '')
+		FROM
+			audit_events E
+		WHERE
+			hash <> ''
+		ORDER BY
+			id ASC`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed verifying audit events")
+		span.RecordError(err)
+		return domain.AuditVerification{}, err
+	}
+	defer rows.Close()
+
+	res := domain.AuditVerification{Valid: true}
+	for rows.Next() {
+		var id uint
+		var ok bool
+		if err := rows.Scan(&id, &ok); err != nil {
+			return domain.AuditVerification{}, err
+		}
+		res.Checked++
+
+		if !ok {
+			res.Valid = false
+			res.BrokenAt = &id
+			break
+		}
+	}
+
+	return res, rows.Err()
+}
@@ -40,8 +40,11 @@ func (p *postgresBudgetRepository) fetch(ctx context.Context, query string, args
 			&bud.Amount,
 			&bud.CategoryID,
 			&bud.CreatedBy,
+			&bud.HouseholdID,
 			&bud.CreatedAt,
 			&bud.UpdatedAt,
+			&bud.Version,
+			&bud.Spent,
 			&cat.ID,
 			&cat.Name,
 			&cat.Note,
@@ -63,8 +66,20 @@ func (p *postgresBudgetRepository) GetByID(ctx context.Context, id uint) (domain
 			b.amount,
 			b.category_id,
 			b.created_by,
+			b.household_id,
 			b.created_at,
 			b.updated_at,
+			b.version,
+			COALESCE((
+				SELECT SUM(TA.amount)
+				FROM transaction_allocations TA
+				WHERE
+					TA.household_id = b.household_id
+					AND TA.category_id IN (SELECT descendant_id FROM category_closure WHERE ancestor_id = b.category_id)
+					AND TA.operation = 'Expense'
+					AND TA.is_deleted = FALSE
+					AND TA.created_at >= date_trunc('month', NOW())
+			), 0) AS spent,
 			C.ID AS category_id,
 			C.NAME AS category_name,
 			C.note AS category_note,
@@ -88,15 +103,27 @@ func (p *postgresBudgetRepository) GetByID(ctx context.Context, id uint) (domain
 	return buds[0], nil
 }
 
-func (p *postgresBudgetRepository) GetByUserSUB(ctx context.Context, sub string) ([]domain.Budget, error) {
+func (p *postgresBudgetRepository) GetByHouseholdID(ctx context.Context, householdID uint) ([]domain.Budget, error) {
 	query := `
 		SELECT
 			b.ID,
 			b.amount,
 			b.category_id,
 			b.created_by,
+			b.household_id,
 			b.created_at,
 			b.updated_at,
+			b.version,
+			COALESCE((
+				SELECT SUM(TA.amount)
+				FROM transaction_allocations TA
+				WHERE
+					TA.household_id = b.household_id
+					AND TA.category_id IN (SELECT descendant_id FROM category_closure WHERE ancestor_id = b.category_id)
+					AND TA.operation = 'Expense'
+					AND TA.is_deleted = FALSE
+					AND TA.created_at >= date_trunc('month', NOW())
+			), 0) AS spent,
 			C.ID AS category_id,
 			C.NAME AS category_name,
 			C.note AS category_note,
@@ -106,12 +133,12 @@ func (p *postgresBudgetRepository) GetByUserSUB(ctx context.Context, sub string)
 			budgets b
 			JOIN categories C ON b.category_id = C.ID 
 		WHERE
-			b.created_by = $1 
+			b.household_id = $1 
 			AND b.is_deleted = FALSE 
 		ORDER BY
 			C.NAME ASC;`
 
-	buds, err := p.fetch(ctx, query, sub)
+	buds, err := p.fetch(ctx, query, householdID)
 	if err != nil {
 		return []domain.Budget{}, err
 	}
@@ -122,9 +149,9 @@ func (p *postgresBudgetRepository) GetByUserSUB(ctx context.Context, sub string)
 func (p *postgresBudgetRepository) Create(ctx context.Context, bud *domain.Budget) (*domain.Budget, error) {
 	query := `
 		INSERT INTO budgets
-			(amount, category_id, created_by)
-		VALUES ($1, $2, $3)
-		RETURNING id, created_at, updated_at`
+			(amount, category_id, created_by, household_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at, version`
 
 	ctx, span := spanWithQuery(ctx, p.tracer, query)
 	defer span.End()
@@ -135,10 +162,12 @@ func (p *postgresBudgetRepository) Create(ctx context.Context, bud *domain.Budge
 		bud.Amount,
 		bud.CategoryID,
 		bud.CreatedBy,
+		bud.HouseholdID,
 	).Scan(
 		&bud.ID,
 		&bud.CreatedAt,
-		&bud.UpdatedAt); err != nil {
+		&bud.UpdatedAt,
+		&bud.Version); err != nil {
 		span.SetStatus(codes.Error, "failed inserting budget")
 		span.RecordError(err)
 		return nil, err
@@ -156,7 +185,8 @@ func (p *postgresBudgetRepository) Update(ctx context.Context, bud *domain.Budge
 			updated_at = NOW()
 		WHERE 
 			id = $1
-		RETURNING updated_at`
+			AND version = $4
+		RETURNING updated_at, version`
 
 	ctx, span := spanWithQuery(ctx, p.tracer, query)
 	defer span.End()
@@ -167,30 +197,33 @@ func (p *postgresBudgetRepository) Update(ctx context.Context, bud *domain.Budge
 		bud.ID,
 		bud.Amount,
 		bud.CategoryID,
+		bud.Version,
 	)
 
-	if err := row.Scan(&bud.UpdatedAt); err != nil {
+	if err := row.Scan(&bud.UpdatedAt, &bud.Version); err != nil {
 		span.SetStatus(codes.Error, "failed to update budget")
 		span.RecordError(err)
-		return nil, err
+		return nil, versionError(err)
 	}
 
 	return bud, nil
 }
 
-func (p *postgresBudgetRepository) Delete(ctx context.Context, id uint) error {
+func (p *postgresBudgetRepository) Delete(ctx context.Context, id uint, version int) error {
 	query := `
 		UPDATE budgets
 		SET 
 			is_deleted = TRUE,
+			deleted_at = NOW(),
 			updated_at = NOW()
 		WHERE 
-			id = $1`
+			id = $1
+			AND version = $2`
 
 	ctx, span := spanWithQuery(ctx, p.tracer, query)
 	defer span.End()
 
-	result, err := p.conn.Exec(ctx, query, id)
+	result, err := p.conn.Exec(ctx, query, id, version)
 	if err != nil {
 		span.SetStatus(codes.Error, "failed to delete budget")
 		span.RecordError(err)
@@ -199,7 +232,7 @@ func (p *postgresBudgetRepository) Delete(ctx context.Context, id uint) error {
 
 	rowsAffected := result.RowsAffected()
 	if rowsAffected == 0 {
-		return domain.ErrNotFound
+		return domain.ErrVersionConflict
 	}
 
 	return nil
@@ -2,7 +2,9 @@ package repository
 
 import (
 	"context"
+	"errors"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
@@ -20,6 +22,16 @@ func NewPostgresCategory(conn Connection) domain.CategoryRepository {
 	return &postgresCategoryRepository{conn: conn, tracer: tracer}
 }
 
+// categoryError turns the category_parent_guard trigger refusing a parent
+// into ErrCategoryCycle.
+func categoryError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName == "category_parent_cycle" {
+		return domain.ErrCategoryCycle
+	}
+	return err
+}
+
 func (p *postgresCategoryRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.Category, error) {
 	ctx, span := spanWithQuery(ctx, p.tracer, query)
 	defer span.End()
@@ -41,8 +53,11 @@ func (p *postgresCategoryRepository) fetch(ctx context.Context, query string, ar
 			&cat.Name,
 			&cat.Note,
 			&cat.CreatedBy,
+			&cat.HouseholdID,
+			&cat.ParentID,
 			&cat.CreatedAt,
 			&cat.UpdatedAt,
+			&cat.Version,
 		); err != nil {
 			return nil, err
 		}
@@ -59,8 +74,11 @@ func (p *postgresCategoryRepository) GetByID(ctx context.Context, id uint) (doma
 			name,
 			note,
 			created_by,
+			household_id,
+			parent_id,
 			created_at,
-			updated_at
+			updated_at,
+			version
 		FROM
 			categories
 		WHERE
@@ -79,25 +97,55 @@ func (p *postgresCategoryRepository) GetByID(ctx context.Context, id uint) (doma
 	return cat[0], nil
 }
 
-func (p *postgresCategoryRepository) GetByUserSUB(ctx context.Context, sub uint) ([]domain.Category, error) {
+func (p *postgresCategoryRepository) GetByHouseholdID(ctx context.Context, householdID uint) ([]domain.Category, error) {
+	query := `
+		SELECT
+			id,
+			name,
+			note,
+			created_by,
+			household_id,
+			parent_id,
+			created_at,
+			updated_at,
+			version
+		FROM
+			categories
+		WHERE
+			(household_id IS NULL OR household_id = $1)
+			AND is_deleted = FALSE
+		ORDER BY
+			name ASC`
+
+	cats, err := p.fetch(ctx, query, householdID)
+	if err != nil {
+		return []domain.Category{}, err
+	}
+
+	return cats, nil
+}
+
+func (p *postgresCategoryRepository) GetGlobal(ctx context.Context) ([]domain.Category, error) {
 	query := `
 		SELECT
 			id,
 			name,
 			note,
 			created_by,
+			household_id,
+			parent_id,
 			created_at,
-			updated_at
+			updated_at,
+			version
 		FROM
 			categories
 		WHERE
-			created_by IS NULL OR
-			created_by = $1 AND
-			is_deleted = FALSE
+			household_id IS NULL
+			AND is_deleted = FALSE
 		ORDER BY
 			name ASC`
 
-	cats, err := p.fetch(ctx, query, sub)
+	cats, err := p.fetch(ctx, query)
 	if err != nil {
 		return []domain.Category{}, err
 	}
@@ -137,9 +185,9 @@ func (p *postgresCategoryRepository) GetByUserSUB(ctx context.Context, sub uint)
 
 func (p *postgresCategoryRepository) Create(ctx context.Context, cat *domain.Category) (*domain.Category, error) {
 	query := `
-		INSERT INTO categories (name, note, created_by)
-		VALUES ($1, $2, $3)
-		RETURNING id, created_at, updated_at`
+		INSERT INTO categories (name, note, created_by, household_id, parent_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at, version`
 
 	ctx, span := spanWithQuery(ctx, p.tracer, query)
 	defer span.End()
@@ -150,13 +198,16 @@ func (p *postgresCategoryRepository) Create(ctx context.Context, cat *domain.Cat
 		cat.Name,
 		cat.Note,
 		cat.CreatedBy,
+		cat.HouseholdID,
+		cat.ParentID,
 	).Scan(
 		&cat.ID,
 		&cat.CreatedAt,
-		&cat.UpdatedAt); err != nil {
+		&cat.UpdatedAt,
+		&cat.Version); err != nil {
 		span.SetStatus(codes.Error, "failed inserting categories")
 		span.RecordError(err)
-		return nil, err
+		return nil, categoryError(err)
 	}
 
 	return cat, nil
@@ -168,10 +219,12 @@ func (p *postgresCategoryRepository) Update(ctx context.Context, cat *domain.Cat
 		SET 
 			name = $2,
 			note = $3,
+			parent_id = $4,
 			updated_at = NOW()
 		WHERE 
 			id = $1
-		RETURNING updated_at`
+			AND version = $5
+		RETURNING updated_at, version`
 
 	ctx, span := spanWithQuery(ctx, p.tracer, query)
 	defer span.End()
@@ -182,40 +235,188 @@ func (p *postgresCategoryRepository) Update(ctx context.Context, cat *domain.Cat
 		cat.ID,
 		cat.Name,
 		cat.Note,
+		cat.ParentID,
+		cat.Version,
 	)
 
-	if err := row.Scan(&cat.UpdatedAt); err != nil {
+	if err := row.Scan(&cat.UpdatedAt, &cat.Version); err != nil {
 		span.SetStatus(codes.Error, "failed to update category")
 		span.RecordError(err)
-		return nil, err
+		return nil, categoryError(versionError(err))
 	}
 
 	return cat, nil
 }
 
-func (p *postgresCategoryRepository) Delete(ctx context.Context, id uint) error {
+func (p *postgresCategoryRepository) Delete(ctx context.Context, id uint, version int) error {
+	// Subcategories move up to the deleted category's parent.
+	query := `
+		WITH deleted AS (
+			UPDATE categories
+			SET 
+				is_deleted = TRUE,
+				deleted_at = NOW(),
+				updated_at = NOW()
+			WHERE 
+				id = $1
+				AND version = $2
+			RETURNING id, parent_id
+		), moved AS (
+			UPDATE categories C
+			SET parent_id = D.parent_id
+			FROM deleted D
+			WHERE C.parent_id = D.id
+		)
+		SELECT id FROM deleted`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, id, version)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to delete category")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrVersionConflict
+	}
+
+	return nil
+}
+
+func (p *postgresCategoryRepository) GetAncestorIDs(ctx context.Context, id uint) ([]uint, error) {
+	query := `
+		SELECT
+			ancestor_id
+		FROM
+			category_closure
+		WHERE
+			descendant_id = $1`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, id)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying category ancestors")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uint{}
+	for rows.Next() {
+		var ancestorID uint
+		if err := rows.Scan(&ancestorID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, ancestorID)
+	}
+
+	return ids, nil
+}
+
+func (p *postgresCategoryRepository) GetUsage(ctx context.Context, id uint) (domain.CategoryUsage, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(DISTINCT transaction_id) FROM transaction_allocations
+				WHERE category_id = $1 AND is_deleted = FALSE),
+			(SELECT COUNT(*) FROM budgets
+				WHERE category_id = $1 AND is_deleted = FALSE),
+			(SELECT COUNT(*) FROM rules
+				WHERE set_category_id = $1)`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	var usage domain.CategoryUsage
+	if err := p.conn.QueryRow(ctx, query, id).Scan(
+		&usage.Transactions,
+		&usage.Budgets,
+		&usage.Rules,
+	); err != nil {
+		span.SetStatus(codes.Error, "failed querying category usage")
+		span.RecordError(err)
+		return domain.CategoryUsage{}, err
+	}
+
+	return usage, nil
+}
+
+func (p *postgresCategoryRepository) Merge(ctx context.Context, id uint, intoID uint) error {
+	tx, err := p.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// A household that budgets both categories ends up with one budget
+	// holding both amounts.
+	queries := []string{
+		`UPDATE transactions SET category_id = $2 WHERE category_id = $1`,
+		`UPDATE transaction_lines SET category_id = $2 WHERE category_id = $1`,
+		`UPDATE rules SET set_category_id = $2 WHERE set_category_id = $1`,
+		`UPDATE budgets T
+		SET
+			amount = CASE WHEN T.is_deleted THEN 0 ELSE COALESCE(T.amount, 0) END + COALESCE(S.amount, 0),
+			is_deleted = FALSE,
+			deleted_at = NULL,
+			updated_at = NOW()
+		FROM budgets S
+		WHERE
+			S.category_id = $1
+			AND S.is_deleted = FALSE
+			AND T.category_id = $2
+			AND T.household_id = S.household_id`,
+		`DELETE FROM budgets S
+		WHERE
+			S.category_id = $1
+			AND EXISTS (
+				SELECT 1 FROM budgets T
+				WHERE T.category_id = $2 AND T.household_id = S.household_id
+			)`,
+		`UPDATE budgets SET category_id = $2, updated_at = NOW() WHERE category_id = $1`,
+		`DELETE FROM category_overrides WHERE category_id = $1`,
+		`UPDATE categories SET parent_id = $2 WHERE parent_id = $1 AND id <> $2`,
+	}
+
+	for _, query := range queries {
+		ctx, span := spanWithQuery(ctx, p.tracer, query)
+		if _, err := tx.Exec(ctx, query, id, intoID); err != nil {
+			span.SetStatus(codes.Error, "failed to merge category")
+			span.RecordError(err)
+			span.End()
+			return err
+		}
+		span.End()
+	}
+
 	query := `
 		UPDATE categories
 		SET 
 			is_deleted = TRUE,
+			deleted_at = NOW(),
 			updated_at = NOW()
 		WHERE 
-			id = $1`
+			id = $1
+			AND is_deleted = FALSE`
 
 	ctx, span := spanWithQuery(ctx, p.tracer, query)
 	defer span.End()
 
-	result, err := p.conn.Exec(ctx, query, id)
+	result, err := tx.Exec(ctx, query, id)
 	if err != nil {
-		span.SetStatus(codes.Error, "failed to delete category")
+		span.SetStatus(codes.Error, "failed to merge category")
 		span.RecordError(err)
 		return err
 	}
 
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
+	if result.RowsAffected() == 0 {
 		return domain.ErrNotFound
 	}
 
-	return nil
+	return tx.Commit(ctx)
 }
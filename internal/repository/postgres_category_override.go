@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type postgresCategoryOverrideRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresCategoryOverride(conn Connection) domain.CategoryOverrideRepository {
+	tracer := otel.Tracer("db:postgres:category_overrides")
+	return &postgresCategoryOverrideRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresCategoryOverrideRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.CategoryOverride, error) {
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying category overrides")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := []domain.CategoryOverride{}
+	for rows.Next() {
+		var o domain.CategoryOverride
+		if err := rows.Scan(
+			&o.UserID,
+			&o.CategoryID,
+			&o.Hidden,
+			&o.Name,
+			&o.Icon,
+			&o.Color,
+			&o.SortOrder,
+			&o.UpdatedAt,
+			&o.Version,
+		); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, nil
+}
+
+func (p *postgresCategoryOverrideRepository) GetByUserID(ctx context.Context, userID uint) ([]domain.CategoryOverride, error) {
+	query := `
+		SELECT
+			user_id,
+			category_id,
+			hidden,
+			name,
+			icon,
+			color,
+			sort_order,
+			updated_at,
+			version
+		FROM
+			category_overrides
+		WHERE
+			user_id = $1`
+
+	overrides, err := p.fetch(ctx, query, userID)
+	if err != nil {
+		return []domain.CategoryOverride{}, err
+	}
+
+	return overrides, nil
+}
+
+func (p *postgresCategoryOverrideRepository) Get(ctx context.Context, userID uint, categoryID uint) (domain.CategoryOverride, error) {
+	query := `
+		SELECT
+			user_id,
+			category_id,
+			hidden,
+			name,
+			icon,
+			color,
+			sort_order,
+			updated_at,
+			version
+		FROM
+			category_overrides
+		WHERE
+			user_id = $1
+			AND category_id = $2`
+
+	overrides, err := p.fetch(ctx, query, userID, categoryID)
+	if err != nil {
+		return domain.CategoryOverride{}, err
+	}
+
+	if len(overrides) == 0 {
+		return domain.CategoryOverride{}, domain.ErrNotFound
+	}
+	return overrides[0], nil
+}
+
+// Save creates the override when o.Version is zero and otherwise updates the
+// stored one at that version. Either way a concurrent change is reported as
+// domain.ErrVersionConflict.
+func (p *postgresCategoryOverrideRepository) Save(ctx context.Context, o *domain.CategoryOverride) (*domain.CategoryOverride, error) {
+	query := `
+		INSERT INTO category_overrides
+			(user_id, category_id, hidden, name, icon, color, sort_order)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id, category_id) DO NOTHING
+		RETURNING updated_at, version`
+	args := []interface{}{o.UserID, o.CategoryID, o.Hidden, o.Name, o.Icon, o.Color, o.SortOrder}
+
+	if o.Version > 0 {
+		query = `
+		UPDATE category_overrides
+		SET
+			hidden = $3,
+			name = $4,
+			icon = $5,
+			color = $6,
+			sort_order = $7,
+			updated_at = NOW()
+		WHERE
+			user_id = $1
+			AND category_id = $2
+			AND version = $8
+		RETURNING updated_at, version`
+		args = append(args, o.Version)
+	}
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if err := p.conn.QueryRow(ctx, query, args...).Scan(&o.UpdatedAt, &o.Version); err != nil {
+		span.SetStatus(codes.Error, "failed saving category override")
+		span.RecordError(err)
+		return nil, versionError(err)
+	}
+
+	return o, nil
+}
+
+func (p *postgresCategoryOverrideRepository) Delete(ctx context.Context, userID uint, categoryID uint, version int) error {
+	query := `
+		DELETE FROM category_overrides
+		WHERE
+			user_id = $1
+			AND category_id = $2
+			AND version = $3`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, userID, categoryID, version)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to delete category override")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrVersionConflict
+	}
+
+	return nil
+}
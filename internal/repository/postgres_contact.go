@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type postgresContactRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresContact(conn Connection) domain.ContactRepository {
+	tracer := otel.Tracer("db:postgres:contacts")
+	return &postgresContactRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresContactRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.Contact, error) {
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying contacts")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	cons := []domain.Contact{}
+	for rows.Next() {
+		var con domain.Contact
+		if err := rows.Scan(
+			&con.ID,
+			&con.HouseholdID,
+			&con.Name,
+			&con.Email,
+			&con.CreatedBy,
+			&con.CreatedAt,
+			&con.UpdatedAt,
+			&con.Version,
+		); err != nil {
+			return nil, err
+		}
+		cons = append(cons, con)
+	}
+	return cons, nil
+}
+
+func (p *postgresContactRepository) GetByID(ctx context.Context, id uint) (domain.Contact, error) {
+	query := `
+		SELECT
+			id,
+			household_id,
+			name,
+			email,
+			created_by,
+			created_at,
+			updated_at,
+			version
+		FROM
+			contacts
+		WHERE
+			id = $1
+			AND is_deleted = FALSE`
+
+	cons, err := p.fetch(ctx, query, id)
+	if err != nil {
+		return domain.Contact{}, err
+	}
+
+	if len(cons) == 0 {
+		return domain.Contact{}, domain.ErrNotFound
+	}
+	return cons[0], nil
+}
+
+func (p *postgresContactRepository) GetByHouseholdID(ctx context.Context, householdID uint) ([]domain.Contact, error) {
+	query := `
+		SELECT
+			id,
+			household_id,
+			name,
+			email,
+			created_by,
+			created_at,
+			updated_at,
+			version
+		FROM
+			contacts
+		WHERE
+			household_id = $1
+			AND is_deleted = FALSE
+		ORDER BY
+			name ASC`
+
+	cons, err := p.fetch(ctx, query, householdID)
+	if err != nil {
+		return []domain.Contact{}, err
+	}
+
+	return cons, nil
+}
+
+func (p *postgresContactRepository) Create(ctx context.Context, con *domain.Contact) (*domain.Contact, error) {
+	query := `
+		INSERT INTO contacts
+			(household_id, name, email, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at, version`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if err := p.conn.QueryRow(
+		ctx,
+		query,
+		con.HouseholdID,
+		con.Name,
+		con.Email,
+		con.CreatedBy,
+	).Scan(
+		&con.ID,
+		&con.CreatedAt,
+		&con.UpdatedAt,
+		&con.Version); err != nil {
+		span.SetStatus(codes.Error, "failed inserting contact")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return con, nil
+}
+
+func (p *postgresContactRepository) Update(ctx context.Context, con *domain.Contact) (*domain.Contact, error) {
+	query := `
+		UPDATE contacts
+		SET
+			name = $2,
+			email = $3,
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND version = $4
+		RETURNING updated_at, version`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	row := p.conn.QueryRow(
+		ctx,
+		query,
+		con.ID,
+		con.Name,
+		con.Email,
+		con.Version,
+	)
+
+	if err := row.Scan(&con.UpdatedAt, &con.Version); err != nil {
+		span.SetStatus(codes.Error, "failed to update contact")
+		span.RecordError(err)
+		return nil, versionError(err)
+	}
+
+	return con, nil
+}
+
+func (p *postgresContactRepository) Delete(ctx context.Context, id uint, version int) error {
+	query := `
+		UPDATE contacts
+		SET
+			is_deleted = TRUE,
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND version = $2`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, id, version)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to delete contact")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrVersionConflict
+	}
+
+	return nil
+}
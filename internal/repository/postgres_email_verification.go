@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type postgresEmailVerificationRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresEmailVerification(conn Connection) domain.EmailVerificationRepository {
+	tracer := otel.Tracer("db:postgres:email_verifications")
+	return &postgresEmailVerificationRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresEmailVerificationRepository) GetByHash(ctx context.Context, hash string) (domain.EmailVerification, error) {
+	query := `
+		SELECT
+			id,
+			user_id,
+			email,
+			token_hash,
+			expires_at,
+			used_at,
+			created_at
+		FROM
+			email_verifications
+		WHERE
+			token_hash = $1`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, hash)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying email verifications")
+		span.RecordError(err)
+		return domain.EmailVerification{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return domain.EmailVerification{}, domain.ErrNotFound
+	}
+
+	var ver domain.EmailVerification
+	if err := rows.Scan(
+		&ver.ID,
+		&ver.UserID,
+		&ver.Email,
+		&ver.TokenHash,
+		&ver.ExpiresAt,
+		&ver.UsedAt,
+		&ver.CreatedAt,
+	); err != nil {
+		return domain.EmailVerification{}, err
+	}
+
+	return ver, nil
+}
+
+func (p *postgresEmailVerificationRepository) Create(ctx context.Context, ver *domain.EmailVerification) (*domain.EmailVerification, error) {
+	query := `
+		INSERT INTO email_verifications
+			(user_id, email, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if err := p.conn.QueryRow(
+		ctx,
+		query,
+		ver.UserID,
+		ver.Email,
+		ver.TokenHash,
+		ver.ExpiresAt,
+	).Scan(
+		&ver.ID,
+		&ver.CreatedAt); err != nil {
+		span.SetStatus(codes.Error, "failed inserting email verification")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return ver, nil
+}
+
+func (p *postgresEmailVerificationRepository) Confirm(ctx context.Context, id uint) error {
+	tx, err := p.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Claiming the verification locks it, so a concurrent replay of the
+	// same token finds it used.
+	query := `
+		UPDATE email_verifications
+		SET
+			used_at = NOW()
+		WHERE
+			id = $1
+			AND used_at IS NULL
+			AND expires_at > NOW()
+		RETURNING user_id, email`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	var userID uint
+	var email string
+	if err := tx.QueryRow(ctx, query, id).Scan(&userID, &email); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ErrInvalidToken
+		}
+		span.SetStatus(codes.Error, "failed to use email verification")
+		span.RecordError(err)
+		return err
+	}
+
+	// The address may have been taken since the change was requested; the
+	// unique index settles races with a concurrent sign up.
+	query = `
+		UPDATE users
+		SET
+			email = $2,
+			email_verified_at = NOW(),
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND is_deleted = FALSE
+			AND NOT EXISTS (SELECT 1 FROM users WHERE email = $2 AND id <> $1)`
+
+	ctx, span = spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := tx.Exec(ctx, query, userID, email)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return domain.ErrEmailTaken
+		}
+		span.SetStatus(codes.Error, "failed to change User email")
+		span.RecordError(err)
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrEmailTaken
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,261 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type postgresFamilyMemberRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresFamilyMember(conn Connection) domain.FamilyMemberRepository {
+	tracer := otel.Tracer("db:postgres:family_members")
+	return &postgresFamilyMemberRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresFamilyMemberRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.FamilyMember, error) {
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying family members")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	fms := []domain.FamilyMember{}
+	for rows.Next() {
+		var fm domain.FamilyMember
+		if err := rows.Scan(
+			&fm.ID,
+			&fm.HouseholdID,
+			&fm.Name,
+			&fm.Relationship,
+			&fm.CreatedBy,
+			&fm.CreatedAt,
+			&fm.UpdatedAt,
+			&fm.Version,
+		); err != nil {
+			return nil, err
+		}
+		fms = append(fms, fm)
+	}
+	return fms, nil
+}
+
+func (p *postgresFamilyMemberRepository) GetByID(ctx context.Context, id uint) (domain.FamilyMember, error) {
+	query := `
+		SELECT
+			id,
+			household_id,
+			name,
+			relationship,
+			created_by,
+			created_at,
+			updated_at,
+			version
+		FROM
+			family_members
+		WHERE
+			id = $1
+			AND is_deleted = FALSE`
+
+	fms, err := p.fetch(ctx, query, id)
+	if err != nil {
+		return domain.FamilyMember{}, err
+	}
+
+	if len(fms) == 0 {
+		return domain.FamilyMember{}, domain.ErrNotFound
+	}
+	return fms[0], nil
+}
+
+func (p *postgresFamilyMemberRepository) GetByHouseholdID(ctx context.Context, householdID uint) ([]domain.FamilyMember, error) {
+	query := `
+		SELECT
+			id,
+			household_id,
+			name,
+			relationship,
+			created_by,
+			created_at,
+			updated_at,
+			version
+		FROM
+			family_members
+		WHERE
+			household_id = $1
+			AND is_deleted = FALSE
+		ORDER BY
+			name ASC`
+
+	fms, err := p.fetch(ctx, query, householdID)
+	if err != nil {
+		return []domain.FamilyMember{}, err
+	}
+
+	return fms, nil
+}
+
+func (p *postgresFamilyMemberRepository) GetTotals(ctx context.Context, householdID uint, from time.Time, to time.Time) ([]domain.FamilyMemberTotal, error) {
+	query := `
+		SELECT
+			F.id,
+			COALESCE(F.name, ''),
+			C.id,
+			C.name,
+			T.operation,
+			SUM(T.amount),
+			COUNT(DISTINCT T.transaction_id)
+		FROM
+			transaction_allocations T
+			JOIN categories C ON T.category_id = C.id
+			LEFT JOIN family_members F ON T.family_member_id = F.id
+		WHERE
+			T.household_id = $1
+			AND T.created_at >= $2
+			AND T.created_at < $3
+			AND T.is_deleted = FALSE
+		GROUP BY
+			F.id,
+			F.name,
+			C.id,
+			C.name,
+			T.operation
+		ORDER BY
+			F.name ASC NULLS LAST,
+			C.name ASC,
+			T.operation ASC`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, householdID, from, to)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying family member totals")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := []domain.FamilyMemberTotal{}
+	for rows.Next() {
+		var total domain.FamilyMemberTotal
+		if err := rows.Scan(
+			&total.FamilyMemberID,
+			&total.FamilyMemberName,
+			&total.CategoryID,
+			&total.CategoryName,
+			&total.Operation,
+			&total.Total,
+			&total.Count,
+		); err != nil {
+			return nil, err
+		}
+		totals = append(totals, total)
+	}
+
+	return totals, nil
+}
+
+func (p *postgresFamilyMemberRepository) Create(ctx context.Context, fm *domain.FamilyMember) (*domain.FamilyMember, error) {
+	query := `
+		INSERT INTO family_members
+			(household_id, name, relationship, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at, version`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if err := p.conn.QueryRow(
+		ctx,
+		query,
+		fm.HouseholdID,
+		fm.Name,
+		fm.Relationship,
+		fm.CreatedBy,
+	).Scan(
+		&fm.ID,
+		&fm.CreatedAt,
+		&fm.UpdatedAt,
+		&fm.Version); err != nil {
+		span.SetStatus(codes.Error, "failed inserting family member")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return fm, nil
+}
+
+func (p *postgresFamilyMemberRepository) Update(ctx context.Context, fm *domain.FamilyMember) (*domain.FamilyMember, error) {
+	query := `
+		UPDATE family_members
+		SET
+			name = $2,
+			relationship = $3,
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND version = $4
+		RETURNING updated_at, version`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	row := p.conn.QueryRow(
+		ctx,
+		query,
+		fm.ID,
+		fm.Name,
+		fm.Relationship,
+		fm.Version,
+	)
+
+	if err := row.Scan(&fm.UpdatedAt, &fm.Version); err != nil {
+		span.SetStatus(codes.Error, "failed to update family member")
+		span.RecordError(err)
+		return nil, versionError(err)
+	}
+
+	return fm, nil
+}
+
+func (p *postgresFamilyMemberRepository) Delete(ctx context.Context, id uint, version int) error {
+	query := `
+		UPDATE family_members
+		SET
+			is_deleted = TRUE,
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND version = $2`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, id, version)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to delete family member")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrVersionConflict
+	}
+
+	return nil
+}
@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type postgresHouseholdRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresHousehold(conn Connection) domain.HouseholdRepository {
+	tracer := otel.Tracer("db:postgres:households")
+	return &postgresHouseholdRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresHouseholdRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.Household, error) {
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying households")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	hhs := []domain.Household{}
+	for rows.Next() {
+		var hh domain.Household
+		if err := rows.Scan(
+			&hh.ID,
+			&hh.Name,
+			&hh.IsPersonal,
+			&hh.CreatedBy,
+			&hh.Role,
+			&hh.CreatedAt,
+			&hh.UpdatedAt,
+			&hh.Version,
+		); err != nil {
+			return nil, err
+		}
+		hhs = append(hhs, hh)
+	}
+	return hhs, nil
+}
+
+func (p *postgresHouseholdRepository) GetByID(ctx context.Context, id uint) (domain.Household, error) {
+	query := `
+		SELECT
+			id,
+			name,
+			is_personal,
+			created_by,
+			'' AS role,
+			created_at,
+			updated_at,
+			version
+		FROM
+			households
+		WHERE
+			id = $1
+			AND is_deleted = FALSE`
+
+	hhs, err := p.fetch(ctx, query, id)
+	if err != nil {
+		return domain.Household{}, err
+	}
+
+	if len(hhs) == 0 {
+		return domain.Household{}, domain.ErrNotFound
+	}
+	return hhs[0], nil
+}
+
+func (p *postgresHouseholdRepository) GetByUserSUB(ctx context.Context, sub uint) ([]domain.Household, error) {
+	query := `
+		SELECT
+			H.id,
+			H.name,
+			H.is_personal,
+			H.created_by,
+			M.role,
+			H.created_at,
+			H.updated_at,
+			H.version
+		FROM
+			households H
+			JOIN household_members M ON M.household_id = H.id
+		WHERE
+			M.user_id = $1
+			AND H.is_deleted = FALSE
+		ORDER BY
+			H.is_personal DESC,
+			H.name ASC`
+
+	hhs, err := p.fetch(ctx, query, sub)
+	if err != nil {
+		return []domain.Household{}, err
+	}
+
+	return hhs, nil
+}
+
+func (p *postgresHouseholdRepository) GetPersonal(ctx context.Context, sub uint) (domain.Household, error) {
+	query := `
+		SELECT
+			id,
+			name,
+			is_personal,
+			created_by,
+			'owner' AS role,
+			created_at,
+			updated_at,
+			version
+		FROM
+			households
+		WHERE
+			created_by = $1
+			AND is_personal = TRUE
+			AND is_deleted = FALSE`
+
+	hhs, err := p.fetch(ctx, query, sub)
+	if err != nil {
+		return domain.Household{}, err
+	}
+
+	if len(hhs) == 0 {
+		return domain.Household{}, domain.ErrNotFound
+	}
+	return hhs[0], nil
+}
+
+func (p *postgresHouseholdRepository) Create(ctx context.Context, hh *domain.Household) (*domain.Household, error) {
+	query := `
+		WITH hh AS (
+			INSERT INTO households
+				(name, is_personal, created_by)
+			VALUES ($1, $2, $3)
+			RETURNING id, created_at, updated_at, version
+		), owner AS (
+			INSERT INTO household_members
+				(household_id, user_id, role)
+			SELECT id, $3, 'owner' FROM hh
+		)
+		SELECT id, created_at, updated_at, version FROM hh`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if err := p.conn.QueryRow(
+		ctx,
+		query,
+		hh.Name,
+		hh.IsPersonal,
+		hh.CreatedBy,
+	).Scan(
+		&hh.ID,
+		&hh.CreatedAt,
+		&hh.UpdatedAt,
+		&hh.Version); err != nil {
+		span.SetStatus(codes.Error, "failed inserting household")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	hh.Role = domain.HouseholdOwner
+	return hh, nil
+}
+
+func (p *postgresHouseholdRepository) Update(ctx context.Context, hh *domain.Household) (*domain.Household, error) {
+	query := `
+		UPDATE households
+		SET
+			name = $2,
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND version = $3
+		RETURNING updated_at, version`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	row := p.conn.QueryRow(
+		ctx,
+		query,
+		hh.ID,
+		hh.Name,
+		hh.Version,
+	)
+
+	if err := row.Scan(&hh.UpdatedAt, &hh.Version); err != nil {
+		span.SetStatus(codes.Error, "failed to update household")
+		span.RecordError(err)
+		return nil, versionError(err)
+	}
+
+	return hh, nil
+}
+
+func (p *postgresHouseholdRepository) Delete(ctx context.Context, id uint, version int) error {
+	query := `
+		UPDATE households
+		SET
+			is_deleted = TRUE,
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND version = $2
+			AND is_personal = FALSE`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, id, version)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to delete household")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrVersionConflict
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type postgresHouseholdMemberRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresHouseholdMember(conn Connection) domain.HouseholdMemberRepository {
+	tracer := otel.Tracer("db:postgres:household_members")
+	return &postgresHouseholdMemberRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresHouseholdMemberRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.HouseholdMember, error) {
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying household members")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	mems := []domain.HouseholdMember{}
+	for rows.Next() {
+		var mem domain.HouseholdMember
+		if err := rows.Scan(
+			&mem.ID,
+			&mem.HouseholdID,
+			&mem.UserID,
+			&mem.Name,
+			&mem.Email,
+			&mem.Role,
+			&mem.CreatedAt,
+			&mem.UpdatedAt,
+			&mem.Version,
+		); err != nil {
+			return nil, err
+		}
+		mems = append(mems, mem)
+	}
+	return mems, nil
+}
+
+func (p *postgresHouseholdMemberRepository) GetByHouseholdUser(ctx context.Context, householdID uint, userID uint) (domain.HouseholdMember, error) {
+	query := `
+		SELECT
+			M.id,
+			M.household_id,
+			M.user_id,
+			U.name,
+			U.email,
+			M.role,
+			M.created_at,
+			M.updated_at,
+			M.version
+		FROM
+			household_members M
+			JOIN households H ON M.household_id = H.id
+			JOIN users U ON M.user_id = U.id
+		WHERE
+			M.household_id = $1
+			AND M.user_id = $2
+			AND H.is_deleted = FALSE`
+
+	mems, err := p.fetch(ctx, query, householdID, userID)
+	if err != nil {
+		return domain.HouseholdMember{}, err
+	}
+
+	if len(mems) == 0 {
+		return domain.HouseholdMember{}, domain.ErrNotFound
+	}
+	return mems[0], nil
+}
+
+func (p *postgresHouseholdMemberRepository) GetByHouseholdID(ctx context.Context, householdID uint) ([]domain.HouseholdMember, error) {
+	query := `
+		SELECT
+			M.id,
+			M.household_id,
+			M.user_id,
+			U.name,
+			U.email,
+			M.role,
+			M.created_at,
+			M.updated_at,
+			M.version
+		FROM
+			household_members M
+			JOIN users U ON M.user_id = U.id
+		WHERE
+			M.household_id = $1
+		ORDER BY
+			U.name ASC`
+
+	mems, err := p.fetch(ctx, query, householdID)
+	if err != nil {
+		return []domain.HouseholdMember{}, err
+	}
+
+	return mems, nil
+}
+
+func (p *postgresHouseholdMemberRepository) CountOwners(ctx context.Context, householdID uint) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM household_members
+		WHERE
+			household_id = $1
+			AND role = 'owner'`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	var count int
+	if err := p.conn.QueryRow(ctx, query, householdID).Scan(&count); err != nil {
+		span.SetStatus(codes.Error, "failed counting household owners")
+		span.RecordError(err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (p *postgresHouseholdMemberRepository) Create(ctx context.Context, mem *domain.HouseholdMember) (*domain.HouseholdMember, error) {
+	query := `
+		INSERT INTO household_members
+			(household_id, user_id, role)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at, version`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if err := p.conn.QueryRow(
+		ctx,
+		query,
+		mem.HouseholdID,
+		mem.UserID,
+		mem.Role,
+	).Scan(
+		&mem.ID,
+		&mem.CreatedAt,
+		&mem.UpdatedAt,
+		&mem.Version); err != nil {
+		span.SetStatus(codes.Error, "failed inserting household member")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return mem, nil
+}
+
+func (p *postgresHouseholdMemberRepository) Update(ctx context.Context, mem *domain.HouseholdMember) (*domain.HouseholdMember, error) {
+	query := `
+		UPDATE household_members
+		SET
+			role = $3,
+			updated_at = NOW()
+		WHERE
+			household_id = $1
+			AND user_id = $2
+			AND version = $4
+		RETURNING updated_at, version`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	row := p.conn.QueryRow(
+		ctx,
+		query,
+		mem.HouseholdID,
+		mem.UserID,
+		mem.Role,
+		mem.Version,
+	)
+
+	if err := row.Scan(&mem.UpdatedAt, &mem.Version); err != nil {
+		span.SetStatus(codes.Error, "failed to update household member")
+		span.RecordError(err)
+		return nil, versionError(err)
+	}
+
+	return mem, nil
+}
+
+func (p *postgresHouseholdMemberRepository) Delete(ctx context.Context, householdID uint, userID uint, version int) error {
+	query := `
+		DELETE FROM household_members
+		WHERE
+			household_id = $1
+			AND user_id = $2
+			AND version = $3`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, householdID, userID, version)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to delete household member")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrVersionConflict
+	}
+
+	return nil
+}
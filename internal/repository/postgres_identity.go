@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type postgresIdentityRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresIdentity(conn Connection) domain.UserIdentityRepository {
+	tracer := otel.Tracer("db:postgres:user_identities")
+	return &postgresIdentityRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresIdentityRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.UserIdentity, error) {
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying user identities")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	idts := []domain.UserIdentity{}
+	for rows.Next() {
+		var idt domain.UserIdentity
+		if err := rows.Scan(
+			&idt.ID,
+			&idt.UserID,
+			&idt.Provider,
+			&idt.Subject,
+			&idt.Email,
+			&idt.CreatedAt,
+			&idt.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		idts = append(idts, idt)
+	}
+	return idts, nil
+}
+
+func (p *postgresIdentityRepository) GetByProviderSubject(ctx context.Context, provider string, subject string) (domain.UserIdentity, error) {
+	query := `
+		SELECT
+			id,
+			user_id,
+			provider,
+			subject,
+			email,
+			created_at,
+			updated_at
+		FROM
+			user_identities
+		WHERE
+			provider = $1
+			AND subject = $2
+			AND is_deleted = FALSE`
+
+	idts, err := p.fetch(ctx, query, provider, subject)
+	if err != nil {
+		return domain.UserIdentity{}, err
+	}
+
+	if len(idts) == 0 {
+		return domain.UserIdentity{}, domain.ErrNotFound
+	}
+	return idts[0], nil
+}
+
+func (p *postgresIdentityRepository) GetByUserSUB(ctx context.Context, sub uint) ([]domain.UserIdentity, error) {
+	query := `
+		SELECT
+			id,
+			user_id,
+			provider,
+			subject,
+			email,
+			created_at,
+			updated_at
+		FROM
+			user_identities
+		WHERE
+			user_id = $1
+			AND is_deleted = FALSE
+		ORDER BY
+			provider ASC`
+
+	idts, err := p.fetch(ctx, query, sub)
+	if err != nil {
+		return []domain.UserIdentity{}, err
+	}
+
+	return idts, nil
+}
+
+func (p *postgresIdentityRepository) Create(ctx context.Context, idt *domain.UserIdentity) (*domain.UserIdentity, error) {
+	query := `
+		INSERT INTO user_identities
+			(user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if err := p.conn.QueryRow(
+		ctx,
+		query,
+		idt.UserID,
+		idt.Provider,
+		idt.Subject,
+		idt.Email,
+	).Scan(
+		&idt.ID,
+		&idt.CreatedAt,
+		&idt.UpdatedAt); err != nil {
+		span.SetStatus(codes.Error, "failed inserting user identity")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return idt, nil
+}
+
+func (p *postgresIdentityRepository) Delete(ctx context.Context, id uint) error {
+	query := `
+		DELETE FROM user_identities
+		WHERE
+			id = $1`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, id)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to delete user identity")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,430 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type postgresPayeeRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresPayee(conn Connection) domain.PayeeRepository {
+	tracer := otel.Tracer("db:postgres:payees")
+	return &postgresPayeeRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresPayeeRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.Payee, error) {
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying payees")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	payees := []domain.Payee{}
+	for rows.Next() {
+		var payee domain.Payee
+		if err := rows.Scan(
+			&payee.ID,
+			&payee.HouseholdID,
+			&payee.Name,
+			&payee.Aliases,
+			&payee.CreatedBy,
+			&payee.CreatedAt,
+			&payee.UpdatedAt,
+			&payee.Version,
+		); err != nil {
+			return nil, err
+		}
+		payees = append(payees, payee)
+	}
+	return payees, nil
+}
+
+func (p *postgresPayeeRepository) GetByID(ctx context.Context, id uint) (domain.Payee, error) {
+	query := `
+		SELECT
+			P.id,
+			P.household_id,
+			P.name,
+			ARRAY(SELECT alias FROM payee_aliases WHERE payee_id = P.id ORDER BY alias),
+			P.created_by,
+			P.created_at,
+			P.updated_at,
+			P.version
+		FROM
+			payees P
+		WHERE
+			P.id = $1`
+
+	payees, err := p.fetch(ctx, query, id)
+	if err != nil {
+		return domain.Payee{}, err
+	}
+
+	if len(payees) == 0 {
+		return domain.Payee{}, domain.ErrNotFound
+	}
+	return payees[0], nil
+}
+
+func (p *postgresPayeeRepository) GetByName(ctx context.Context, householdID uint, name string) (domain.Payee, error) {
+	query := `
+		SELECT
+			P.id,
+			P.household_id,
+			P.name,
+			ARRAY(SELECT alias FROM payee_aliases WHERE payee_id = P.id ORDER BY alias),
+			P.created_by,
+			P.created_at,
+			P.updated_at,
+			P.version
+		FROM
+			payees P
+		WHERE
+			P.household_id = $1
+			AND (
+				P.normalized_name = $2
+				OR EXISTS (SELECT 1 FROM payee_aliases PA WHERE PA.payee_id = P.id AND PA.alias = $2)
+			)
+		LIMIT 1`
+
+	payees, err := p.fetch(ctx, query, householdID, domain.NormalizePayee(name))
+	if err != nil {
+		return domain.Payee{}, err
+	}
+
+	if len(payees) == 0 {
+		return domain.Payee{}, domain.ErrNotFound
+	}
+	return payees[0], nil
+}
+
+func (p *postgresPayeeRepository) GetByHouseholdID(ctx context.Context, householdID uint) ([]domain.Payee, error) {
+	query := `
+		SELECT
+			P.id,
+			P.household_id,
+			P.name,
+			ARRAY(SELECT alias FROM payee_aliases WHERE payee_id = P.id ORDER BY alias),
+			P.created_by,
+			P.created_at,
+			P.updated_at,
+			P.version
+		FROM
+			payees P
+		WHERE
+			P.household_id = $1
+		ORDER BY
+			P.name ASC`
+
+	payees, err := p.fetch(ctx, query, householdID)
+	if err != nil {
+		return []domain.Payee{}, err
+	}
+
+	return payees, nil
+}
+
+func (p *postgresPayeeRepository) Search(ctx context.Context, householdID uint, q string, limit int) ([]domain.Payee, error) {
+	query := `
+		SELECT
+			P.id,
+			P.household_id,
+			P.name,
+			ARRAY(SELECT alias FROM payee_aliases WHERE payee_id = P.id ORDER BY alias),
+			P.created_by,
+			P.created_at,
+			P.updated_at,
+			P.version
+		FROM
+			payees P
+		WHERE
+			P.household_id = $1
+			AND (
+				P.normalized_name LIKE $2 || '%'
+				OR EXISTS (SELECT 1 FROM payee_aliases PA WHERE PA.payee_id = P.id AND PA.alias LIKE $2 || '%')
+			)
+		ORDER BY
+			(SELECT COUNT(*) FROM transactions T WHERE T.payee_id = P.id AND T.is_deleted = FALSE) DESC,
+			P.name ASC
+		LIMIT $3`
+
+	payees, err := p.fetch(ctx, query, householdID, domain.NormalizePayee(q), limit)
+	if err != nil {
+		return []domain.Payee{}, err
+	}
+
+	return payees, nil
+}
+
+func (p *postgresPayeeRepository) GetHistory(ctx context.Context, id uint) ([]domain.PayeeMonth, error) {
+	query := `
+		SELECT
+			date_trunc('month', T.created_at) AS month,
+			T.operation,
+			SUM(T.amount),
+			COUNT(T.id)
+		FROM
+			transactions T
+		WHERE
+			T.payee_id = $1
+			AND T.is_deleted = FALSE
+		GROUP BY
+			month,
+			T.operation
+		ORDER BY
+			month DESC,
+			T.operation ASC`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, id)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying payee history")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	months := []domain.PayeeMonth{}
+	for rows.Next() {
+		var month domain.PayeeMonth
+		if err := rows.Scan(
+			&month.Month,
+			&month.Operation,
+			&month.Total,
+			&month.Count,
+		); err != nil {
+			return nil, err
+		}
+		months = append(months, month)
+	}
+
+	return months, nil
+}
+
+func (p *postgresPayeeRepository) Create(ctx context.Context, payee *domain.Payee) (*domain.Payee, error) {
+	query := `
+		INSERT INTO payees
+			(household_id, name, normalized_name, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at, version`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if err := p.conn.QueryRow(
+		ctx,
+		query,
+		payee.HouseholdID,
+		payee.Name,
+		domain.NormalizePayee(payee.Name),
+		payee.CreatedBy,
+	).Scan(
+		&payee.ID,
+		&payee.CreatedAt,
+		&payee.UpdatedAt,
+		&payee.Version); err != nil {
+		span.SetStatus(codes.Error, "failed inserting payee")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	if payee.Aliases == nil {
+		payee.Aliases = []string{}
+	}
+	return payee, nil
+}
+
+func (p *postgresPayeeRepository) Update(ctx context.Context, payee *domain.Payee) (*domain.Payee, error) {
+	query := `
+		UPDATE payees
+		SET
+			name = $2,
+			normalized_name = $3,
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND version = $4
+		RETURNING updated_at, version`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	row := p.conn.QueryRow(
+		ctx,
+		query,
+		payee.ID,
+		payee.Name,
+		domain.NormalizePayee(payee.Name),
+		payee.Version,
+	)
+
+	if err := row.Scan(&payee.UpdatedAt, &payee.Version); err != nil {
+		span.SetStatus(codes.Error, "failed to update payee")
+		span.RecordError(err)
+		return nil, versionError(err)
+	}
+
+	return payee, nil
+}
+
+func (p *postgresPayeeRepository) Delete(ctx context.Context, id uint, version int) error {
+	query := `
+		DELETE FROM payees
+		WHERE
+			id = $1
+			AND version = $2`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, id, version)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to delete payee")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrVersionConflict
+	}
+
+	return nil
+}
+
+func (p *postgresPayeeRepository) AddAlias(ctx context.Context, payee *domain.Payee, alias string) error {
+	// Aliases are part of the payee, so adding one moves its version on.
+	query := `
+		WITH touched AS (
+			UPDATE payees
+			SET updated_at = NOW()
+			WHERE id = $1
+		)
+		INSERT INTO payee_aliases
+			(payee_id, household_id, alias)
+		VALUES ($1, $2, $3)`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if _, err := p.conn.Exec(ctx, query, payee.ID, payee.HouseholdID, domain.NormalizePayee(alias)); err != nil {
+		span.SetStatus(codes.Error, "failed inserting payee alias")
+		span.RecordError(err)
+		return err
+	}
+
+	return nil
+}
+
+// RemoveAlias drops alias from the payee at version, moving the payee's
+// version on.
+func (p *postgresPayeeRepository) RemoveAlias(ctx context.Context, id uint, version int, alias string) error {
+	tx, err := p.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE payees
+		SET updated_at = NOW()
+		WHERE
+			id = $1
+			AND version = $2`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := tx.Exec(ctx, query, id, version)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to delete payee alias")
+		span.RecordError(err)
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrVersionConflict
+	}
+
+	query = `
+		DELETE FROM payee_aliases
+		WHERE
+			payee_id = $1
+			AND alias = $2`
+
+	result, err = tx.Exec(ctx, query, id, domain.NormalizePayee(alias))
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to delete payee alias")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (p *postgresPayeeRepository) Merge(ctx context.Context, id uint, intoID uint) error {
+	tx, err := p.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		WITH moved AS (
+			UPDATE transactions
+			SET payee_id = $2
+			WHERE payee_id = $1
+		), aliases AS (
+			UPDATE payee_aliases
+			SET payee_id = $2
+			WHERE payee_id = $1
+		)
+		INSERT INTO payee_aliases
+			(payee_id, household_id, alias)
+		SELECT $2, household_id, normalized_name
+		FROM payees
+		WHERE id = $1
+		ON CONFLICT DO NOTHING`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if _, err := tx.Exec(ctx, query, id, intoID); err != nil {
+		span.SetStatus(codes.Error, "failed to merge payee")
+		span.RecordError(err)
+		return err
+	}
+
+	result, err := tx.Exec(ctx, `DELETE FROM payees WHERE id = $1`, id)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to merge payee")
+		span.RecordError(err)
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type postgresRuleRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresRule(conn Connection) domain.RuleRepository {
+	tracer := otel.Tracer("db:postgres:rules")
+	return &postgresRuleRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresRuleRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.Rule, error) {
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying rules")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []domain.Rule{}
+	for rows.Next() {
+		var rule domain.Rule
+		if err := rows.Scan(
+			&rule.ID,
+			&rule.HouseholdID,
+			&rule.Name,
+			&rule.Priority,
+			&rule.IsActive,
+			&rule.Conditions.NoteContains,
+			&rule.Conditions.PayeeContains,
+			&rule.Conditions.AmountMin,
+			&rule.Conditions.AmountMax,
+			&rule.Conditions.AccountID,
+			&rule.Conditions.Operation,
+			&rule.Actions.CategoryID,
+			&rule.Actions.Tags,
+			&rule.Actions.PayeeID,
+			&rule.Actions.Note,
+			&rule.CreatedBy,
+			&rule.CreatedAt,
+			&rule.UpdatedAt,
+			&rule.Version,
+		); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (p *postgresRuleRepository) GetByID(ctx context.Context, id uint) (domain.Rule, error) {
+	query := `
+		SELECT
+			id,
+			household_id,
+			name,
+			priority,
+			is_active,
+			note_contains,
+			payee_contains,
+			amount_min,
+			amount_max,
+			account_id,
+			COALESCE(operation::TEXT, ''),
+			set_category_id,
+			set_tags,
+			set_payee_id,
+			set_note,
+			created_by,
+			created_at,
+			updated_at,
+			version
+		FROM
+			rules
+		WHERE
+			id = $1`
+
+	rules, err := p.fetch(ctx, query, id)
+	if err != nil {
+		return domain.Rule{}, err
+	}
+
+	if len(rules) == 0 {
+		return domain.Rule{}, domain.ErrNotFound
+	}
+	return rules[0], nil
+}
+
+func (p *postgresRuleRepository) GetByHouseholdID(ctx context.Context, householdID uint) ([]domain.Rule, error) {
+	query := `
+		SELECT
+			id,
+			household_id,
+			name,
+			priority,
+			is_active,
+			note_contains,
+			payee_contains,
+			amount_min,
+			amount_max,
+			account_id,
+			COALESCE(operation::TEXT, ''),
+			set_category_id,
+			set_tags,
+			set_payee_id,
+			set_note,
+			created_by,
+			created_at,
+			updated_at,
+			version
+		FROM
+			rules
+		WHERE
+			household_id = $1
+		ORDER BY
+			priority ASC,
+			id ASC`
+
+	rules, err := p.fetch(ctx, query, householdID)
+	if err != nil {
+		return []domain.Rule{}, err
+	}
+
+	return rules, nil
+}
+
+func (p *postgresRuleRepository) Create(ctx context.Context, rule *domain.Rule) (*domain.Rule, error) {
+	query := `
+		INSERT INTO rules
+			(household_id, name, priority, is_active, note_contains, payee_contains, amount_min, amount_max,
+			account_id, operation, set_category_id, set_tags, set_payee_id, set_note, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, '')::operation, $11, $12, $13, $14, $15)
+		RETURNING id, created_at, updated_at, version`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if rule.Actions.Tags == nil {
+		rule.Actions.Tags = []string{}
+	}
+
+	if err := p.conn.QueryRow(
+		ctx,
+		query,
+		rule.HouseholdID,
+		rule.Name,
+		rule.Priority,
+		rule.IsActive,
+		rule.Conditions.NoteContains,
+		rule.Conditions.PayeeContains,
+		rule.Conditions.AmountMin,
+		rule.Conditions.AmountMax,
+		rule.Conditions.AccountID,
+		rule.Conditions.Operation,
+		rule.Actions.CategoryID,
+		rule.Actions.Tags,
+		rule.Actions.PayeeID,
+		rule.Actions.Note,
+		rule.CreatedBy,
+	).Scan(
+		&rule.ID,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+		&rule.Version); err != nil {
+		span.SetStatus(codes.Error, "failed inserting rule")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func (p *postgresRuleRepository) Update(ctx context.Context, rule *domain.Rule) (*domain.Rule, error) {
+	query := `
+		UPDATE rules
+		SET
+			name = $2,
+			priority = $3,
+			is_active = $4,
+			note_contains = $5,
+			payee_contains = $6,
+			amount_min = $7,
+			amount_max = $8,
+			account_id = $9,
+			operation = NULLIF($10, '')::operation,
+			set_category_id = $11,
+			set_tags = $12,
+			set_payee_id = $13,
+			set_note = $14,
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND version = $15
+		RETURNING updated_at, version`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if rule.Actions.Tags == nil {
+		rule.Actions.Tags = []string{}
+	}
+
+	row := p.conn.QueryRow(
+		ctx,
+		query,
+		rule.ID,
+		rule.Name,
+		rule.Priority,
+		rule.IsActive,
+		rule.Conditions.NoteContains,
+		rule.Conditions.PayeeContains,
+		rule.Conditions.AmountMin,
+		rule.Conditions.AmountMax,
+		rule.Conditions.AccountID,
+		rule.Conditions.Operation,
+		rule.Actions.CategoryID,
+		rule.Actions.Tags,
+		rule.Actions.PayeeID,
+		rule.Actions.Note,
+		rule.Version,
+	)
+
+	if err := row.Scan(&rule.UpdatedAt, &rule.Version); err != nil {
+		span.SetStatus(codes.Error, "failed to update rule")
+		span.RecordError(err)
+		return nil, versionError(err)
+	}
+
+	return rule, nil
+}
+
+func (p *postgresRuleRepository) Delete(ctx context.Context, id uint, version int) error {
+	query := `
+		DELETE FROM rules
+		WHERE
+			id = $1
+			AND version = $2`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, id, version)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to delete rule")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrVersionConflict
+	}
+
+	return nil
+}
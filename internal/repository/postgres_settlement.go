@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type postgresSettlementRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresSettlement(conn Connection) domain.SettlementRepository {
+	tracer := otel.Tracer("db:postgres:settlements")
+	return &postgresSettlementRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresSettlementRepository) GetByHouseholdID(ctx context.Context, householdID uint) ([]domain.Settlement, error) {
+	query := `
+		SELECT
+			id,
+			household_id,
+			from_contact_id,
+			to_contact_id,
+			amount,
+			note,
+			transaction_id,
+			created_by,
+			created_at
+		FROM
+			settlements
+		WHERE
+			household_id = $1
+		ORDER BY
+			created_at DESC`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, householdID)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying settlements")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	stls := []domain.Settlement{}
+	for rows.Next() {
+		var stl domain.Settlement
+		if err := rows.Scan(
+			&stl.ID,
+			&stl.HouseholdID,
+			&stl.FromContactID,
+			&stl.ToContactID,
+			&stl.Amount,
+			&stl.Note,
+			&stl.TransactionID,
+			&stl.CreatedBy,
+			&stl.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		stls = append(stls, stl)
+	}
+
+	return stls, nil
+}
+
+func (p *postgresSettlementRepository) Create(ctx context.Context, stl *domain.Settlement) (*domain.Settlement, error) {
+	query := `
+		INSERT INTO settlements
+			(household_id, from_contact_id, to_contact_id, amount, note, transaction_id, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if err := p.conn.QueryRow(
+		ctx,
+		query,
+		stl.HouseholdID,
+		stl.FromContactID,
+		stl.ToContactID,
+		stl.Amount,
+		stl.Note,
+		stl.TransactionID,
+		stl.CreatedBy,
+	).Scan(
+		&stl.ID,
+		&stl.CreatedAt); err != nil {
+		span.SetStatus(codes.Error, "failed inserting settlement")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return stl, nil
+}
@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type postgresShareLinkRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresShareLink(conn Connection) domain.ShareLinkRepository {
+	tracer := otel.Tracer("db:postgres:share_links")
+	return &postgresShareLinkRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresShareLinkRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.ShareLink, error) {
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying share links")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []domain.ShareLink{}
+	for rows.Next() {
+		var link domain.ShareLink
+		if err := rows.Scan(
+			&link.ID,
+			&link.HouseholdID,
+			&link.Resource,
+			&link.ResourceID,
+			&link.StartsAt,
+			&link.EndsAt,
+			&link.Prefix,
+			&link.TokenHash,
+			&link.PasscodeHash,
+			&link.ExpiresAt,
+			&link.LastAccessedAt,
+			&link.RevokedAt,
+			&link.CreatedBy,
+			&link.CreatedAt,
+			&link.UpdatedAt,
+			&link.Version,
+		); err != nil {
+			return nil, err
+		}
+		link.HasPasscode = link.PasscodeHash != nil
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+func (p *postgresShareLinkRepository) GetByID(ctx context.Context, id uint) (domain.ShareLink, error) {
+	query := `
+		SELECT
+			id,
+			household_id,
+			resource,
+			resource_id,
+			starts_at,
+			ends_at,
+			prefix,
+			token_hash,
+			passcode_hash,
+			expires_at,
+			last_accessed_at,
+			revoked_at,
+			created_by,
+			created_at,
+			updated_at,
+			version
+		FROM
+			share_links
+		WHERE
+			id = $1`
+
+	links, err := p.fetch(ctx, query, id)
+	if err != nil {
+		return domain.ShareLink{}, err
+	}
+
+	if len(links) == 0 {
+		return domain.ShareLink{}, domain.ErrNotFound
+	}
+	return links[0], nil
+}
+
+func (p *postgresShareLinkRepository) GetByHash(ctx context.Context, hash string) (domain.ShareLink, error) {
+	query := `
+		SELECT
+			id,
+			household_id,
+			resource,
+			resource_id,
+			starts_at,
+			ends_at,
+			prefix,
+			token_hash,
+			passcode_hash,
+			expires_at,
+			last_accessed_at,
+			revoked_at,
+			created_by,
+			created_at,
+			updated_at,
+			version
+		FROM
+			share_links
+		WHERE
+			token_hash = $1`
+
+	links, err := p.fetch(ctx, query, hash)
+	if err != nil {
+		return domain.ShareLink{}, err
+	}
+
+	if len(links) == 0 {
+		return domain.ShareLink{}, domain.ErrNotFound
+	}
+	return links[0], nil
+}
+
+func (p *postgresShareLinkRepository) GetByHouseholdID(ctx context.Context, householdID uint) ([]domain.ShareLink, error) {
+	query := `
+		SELECT
+			id,
+			household_id,
+			resource,
+			resource_id,
+			starts_at,
+			ends_at,
+			prefix,
+			token_hash,
+			passcode_hash,
+			expires_at,
+			last_accessed_at,
+			revoked_at,
+			created_by,
+			created_at,
+			updated_at,
+			version
+		FROM
+			share_links
+		WHERE
+			household_id = $1
+		ORDER BY
+			created_at DESC`
+
+	links, err := p.fetch(ctx, query, householdID)
+	if err != nil {
+		return []domain.ShareLink{}, err
+	}
+
+	return links, nil
+}
+
+func (p *postgresShareLinkRepository) Create(ctx context.Context, link *domain.ShareLink) (*domain.ShareLink, error) {
+	query := `
+		INSERT INTO share_links
+			(household_id, resource, resource_id, starts_at, ends_at, prefix, token_hash, passcode_hash, expires_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at, updated_at, version`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if err := p.conn.QueryRow(
+		ctx,
+		query,
+		link.HouseholdID,
+		link.Resource,
+		link.ResourceID,
+		link.StartsAt,
+		link.EndsAt,
+		link.Prefix,
+		link.TokenHash,
+		link.PasscodeHash,
+		link.ExpiresAt,
+		link.CreatedBy,
+	).Scan(
+		&link.ID,
+		&link.CreatedAt,
+		&link.UpdatedAt,
+		&link.Version); err != nil {
+		span.SetStatus(codes.Error, "failed inserting share link")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	link.HasPasscode = link.PasscodeHash != nil
+	return link, nil
+}
+
+func (p *postgresShareLinkRepository) Touch(ctx context.Context, id uint) error {
+	query := `
+		UPDATE share_links
+		SET
+			last_accessed_at = NOW()
+		WHERE
+			id = $1`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if _, err := p.conn.Exec(ctx, query, id); err != nil {
+		span.SetStatus(codes.Error, "failed to touch share link")
+		span.RecordError(err)
+		return err
+	}
+
+	return nil
+}
+
+func (p *postgresShareLinkRepository) Revoke(ctx context.Context, id uint, version int) error {
+	query := `
+		UPDATE share_links
+		SET
+			revoked_at = NOW(),
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND version = $2
+			AND revoked_at IS NULL`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, id, version)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to revoke share link")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrVersionConflict
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type postgresShareAccessRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresShareAccess(conn Connection) domain.ShareAccessRepository {
+	tracer := otel.Tracer("db:postgres:share_link_accesses")
+	return &postgresShareAccessRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresShareAccessRepository) GetByShareLinkID(ctx context.Context, shareLinkID uint) ([]domain.ShareAccess, error) {
+	query := `
+		SELECT
+			id,
+			share_link_id,
+			ip,
+			user_agent,
+			granted,
+			reason,
+			created_at
+		FROM
+			share_link_accesses
+		WHERE
+			share_link_id = $1
+		ORDER BY
+			created_at DESC`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, shareLinkID)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying share link accesses")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	accs := []domain.ShareAccess{}
+	for rows.Next() {
+		var acc domain.ShareAccess
+		if err := rows.Scan(
+			&acc.ID,
+			&acc.ShareLinkID,
+			&acc.IP,
+			&acc.UserAgent,
+			&acc.Granted,
+			&acc.Reason,
+			&acc.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		accs = append(accs, acc)
+	}
+	return accs, nil
+}
+
+func (p *postgresShareAccessRepository) Create(ctx context.Context, acc *domain.ShareAccess) (*domain.ShareAccess, error) {
+	query := `
+		INSERT INTO share_link_accesses
+			(share_link_id, ip, user_agent, granted, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if err := p.conn.QueryRow(
+		ctx,
+		query,
+		acc.ShareLinkID,
+		acc.IP,
+		acc.UserAgent,
+		acc.Granted,
+		acc.Reason,
+	).Scan(
+		&acc.ID,
+		&acc.CreatedAt); err != nil {
+		span.SetStatus(codes.Error, "failed inserting share link access")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return acc, nil
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type postgresSignInAttemptRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresSignInAttempt(conn Connection) domain.SignInAttemptRepository {
+	tracer := otel.Tracer("db:postgres:sign_in_attempts")
+	return &postgresSignInAttemptRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresSignInAttemptRepository) GetByEmail(ctx context.Context, email string, since time.Time) ([]domain.SignInAttempt, error) {
+	query := `
+		SELECT
+			id,
+			email,
+			ip,
+			user_agent,
+			reason,
+			user_id,
+			created_at
+		FROM
+			sign_in_attempts
+		WHERE
+			email = $1
+			AND created_at >= $2
+		ORDER BY
+			created_at DESC`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, email, since)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying sign in attempts")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	atts := []domain.SignInAttempt{}
+	for rows.Next() {
+		var att domain.SignInAttempt
+		if err := rows.Scan(
+			&att.ID,
+			&att.Email,
+			&att.IP,
+			&att.UserAgent,
+			&att.Reason,
+			&att.UserID,
+			&att.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		atts = append(atts, att)
+	}
+	return atts, nil
+}
+
+func (p *postgresSignInAttemptRepository) Create(ctx context.Context, att *domain.SignInAttempt) (*domain.SignInAttempt, error) {
+	query := `
+		INSERT INTO sign_in_attempts
+			(email, ip, user_agent, reason, user_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if err := p.conn.QueryRow(
+		ctx,
+		query,
+		att.Email,
+		att.IP,
+		att.UserAgent,
+		att.Reason,
+		att.UserID,
+	).Scan(
+		&att.ID,
+		&att.CreatedAt); err != nil {
+		span.SetStatus(codes.Error, "failed inserting sign in attempt")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return att, nil
+}
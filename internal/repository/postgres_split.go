@@ -0,0 +1,386 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type postgresSplitRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresSplit(conn Connection) domain.SplitRepository {
+	tracer := otel.Tracer("db:postgres:transaction_splits")
+	return &postgresSplitRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresSplitRepository) fetchShares(ctx context.Context, splitID uint) ([]domain.SplitShare, error) {
+	query := `
+		SELECT
+			id,
+			split_id,
+			contact_id,
+			value,
+			amount
+		FROM
+			split_shares
+		WHERE
+			split_id = $1
+		ORDER BY
+			id ASC`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, splitID)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying split shares")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []domain.SplitShare{}
+	for rows.Next() {
+		var sh domain.SplitShare
+		if err := rows.Scan(
+			&sh.ID,
+			&sh.SplitID,
+			&sh.ContactID,
+			&sh.Value,
+			&sh.Amount,
+		); err != nil {
+			return nil, err
+		}
+		shares = append(shares, sh)
+	}
+	return shares, nil
+}
+
+func (p *postgresSplitRepository) GetByTransactionID(ctx context.Context, transactionID uint) (domain.Split, error) {
+	query := `
+		SELECT
+			id,
+			transaction_id,
+			household_id,
+			method,
+			paid_by,
+			created_by,
+			created_at,
+			updated_at
+		FROM
+			transaction_splits
+		WHERE
+			transaction_id = $1`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, transactionID)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying split")
+		span.RecordError(err)
+		return domain.Split{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return domain.Split{}, domain.ErrNotFound
+	}
+
+	var split domain.Split
+	if err := rows.Scan(
+		&split.ID,
+		&split.TransactionID,
+		&split.HouseholdID,
+		&split.Method,
+		&split.PaidBy,
+		&split.CreatedBy,
+		&split.CreatedAt,
+		&split.UpdatedAt,
+	); err != nil {
+		return domain.Split{}, err
+	}
+	rows.Close()
+
+	shares, err := p.fetchShares(ctx, split.ID)
+	if err != nil {
+		return domain.Split{}, err
+	}
+	split.Shares = shares
+
+	return split, nil
+}
+
+func (p *postgresSplitRepository) GetBalances(ctx context.Context, householdID uint) ([]domain.SplitBalance, error) {
+	query := `
+		WITH entries AS (
+			SELECT
+				S.paid_by AS contact_id,
+				SH.amount
+			FROM
+				split_shares SH
+				JOIN transaction_splits S ON SH.split_id = S.id
+				JOIN transactions T ON S.transaction_id = T.id
+			WHERE
+				S.household_id = $1
+				AND T.is_deleted = FALSE
+				AND SH.contact_id IS DISTINCT FROM S.paid_by
+			UNION ALL
+			SELECT
+				SH.contact_id,
+				-SH.amount
+			FROM
+				split_shares SH
+				JOIN transaction_splits S ON SH.split_id = S.id
+				JOIN transactions T ON S.transaction_id = T.id
+			WHERE
+				S.household_id = $1
+				AND T.is_deleted = FALSE
+				AND SH.contact_id IS DISTINCT FROM S.paid_by
+			UNION ALL
+			SELECT
+				from_contact_id,
+				amount
+			FROM
+				settlements
+			WHERE
+				household_id = $1
+			UNION ALL
+			SELECT
+				to_contact_id,
+				-amount
+			FROM
+				settlements
+			WHERE
+				household_id = $1
+		)
+		SELECT
+			E.contact_id,
+			COALESCE(C.name, ''),
+			ROUND(SUM(E.amount)::NUMERIC, 2)::DOUBLE PRECISION
+		FROM
+			entries E
+			LEFT JOIN contacts C ON E.contact_id = C.id
+		GROUP BY
+			E.contact_id,
+			C.name
+		HAVING
+			ROUND(SUM(E.amount)::NUMERIC, 2) <> 0
+		ORDER BY
+			C.name ASC NULLS FIRST`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, householdID)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying split balances")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := []domain.SplitBalance{}
+	for rows.Next() {
+		var b domain.SplitBalance
+		if err := rows.Scan(
+			&b.ContactID,
+			&b.Name,
+			&b.Amount,
+		); err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+
+	return balances, nil
+}
+
+// transactionSplit loads the split of a transaction through conn, so it can
+// be read inside a database transaction, or nil when it has none.
+func transactionSplit(ctx context.Context, conn Connection, transactionID uint) (*domain.Split, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT
+			S.id,
+			S.method,
+			SH.id,
+			SH.contact_id,
+			SH.value,
+			SH.amount
+		FROM
+			transaction_splits S
+			JOIN split_shares SH ON SH.split_id = S.id
+		WHERE
+			S.transaction_id = $1
+		ORDER BY
+			SH.id ASC`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var split *domain.Split
+	for rows.Next() {
+		if split == nil {
+			split = &domain.Split{TransactionID: transactionID}
+		}
+		var sh domain.SplitShare
+		if err := rows.Scan(
+			&split.ID,
+			&split.Method,
+			&sh.ID,
+			&sh.ContactID,
+			&sh.Value,
+			&sh.Amount,
+		); err != nil {
+			return nil, err
+		}
+		sh.SplitID = split.ID
+		split.Shares = append(split.Shares, sh)
+	}
+	return split, rows.Err()
+}
+
+// saveShareAmounts stores the share amounts of a reallocated split.
+func saveShareAmounts(ctx context.Context, conn Connection, split *domain.Split) error {
+	for _, sh := range split.Shares {
+		if _, err := conn.Exec(ctx, `UPDATE split_shares SET amount = $2 WHERE id = $1`, sh.ID, sh.Amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// touchTransaction moves the version of the transaction a split belongs to
+// on, so a split change is guarded by and shows in the transaction's ETag.
+func touchTransaction(ctx context.Context, tx pgx.Tx, transactionID uint, version int) error {
+	result, err := tx.Exec(ctx, `
+		UPDATE transactions
+		SET updated_at = NOW()
+		WHERE
+			id = $1
+			AND version = $2`, transactionID, version)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrVersionConflict
+	}
+	return nil
+}
+
+func (p *postgresSplitRepository) Save(ctx context.Context, split *domain.Split, version int) (*domain.Split, error) {
+	tx, err := p.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := touchTransaction(ctx, tx, split.TransactionID, version); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM transaction_splits WHERE transaction_id = $1`, split.TransactionID); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO transaction_splits
+			(transaction_id, household_id, method, paid_by, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if err := tx.QueryRow(
+		ctx,
+		query,
+		split.TransactionID,
+		split.HouseholdID,
+		split.Method,
+		split.PaidBy,
+		split.CreatedBy,
+	).Scan(
+		&split.ID,
+		&split.CreatedAt,
+		&split.UpdatedAt); err != nil {
+		span.SetStatus(codes.Error, "failed inserting split")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	shareQuery := `
+		INSERT INTO split_shares
+			(split_id, contact_id, value, amount)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`
+
+	for i := range split.Shares {
+		sh := &split.Shares[i]
+		sh.SplitID = split.ID
+		if err := tx.QueryRow(
+			ctx,
+			shareQuery,
+			sh.SplitID,
+			sh.ContactID,
+			sh.Value,
+			sh.Amount,
+		).Scan(&sh.ID); err != nil {
+			span.SetStatus(codes.Error, "failed inserting split share")
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		span.SetStatus(codes.Error, "failed committing split")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return split, nil
+}
+
+func (p *postgresSplitRepository) Delete(ctx context.Context, transactionID uint, version int) error {
+	tx, err := p.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := touchTransaction(ctx, tx, transactionID, version); err != nil {
+		return err
+	}
+
+	query := `
+		DELETE FROM transaction_splits
+		WHERE
+			transaction_id = $1`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := tx.Exec(ctx, query, transactionID)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to delete split")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type postgresSyncRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresSync(conn Connection) domain.SyncRepository {
+	tracer := otel.Tracer("db:postgres:sync")
+	return &postgresSyncRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresSyncRepository) GetChanges(ctx context.Context, householdID uint, userID uint, since int64, limit int) ([]domain.SyncChange, error) {
+	query := `
+		WITH changes AS (
+			SELECT 'account' AS type, X.id, X.change_seq, X.is_deleted,
+				to_jsonb(X) - 'is_deleted' - 'deleted_at' - 'change_seq' AS data
+			FROM accounts X
+			WHERE X.household_id = $1 AND X.change_seq > $2 AND (X.is_deleted = FALSE OR $2 > 0)
+			UNION ALL
+			SELECT 'category', X.id, GREATEST(X.change_seq, O.change_seq, R.change_seq), X.is_deleted,
+				to_jsonb(X) - 'is_deleted' - 'deleted_at' - 'change_seq' || jsonb_build_object(
+					'override', to_jsonb(O) - 'user_id' - 'category_id' - 'change_seq'
+				)
+			FROM categories X
+				LEFT JOIN category_overrides O ON O.category_id = X.id AND O.user_id = $4
+				LEFT JOIN LATERAL (
+					SELECT MAX(T.change_seq) AS change_seq
+					FROM sync_tombstones T
+					WHERE T.user_id = $4 AND T.entity_id = X.id AND T.entity_type = 'category_override'
+				) R ON TRUE
+			WHERE
+				(X.household_id = $1 OR X.household_id IS NULL)
+				AND GREATEST(X.change_seq, O.change_seq, R.change_seq) > $2
+				AND (X.is_deleted = FALSE OR $2 > 0)
+			UNION ALL
+			SELECT 'budget', X.id, X.change_seq, X.is_deleted,
+				to_jsonb(X) - 'is_deleted' - 'deleted_at' - 'change_seq'
+			FROM budgets X
+			WHERE X.household_id = $1 AND X.change_seq > $2 AND (X.is_deleted = FALSE OR $2 > 0)
+			UNION ALL
+			SELECT 'transaction', X.id, X.change_seq, X.is_deleted,
+				to_jsonb(X) - 'is_deleted' - 'deleted_at' - 'change_seq' || jsonb_build_object(
+					'lines', COALESCE((
+						SELECT jsonb_agg(jsonb_build_object('category_id', L.category_id, 'amount', L.amount, 'note', L.note) ORDER BY L.id)
+						FROM transaction_lines L WHERE L.transaction_id = X.id
+					), '[]'),
+					'tags', COALESCE((
+						SELECT jsonb_agg(G.name ORDER BY G.name)
+						FROM transaction_tags TT JOIN tags G ON G.id = TT.tag_id WHERE TT.transaction_id = X.id
+					), '[]')
+				)
+			FROM transactions X
+			WHERE X.household_id = $1 AND X.change_seq > $2 AND (X.is_deleted = FALSE OR $2 > 0)
+			UNION ALL
+			SELECT T.entity_type, T.entity_id, T.change_seq, TRUE, NULL
+			FROM sync_tombstones T
+			WHERE
+				(T.household_id = $1 OR (T.household_id IS NULL AND T.entity_type = 'category'))
+				AND T.change_seq > $2
+				AND $2 > 0
+		)
+		SELECT
+			C.type,
+			C.id,
+			S.client_id::TEXT,
+			C.is_deleted,
+			CASE WHEN C.is_deleted THEN NULL ELSE C.data END,
+			C.change_seq
+		FROM
+			changes C
+			LEFT JOIN sync_client_ids S ON S.entity_type = C.type AND S.entity_id = C.id
+		ORDER BY
+			C.change_seq ASC
+		LIMIT $3`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, householdID, since, limit, userID)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying sync changes")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []domain.SyncChange{}
+	for rows.Next() {
+		var change domain.SyncChange
+		var data []byte
+		if err := rows.Scan(
+			&change.Type,
+			&change.ID,
+			&change.ClientID,
+			&change.Deleted,
+			&data,
+			&change.Seq,
+		); err != nil {
+			return nil, err
+		}
+		change.Data = data
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func (p *postgresSyncRepository) GetClientID(ctx context.Context, clientID string) (domain.SyncClientID, error) {
+	query := `
+		SELECT
+			client_id::TEXT,
+			household_id,
+			entity_type,
+			entity_id
+		FROM
+			sync_client_ids
+		WHERE
+			client_id = $1`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, clientID)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying sync client ids")
+		span.RecordError(err)
+		return domain.SyncClientID{}, err
+	}
+	defer rows.Close()
+
+	ids := []domain.SyncClientID{}
+	for rows.Next() {
+		var id domain.SyncClientID
+		if err := rows.Scan(
+			&id.ClientID,
+			&id.HouseholdID,
+			&id.Type,
+			&id.EntityID,
+		); err != nil {
+			return domain.SyncClientID{}, err
+		}
+		ids = append(ids, id)
+	}
+
+	if len(ids) == 0 {
+		return domain.SyncClientID{}, domain.ErrNotFound
+	}
+	return ids[0], nil
+}
+
+func (p *postgresSyncRepository) ReserveClientID(ctx context.Context, id domain.SyncClientID) (bool, error) {
+	query := `
+		INSERT INTO sync_client_ids
+			(client_id, household_id, entity_type)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (client_id) DO UPDATE
+		SET
+			reserved_at = NOW()
+		WHERE
+			sync_client_ids.entity_id IS NULL
+			AND sync_client_ids.household_id = EXCLUDED.household_id
+			AND sync_client_ids.entity_type = EXCLUDED.entity_type
+			AND sync_client_ids.reserved_at < NOW() - INTERVAL '5 minutes'`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, id.ClientID, id.HouseholdID, id.Type)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to reserve sync client id")
+		span.RecordError(err)
+		return false, err
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+func (p *postgresSyncRepository) SetEntityID(ctx context.Context, clientID string, entityID uint) error {
+	query := `
+		UPDATE sync_client_ids
+		SET
+			entity_id = $2
+		WHERE
+			client_id = $1`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, clientID, entityID)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to set sync entity id")
+		span.RecordError(err)
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *postgresSyncRepository) ReleaseClientID(ctx context.Context, clientID string) error {
+	query := `
+		DELETE FROM sync_client_ids
+		WHERE
+			client_id = $1
+			AND entity_id IS NULL`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if _, err := p.conn.Exec(ctx, query, clientID); err != nil {
+		span.SetStatus(codes.Error, "failed to release sync client id")
+		span.RecordError(err)
+		return err
+	}
+
+	return nil
+}
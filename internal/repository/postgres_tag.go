@@ -0,0 +1,302 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type postgresTagRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresTag(conn Connection) domain.TagRepository {
+	tracer := otel.Tracer("db:postgres:tags")
+	return &postgresTagRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresTagRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.Tag, error) {
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying tags")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []domain.Tag{}
+	for rows.Next() {
+		var tag domain.Tag
+		if err := rows.Scan(
+			&tag.ID,
+			&tag.HouseholdID,
+			&tag.Name,
+			&tag.CreatedBy,
+			&tag.CreatedAt,
+			&tag.UpdatedAt,
+			&tag.Version,
+		); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func (p *postgresTagRepository) GetByID(ctx context.Context, id uint) (domain.Tag, error) {
+	query := `
+		SELECT
+			id,
+			household_id,
+			name,
+			created_by,
+			created_at,
+			updated_at,
+			version
+		FROM
+			tags
+		WHERE
+			id = $1`
+
+	tags, err := p.fetch(ctx, query, id)
+	if err != nil {
+		return domain.Tag{}, err
+	}
+
+	if len(tags) == 0 {
+		return domain.Tag{}, domain.ErrNotFound
+	}
+	return tags[0], nil
+}
+
+func (p *postgresTagRepository) GetByName(ctx context.Context, householdID uint, name string) (domain.Tag, error) {
+	query := `
+		SELECT
+			id,
+			household_id,
+			name,
+			created_by,
+			created_at,
+			updated_at,
+			version
+		FROM
+			tags
+		WHERE
+			household_id = $1
+			AND name = $2`
+
+	tags, err := p.fetch(ctx, query, householdID, name)
+	if err != nil {
+		return domain.Tag{}, err
+	}
+
+	if len(tags) == 0 {
+		return domain.Tag{}, domain.ErrNotFound
+	}
+	return tags[0], nil
+}
+
+func (p *postgresTagRepository) GetByHouseholdID(ctx context.Context, householdID uint) ([]domain.Tag, error) {
+	query := `
+		SELECT
+			id,
+			household_id,
+			name,
+			created_by,
+			created_at,
+			updated_at,
+			version
+		FROM
+			tags
+		WHERE
+			household_id = $1
+		ORDER BY
+			name ASC`
+
+	tags, err := p.fetch(ctx, query, householdID)
+	if err != nil {
+		return []domain.Tag{}, err
+	}
+
+	return tags, nil
+}
+
+func (p *postgresTagRepository) GetTotals(ctx context.Context, householdID uint, from time.Time, to time.Time) ([]domain.TagTotal, error) {
+	query := `
+		SELECT
+			G.id,
+			G.name,
+			T.operation,
+			SUM(T.amount),
+			COUNT(T.id)
+		FROM
+			transaction_tags TT
+			JOIN tags G ON TT.tag_id = G.id
+			JOIN transactions T ON TT.transaction_id = T.id
+		WHERE
+			G.household_id = $1
+			AND T.created_at >= $2
+			AND T.created_at < $3
+			AND T.is_deleted = FALSE
+		GROUP BY
+			G.id,
+			G.name,
+			T.operation
+		ORDER BY
+			G.name ASC,
+			T.operation ASC`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, householdID, from, to)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying tag totals")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := []domain.TagTotal{}
+	for rows.Next() {
+		var total domain.TagTotal
+		if err := rows.Scan(
+			&total.TagID,
+			&total.Name,
+			&total.Operation,
+			&total.Total,
+			&total.Count,
+		); err != nil {
+			return nil, err
+		}
+		totals = append(totals, total)
+	}
+
+	return totals, nil
+}
+
+func (p *postgresTagRepository) Create(ctx context.Context, tag *domain.Tag) (*domain.Tag, error) {
+	query := `
+		INSERT INTO tags
+			(household_id, name, created_by)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at, version`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if err := p.conn.QueryRow(
+		ctx,
+		query,
+		tag.HouseholdID,
+		tag.Name,
+		tag.CreatedBy,
+	).Scan(
+		&tag.ID,
+		&tag.CreatedAt,
+		&tag.UpdatedAt,
+		&tag.Version); err != nil {
+		span.SetStatus(codes.Error, "failed inserting tag")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+func (p *postgresTagRepository) Update(ctx context.Context, tag *domain.Tag) (*domain.Tag, error) {
+	query := `
+		UPDATE tags
+		SET
+			name = $2,
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND version = $3
+		RETURNING updated_at, version`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	row := p.conn.QueryRow(
+		ctx,
+		query,
+		tag.ID,
+		tag.Name,
+		tag.Version,
+	)
+
+	if err := row.Scan(&tag.UpdatedAt, &tag.Version); err != nil {
+		span.SetStatus(codes.Error, "failed to update tag")
+		span.RecordError(err)
+		return nil, versionError(err)
+	}
+
+	return tag, nil
+}
+
+func (p *postgresTagRepository) Delete(ctx context.Context, id uint, version int) error {
+	query := `
+		DELETE FROM tags
+		WHERE
+			id = $1
+			AND version = $2`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, id, version)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to delete tag")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrVersionConflict
+	}
+
+	return nil
+}
+
+func (p *postgresTagRepository) Merge(ctx context.Context, id uint, intoID uint) error {
+	query := `
+		WITH moved AS (
+			INSERT INTO transaction_tags
+				(transaction_id, tag_id)
+			SELECT transaction_id, $2
+			FROM transaction_tags
+			WHERE tag_id = $1
+			ON CONFLICT DO NOTHING
+		)
+		DELETE FROM tags
+		WHERE
+			id = $1`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, id, intoID)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to merge tag")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+
+	return nil
+}
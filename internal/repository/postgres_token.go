@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type postgresTokenRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresToken(conn Connection) domain.PersonalAccessTokenRepository {
+	tracer := otel.Tracer("db:postgres:personal_access_tokens")
+	return &postgresTokenRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresTokenRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.PersonalAccessToken, error) {
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying personal access tokens")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	toks := []domain.PersonalAccessToken{}
+	for rows.Next() {
+		var tok domain.PersonalAccessToken
+		if err := rows.Scan(
+			&tok.ID,
+			&tok.Name,
+			&tok.Prefix,
+			&tok.TokenHash,
+			&tok.Scopes,
+			&tok.ExpiresAt,
+			&tok.LastUsedAt,
+			&tok.RevokedAt,
+			&tok.CreatedBy,
+			&tok.CreatedAt,
+			&tok.UpdatedAt,
+			&tok.Version,
+		); err != nil {
+			return nil, err
+		}
+		toks = append(toks, tok)
+	}
+	return toks, nil
+}
+
+func (p *postgresTokenRepository) GetByID(ctx context.Context, id uint) (domain.PersonalAccessToken, error) {
+	query := `
+		SELECT
+			id,
+			name,
+			prefix,
+			token_hash,
+			scopes,
+			expires_at,
+			last_used_at,
+			revoked_at,
+			created_by,
+			created_at,
+			updated_at,
+			version
+		FROM
+			personal_access_tokens
+		WHERE
+			id = $1
+			AND is_deleted = FALSE`
+
+	toks, err := p.fetch(ctx, query, id)
+	if err != nil {
+		return domain.PersonalAccessToken{}, err
+	}
+
+	if len(toks) == 0 {
+		return domain.PersonalAccessToken{}, domain.ErrNotFound
+	}
+	return toks[0], nil
+}
+
+func (p *postgresTokenRepository) GetByHash(ctx context.Context, hash string) (domain.PersonalAccessToken, error) {
+	query := `
+		SELECT
+			id,
+			name,
+			prefix,
+			token_hash,
+			scopes,
+			expires_at,
+			last_used_at,
+			revoked_at,
+			created_by,
+			created_at,
+			updated_at,
+			version
+		FROM
+			personal_access_tokens
+		WHERE
+			token_hash = $1
+			AND is_deleted = FALSE`
+
+	toks, err := p.fetch(ctx, query, hash)
+	if err != nil {
+		return domain.PersonalAccessToken{}, err
+	}
+
+	if len(toks) == 0 {
+		return domain.PersonalAccessToken{}, domain.ErrNotFound
+	}
+	return toks[0], nil
+}
+
+func (p *postgresTokenRepository) GetByUserSUB(ctx context.Context, sub uint) ([]domain.PersonalAccessToken, error) {
+	query := `
+		SELECT
+			id,
+			name,
+			prefix,
+			token_hash,
+			scopes,
+			expires_at,
+			last_used_at,
+			revoked_at,
+			created_by,
+			created_at,
+			updated_at,
+			version
+		FROM
+			personal_access_tokens
+		WHERE
+			created_by = $1
+			AND is_deleted = FALSE
+		ORDER BY
+			created_at DESC`
+
+	toks, err := p.fetch(ctx, query, sub)
+	if err != nil {
+		return []domain.PersonalAccessToken{}, err
+	}
+
+	return toks, nil
+}
+
+func (p *postgresTokenRepository) Create(ctx context.Context, tok *domain.PersonalAccessToken) (*domain.PersonalAccessToken, error) {
+	query := `
+		INSERT INTO personal_access_tokens
+			(name, prefix, token_hash, scopes, expires_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at, version`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if err := p.conn.QueryRow(
+		ctx,
+		query,
+		tok.Name,
+		tok.Prefix,
+		tok.TokenHash,
+		tok.Scopes,
+		tok.ExpiresAt,
+		tok.CreatedBy,
+	).Scan(
+		&tok.ID,
+		&tok.CreatedAt,
+		&tok.UpdatedAt,
+		&tok.Version); err != nil {
+		span.SetStatus(codes.Error, "failed inserting personal access token")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return tok, nil
+}
+
+func (p *postgresTokenRepository) Touch(ctx context.Context, id uint) error {
+	query := `
+		UPDATE personal_access_tokens
+		SET
+			last_used_at = NOW()
+		WHERE
+			id = $1`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if _, err := p.conn.Exec(ctx, query, id); err != nil {
+		span.SetStatus(codes.Error, "failed to touch personal access token")
+		span.RecordError(err)
+		return err
+	}
+
+	return nil
+}
+
+func (p *postgresTokenRepository) Revoke(ctx context.Context, id uint, version int) error {
+	query := `
+		UPDATE personal_access_tokens
+		SET
+			revoked_at = NOW(),
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND version = $2
+			AND revoked_at IS NULL`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, id, version)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to revoke personal access token")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrVersionConflict
+	}
+
+	return nil
+}
+
+func (p *postgresTokenRepository) RevokeByUserSUB(ctx context.Context, sub uint) error {
+	query := `
+		UPDATE personal_access_tokens
+		SET
+			revoked_at = NOW(),
+			updated_at = NOW()
+		WHERE
+			created_by = $1
+			AND revoked_at IS NULL`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	if _, err := p.conn.Exec(ctx, query, sub); err != nil {
+		span.SetStatus(codes.Error, "failed to revoke personal access tokens")
+		span.RecordError(err)
+		return err
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/Brix101/budgetto-backend/internal/domain"
 	"go.opentelemetry.io/otel"
@@ -44,8 +45,13 @@ func (p *postgresTransactionRepository) fetch(ctx context.Context, query string,
 			&trn.AccountID,
 			&trn.CategoryID,
 			&trn.CreatedBy,
+			&trn.HouseholdID,
+			&trn.FamilyMemberID,
+			&trn.PayeeID,
+			&trn.PayeeName,
 			&trn.CreatedAt,
 			&trn.UpdatedAt,
+			&trn.Version,
 			&acc.ID,
 			&acc.Name,
 			&acc.Balance,
@@ -65,9 +71,216 @@ func (p *postgresTransactionRepository) fetch(ctx context.Context, query string,
 		trn.Category = cat
 		trns = append(trns, trn)
 	}
+	rows.Close()
+
+	if err := p.fetchLines(ctx, trns); err != nil {
+		return nil, err
+	}
+	if err := p.fetchTags(ctx, trns); err != nil {
+		return nil, err
+	}
 	return trns, nil
 }
 
+// fetchTags loads the tag names of trns in one query.
+func (p *postgresTransactionRepository) fetchTags(ctx context.Context, trns []domain.Transaction) error {
+	if len(trns) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT
+			TT.transaction_id,
+			G.name
+		FROM
+			transaction_tags TT
+			JOIN tags G ON TT.tag_id = G.id
+		WHERE
+			TT.transaction_id = ANY($1::INTEGER[])
+		ORDER BY
+			G.name ASC`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	ids := make([]int64, len(trns))
+	index := map[uint]int{}
+	for i, trn := range trns {
+		ids[i] = int64(trn.ID)
+		index[trn.ID] = i
+	}
+
+	rows, err := p.conn.Query(ctx, query, ids)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying transaction tags")
+		span.RecordError(err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uint
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		i := index[id]
+		trns[i].Tags = append(trns[i].Tags, name)
+	}
+	return nil
+}
+
+// saveTags replaces the tags of trn, creating the household tags that don't
+// exist yet.
+func saveTags(ctx context.Context, conn Connection, trn *domain.Transaction) error {
+	if _, err := conn.Exec(ctx, `DELETE FROM transaction_tags WHERE transaction_id = $1`, trn.ID); err != nil {
+		return err
+	}
+
+	query := `
+		WITH tag AS (
+			INSERT INTO tags
+				(household_id, name, created_by)
+			VALUES ($2, $3, $4)
+			ON CONFLICT (household_id, name) DO UPDATE SET name = EXCLUDED.name
+			RETURNING id
+		)
+		INSERT INTO transaction_tags
+			(transaction_id, tag_id)
+		SELECT $1, id FROM tag`
+
+	trn.Tags = domain.NormalizeTags(trn.Tags)
+	for _, name := range trn.Tags {
+		if _, err := conn.Exec(ctx, query, trn.ID, trn.HouseholdID, name, trn.CreatedBy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchLines loads the category lines of trns in one query.
+func (p *postgresTransactionRepository) fetchLines(ctx context.Context, trns []domain.Transaction) error {
+	if len(trns) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT
+			L.id,
+			L.transaction_id,
+			L.category_id,
+			C.name,
+			L.amount,
+			L.note
+		FROM
+			transaction_lines L
+			JOIN categories C ON L.category_id = C.id
+		WHERE
+			L.transaction_id = ANY($1::INTEGER[])
+		ORDER BY
+			L.id ASC`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	ids := make([]int64, len(trns))
+	index := map[uint]int{}
+	for i, trn := range trns {
+		ids[i] = int64(trn.ID)
+		index[trn.ID] = i
+	}
+
+	rows, err := p.conn.Query(ctx, query, ids)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying transaction lines")
+		span.RecordError(err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line domain.TransactionLine
+		if err := rows.Scan(
+			&line.ID,
+			&line.TransactionID,
+			&line.CategoryID,
+			&line.CategoryName,
+			&line.Amount,
+			&line.Note,
+		); err != nil {
+			return err
+		}
+		i := index[line.TransactionID]
+		trns[i].Lines = append(trns[i].Lines, line)
+	}
+	return nil
+}
+
+// saveLines replaces the category lines of trn.
+func saveLines(ctx context.Context, conn Connection, trn *domain.Transaction) error {
+	if _, err := conn.Exec(ctx, `DELETE FROM transaction_lines WHERE transaction_id = $1`, trn.ID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO transaction_lines
+			(transaction_id, category_id, amount, note)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`
+
+	for i := range trn.Lines {
+		line := &trn.Lines[i]
+		line.TransactionID = trn.ID
+		if err := conn.QueryRow(
+			ctx,
+			query,
+			line.TransactionID,
+			line.CategoryID,
+			line.Amount,
+			line.Note,
+		).Scan(&line.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveVersion adds how the transaction looks now, lines and tags included,
+// to its history under the transaction's own version, so a history entry and
+// the ETag it was served with share a number. It runs after the lines and
+// tags have been saved.
+func saveVersion(ctx context.Context, conn Connection, id uint) error {
+	query := `
+		INSERT INTO transaction_versions
+			(transaction_id, version, amount, note, operation, account_id, category_id, family_member_id, payee_id, lines, tags, edited_by)
+		SELECT
+			T.id,
+			T.version,
+			T.amount,
+			T.note,
+			T.operation,
+			T.account_id,
+			T.category_id,
+			T.family_member_id,
+			T.payee_id,
+			COALESCE((
+				SELECT jsonb_agg(jsonb_build_object('category_id', L.category_id, 'amount', L.amount, 'note', L.note) ORDER BY L.id)
+				FROM transaction_lines L WHERE L.transaction_id = T.id
+			), '[]'),
+			COALESCE((
+				SELECT array_agg(G.name ORDER BY G.name)
+				FROM transaction_tags TT JOIN tags G ON G.id = TT.tag_id WHERE TT.transaction_id = T.id
+			), '{}'),
+			NULLIF(current_setting('budgetto.actor_id', TRUE), '')::INTEGER
+		FROM
+			transactions T
+		WHERE
+			T.id = $1`
+
+	_, err := conn.Exec(ctx, query, id)
+	return err
+}
+
 func (p *postgresTransactionRepository) GetByID(ctx context.Context, id uint) (domain.Transaction, error) {
 	query := `
 		SELECT 
@@ -78,8 +291,13 @@ func (p *postgresTransactionRepository) GetByID(ctx context.Context, id uint) (d
 			T.account_id,
 			T.category_id,
 			T.created_by,
+			T.household_id,
+			T.family_member_id,
+			T.payee_id,
+			COALESCE(P.name, '') AS payee_name,
 			T.created_at,
 			T.updated_at,
+			T.version,
 			A.ID AS acc_id,
 			A.NAME AS acc_name,
 			A.balance AS acc_balance,
@@ -95,6 +313,7 @@ func (p *postgresTransactionRepository) GetByID(ctx context.Context, id uint) (d
 			transactions T 
 			JOIN accounts A ON T.account_id = A.ID 
 			JOIN categories C ON T.category_id = C.ID 
+			LEFT JOIN payees P ON T.payee_id = P.id
 		WHERE
 			T.ID = $1 
 			AND T.is_deleted = FALSE;`
@@ -110,7 +329,7 @@ func (p *postgresTransactionRepository) GetByID(ctx context.Context, id uint) (d
 	return trns[0], nil
 }
 
-func (p *postgresTransactionRepository) GetByUserSUB(ctx context.Context, sub string) ([]domain.Transaction, error) {
+func (p *postgresTransactionRepository) GetByHouseholdID(ctx context.Context, householdID uint, filter domain.TransactionFilter) ([]domain.Transaction, error) {
 	query := `
 		SELECT 
 			T.ID,
@@ -120,8 +339,13 @@ func (p *postgresTransactionRepository) GetByUserSUB(ctx context.Context, sub st
 			T.account_id,
 			T.category_id,
 			T.created_by,
+			T.household_id,
+			T.family_member_id,
+			T.payee_id,
+			COALESCE(P.name, '') AS payee_name,
 			T.created_at,
 			T.updated_at,
+			T.version,
 			A.ID AS acc_id,
 			A.NAME AS acc_name,
 			A.balance AS acc_balance,
@@ -137,11 +361,88 @@ func (p *postgresTransactionRepository) GetByUserSUB(ctx context.Context, sub st
 			transactions T 
 			JOIN accounts A ON T.account_id = A.ID 
 			JOIN categories C ON T.category_id = C.ID 
+			LEFT JOIN payees P ON T.payee_id = P.id
 		WHERE
-			T.created_by = $1 
+			T.household_id = $1 
+			AND ($2::INTEGER IS NULL OR T.family_member_id = $2)
+			AND ($5::INTEGER IS NULL OR T.payee_id = $5)
+			AND ($6::INTEGER IS NULL OR T.account_id = $6)
+			AND ($7::INTEGER IS NULL OR T.category_id = $7)
+			AND ($8::VARCHAR = '' OR T.operation::VARCHAR = $8)
+			AND ($9::TIMESTAMPTZ IS NULL OR T.created_at >= $9)
+			AND ($10::TIMESTAMPTZ IS NULL OR T.created_at < $10)
+			AND (
+				cardinality($3::VARCHAR[]) = 0
+				OR (
+					SELECT COUNT(*)
+					FROM transaction_tags TT JOIN tags G ON TT.tag_id = G.id
+					WHERE TT.transaction_id = T.ID AND G.name = ANY($3::VARCHAR[])
+				) >= CASE WHEN $4 THEN cardinality($3::VARCHAR[]) ELSE 1 END
+			)
 			AND T.is_deleted = FALSE;`
 
-	trns, err := p.fetch(ctx, query, sub)
+	tags := domain.NormalizeTags(filter.Tags)
+	trns, err := p.fetch(
+		ctx,
+		query,
+		householdID,
+		filter.FamilyMemberID,
+		tags,
+		filter.TagMatch == domain.TagMatchAll,
+		filter.PayeeID,
+		filter.AccountID,
+		filter.CategoryID,
+		filter.Operation,
+		filter.From,
+		filter.To,
+	)
+	if err != nil {
+		return []domain.Transaction{}, err
+	}
+
+	return trns, nil
+}
+
+func (p *postgresTransactionRepository) GetByAccountID(ctx context.Context, accountID uint) ([]domain.Transaction, error) {
+	query := `
+		SELECT 
+			T.ID,
+			T.amount,
+			T.note,
+			T.operation,
+			T.account_id,
+			T.category_id,
+			T.created_by,
+			T.household_id,
+			T.family_member_id,
+			T.payee_id,
+			COALESCE(P.name, '') AS payee_name,
+			T.created_at,
+			T.updated_at,
+			T.version,
+			A.ID AS acc_id,
+			A.NAME AS acc_name,
+			A.balance AS acc_balance,
+			A.note AS acc_note,
+			A.created_at AS acc_created_at,
+			A.updated_at AS acc_updated_at,
+			C.ID AS cat_id,
+			C.NAME AS cat_name,
+			C.note AS cat_note,
+			C.created_at AS cat_created_at,
+			C.updated_at AS cat_updated_at 
+		FROM
+			transactions T 
+			JOIN accounts A ON T.account_id = A.ID 
+			JOIN categories C ON T.category_id = C.ID 
+			LEFT JOIN payees P ON T.payee_id = P.id
+		WHERE
+			T.account_id = $1 
+			AND T.is_deleted = FALSE
+		ORDER BY
+			T.created_at DESC;`
+
+	trns, err := p.fetch(ctx, query, accountID)
 	if err != nil {
 		return []domain.Transaction{}, err
 	}
@@ -149,6 +450,65 @@ func (p *postgresTransactionRepository) GetByUserSUB(ctx context.Context, sub st
 	return trns, nil
 }
 
+func (p *postgresTransactionRepository) GetCategoryTotals(ctx context.Context, householdID uint, from time.Time, to time.Time) ([]domain.CategoryTotal, error) {
+	query := `
+		SELECT
+			C.ID,
+			C.parent_id,
+			C.NAME,
+			T.operation,
+			COALESCE(SUM(T.amount) FILTER (WHERE T.category_id = C.ID), 0),
+			SUM(T.amount),
+			COUNT(DISTINCT T.transaction_id) FILTER (WHERE T.category_id = C.ID)
+		FROM
+			transaction_allocations T
+			JOIN category_closure CC ON T.category_id = CC.descendant_id
+			JOIN categories C ON CC.ancestor_id = C.ID
+		WHERE
+			T.household_id = $1
+			AND T.created_at >= $2
+			AND T.created_at < $3
+			AND T.is_deleted = FALSE
+		GROUP BY
+			C.ID,
+			C.parent_id,
+			C.NAME,
+			T.operation
+		ORDER BY
+			C.NAME ASC,
+			T.operation ASC;`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, householdID, from, to)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying category totals")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := []domain.CategoryTotal{}
+	for rows.Next() {
+		var total domain.CategoryTotal
+		if err := rows.Scan(
+			&total.CategoryID,
+			&total.ParentID,
+			&total.Name,
+			&total.Operation,
+			&total.Total,
+			&total.Rollup,
+			&total.Count,
+		); err != nil {
+			return nil, err
+		}
+		totals = append(totals, total)
+	}
+
+	return totals, nil
+}
+
 func (p *postgresTransactionRepository) GetOperationType(ctx context.Context) ([]string, error) {
 	query := `
         SELECT enumlabel
@@ -183,79 +543,193 @@ func (p *postgresTransactionRepository) GetOperationType(ctx context.Context) ([
 func (p *postgresTransactionRepository) Create(ctx context.Context, trn *domain.Transaction) (*domain.Transaction, error) {
 	query := `
 		INSERT INTO transactions
-			(amount, note, account_id, category_id, created_by)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, created_at, updated_at`
+			(amount, note, operation, account_id, category_id, created_by, household_id, family_member_id, payee_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at, version`
 
 	ctx, span := spanWithQuery(ctx, p.tracer, query)
 	defer span.End()
 
-	if err := p.conn.QueryRow(
+	tx, err := p.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := tx.QueryRow(
 		ctx,
 		query,
 		trn.Amount,
+		trn.Note,
+		trn.Operation,
+		trn.AccountID,
 		trn.CategoryID,
 		trn.CreatedBy,
+		trn.HouseholdID,
+		trn.FamilyMemberID,
+		trn.PayeeID,
 	).Scan(
 		&trn.ID,
 		&trn.CreatedAt,
-		&trn.UpdatedAt); err != nil {
+		&trn.UpdatedAt,
+		&trn.Version); err != nil {
 		span.SetStatus(codes.Error, "failed inserting transaction")
 		span.RecordError(err)
 		return nil, err
 	}
 
+	if err := saveLines(ctx, tx, trn); err != nil {
+		span.SetStatus(codes.Error, "failed inserting transaction lines")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	if err := saveTags(ctx, tx, trn); err != nil {
+		span.SetStatus(codes.Error, "failed inserting transaction tags")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	if err := saveVersion(ctx, tx, trn.ID); err != nil {
+		span.SetStatus(codes.Error, "failed inserting transaction version")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
 	return trn, nil
 }
 
 func (p *postgresTransactionRepository) Update(ctx context.Context, trn *domain.Transaction) (*domain.Transaction, error) {
-	query := `
+	ctx, span := spanWithQuery(ctx, p.tracer, updateTransactionQuery)
+	defer span.End()
+
+	tx, err := p.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := updateTransaction(ctx, tx, trn); err != nil {
+		span.SetStatus(codes.Error, "failed to update transaction")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return trn, nil
+}
+
+// UpdateMany saves every transaction in one database transaction. A stale
+// version on any of them leaves all of them unchanged.
+func (p *postgresTransactionRepository) UpdateMany(ctx context.Context, trns []domain.Transaction) error {
+	ctx, span := spanWithQuery(ctx, p.tracer, updateTransactionQuery)
+	defer span.End()
+
+	tx, err := p.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for i := range trns {
+		if err := updateTransaction(ctx, tx, &trns[i]); err != nil {
+			span.SetStatus(codes.Error, "failed to update transactions")
+			span.RecordError(err)
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+const updateTransactionQuery = `
 		UPDATE transactions
 		SET 
 			amount = $2,
 			note = $3,
 			account_id = $4,
 			category_id = $5,
+			family_member_id = $6,
+			payee_id = $7,
+			operation = $9,
 			updated_at = NOW()
 		WHERE 
 			id = $1
-		RETURNING updated_at`
-
-	ctx, span := spanWithQuery(ctx, p.tracer, query)
-	defer span.End()
+			AND version = $8
+		RETURNING updated_at, version`
+
+// updateTransaction saves trn with its lines and tags and records the new
+// version, all on conn. A split on the transaction is worked out again for
+// the new amount first; when its shares no longer fit, the error is returned
+// before anything is written.
+func updateTransaction(ctx context.Context, conn Connection, trn *domain.Transaction) error {
+	split, err := transactionSplit(ctx, conn, trn.ID)
+	if err != nil {
+		return err
+	}
+	if split != nil {
+		if err := split.Allocate(trn.Amount); err != nil {
+			return err
+		}
+	}
 
-	row := p.conn.QueryRow(
+	row := conn.QueryRow(
 		ctx,
-		query,
+		updateTransactionQuery,
 		trn.ID,
 		trn.Amount,
 		trn.Note,
 		trn.AccountID,
 		trn.CategoryID,
+		trn.FamilyMemberID,
+		trn.PayeeID,
+		trn.Version,
+		trn.Operation,
 	)
 
-	if err := row.Scan(&trn.UpdatedAt); err != nil {
-		span.SetStatus(codes.Error, "failed to update transaction")
-		span.RecordError(err)
-		return nil, err
+	if err := row.Scan(&trn.UpdatedAt, &trn.Version); err != nil {
+		return versionError(err)
 	}
 
-	return trn, nil
+	if split != nil {
+		if err := saveShareAmounts(ctx, conn, split); err != nil {
+			return err
+		}
+	}
+
+	if err := saveLines(ctx, conn, trn); err != nil {
+		return err
+	}
+
+	if err := saveTags(ctx, conn, trn); err != nil {
+		return err
+	}
+
+	return saveVersion(ctx, conn, trn.ID)
 }
 
-func (p *postgresTransactionRepository) Delete(ctx context.Context, id uint) error {
+func (p *postgresTransactionRepository) Delete(ctx context.Context, id uint, version int) error {
 	query := `
 		UPDATE transactions
 		SET 
 			is_deleted = TRUE,
+			deleted_at = NOW(),
 			updated_at = NOW()
 		WHERE 
-			id = $1`
+			id = $1
+			AND version = $2`
 
 	ctx, span := spanWithQuery(ctx, p.tracer, query)
 	defer span.End()
 
-	result, err := p.conn.Exec(ctx, query, id)
+	result, err := p.conn.Exec(ctx, query, id, version)
 	if err != nil {
 		span.SetStatus(codes.Error, "failed to delete transaction")
 		span.RecordError(err)
@@ -264,8 +738,175 @@ func (p *postgresTransactionRepository) Delete(ctx context.Context, id uint) err
 
 	rowsAffected := result.RowsAffected()
 	if rowsAffected == 0 {
-		return domain.ErrNotFound
+		return domain.ErrVersionConflict
 	}
 
 	return nil
 }
+
+// DeleteMany moves the transactions to the trash in one statement, each
+// only at the version it carries. If any of them has changed since, none
+// are moved.
+func (p *postgresTransactionRepository) DeleteMany(ctx context.Context, trns []domain.Transaction) (int64, error) {
+	query := `
+		UPDATE transactions T
+		SET 
+			is_deleted = TRUE,
+			deleted_at = NOW(),
+			updated_at = NOW()
+		FROM
+			unnest($1::INTEGER[], $2::INTEGER[]) AS D (id, version)
+		WHERE 
+			T.id = D.id
+			AND T.version = D.version
+			AND T.is_deleted = FALSE`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	tx, err := p.conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	ids := make([]int64, len(trns))
+	versions := make([]int64, len(trns))
+	for i, trn := range trns {
+		ids[i] = int64(trn.ID)
+		versions[i] = int64(trn.Version)
+	}
+
+	result, err := tx.Exec(ctx, query, ids, versions)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to delete transactions")
+		span.RecordError(err)
+		return 0, err
+	}
+
+	if result.RowsAffected() != int64(len(trns)) {
+		return 0, domain.ErrVersionConflict
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (p *postgresTransactionRepository) fetchVersions(ctx context.Context, query string, args ...interface{}) ([]domain.TransactionVersion, error) {
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying transaction versions")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []domain.TransactionVersion{}
+	for rows.Next() {
+		var v domain.TransactionVersion
+		if err := rows.Scan(
+			&v.ID,
+			&v.TransactionID,
+			&v.Version,
+			&v.Amount,
+			&v.Note,
+			&v.Operation,
+			&v.AccountID,
+			&v.AccountName,
+			&v.CategoryID,
+			&v.CategoryName,
+			&v.FamilyMemberID,
+			&v.PayeeID,
+			&v.Lines,
+			&v.Tags,
+			&v.EditedBy,
+			&v.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+
+	return versions, nil
+}
+
+func (p *postgresTransactionRepository) GetVersions(ctx context.Context, id uint) ([]domain.TransactionVersion, error) {
+	query := `
+		SELECT
+			V.id,
+			V.transaction_id,
+			V.version,
+			COALESCE(V.amount, 0),
+			COALESCE(V.note, ''),
+			V.operation,
+			V.account_id,
+			COALESCE(A.name, ''),
+			V.category_id,
+			COALESCE(C.name, ''),
+			V.family_member_id,
+			V.payee_id,
+			V.lines,
+			V.tags,
+			V.edited_by,
+			V.created_at
+		FROM
+			transaction_versions V
+			LEFT JOIN accounts A ON V.account_id = A.id
+			LEFT JOIN categories C ON V.category_id = C.id
+		WHERE
+			V.transaction_id = $1
+		ORDER BY
+			V.version ASC`
+
+	versions, err := p.fetchVersions(ctx, query, id)
+	if err != nil {
+		return []domain.TransactionVersion{}, err
+	}
+
+	return versions, nil
+}
+
+func (p *postgresTransactionRepository) GetVersion(ctx context.Context, id uint, version int) (domain.TransactionVersion, error) {
+	query := `
+		SELECT
+			V.id,
+			V.transaction_id,
+			V.version,
+			COALESCE(V.amount, 0),
+			COALESCE(V.note, ''),
+			V.operation,
+			V.account_id,
+			COALESCE(A.name, ''),
+			V.category_id,
+			COALESCE(C.name, ''),
+			V.family_member_id,
+			V.payee_id,
+			V.lines,
+			V.tags,
+			V.edited_by,
+			V.created_at
+		FROM
+			transaction_versions V
+			LEFT JOIN accounts A ON V.account_id = A.id
+			LEFT JOIN categories C ON V.category_id = C.id
+		WHERE
+			V.transaction_id = $1
+			AND V.version = $2`
+
+	versions, err := p.fetchVersions(ctx, query, id, version)
+	if err != nil {
+		return domain.TransactionVersion{}, err
+	}
+
+	if len(versions) == 0 {
+		return domain.TransactionVersion{}, domain.ErrNotFound
+	}
+
+	return versions[0], nil
+}
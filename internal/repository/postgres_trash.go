@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+// trashQuery lists every soft-deleted record that can be restored.
+const trashQuery = `
+		SELECT 'account' AS type, id, household_id, name, balance AS amount, deleted_at
+		FROM accounts
+		WHERE is_deleted = TRUE
+		UNION ALL
+		SELECT 'category', id, household_id, name, NULL::DOUBLE PRECISION, deleted_at
+		FROM categories
+		WHERE is_deleted = TRUE
+		UNION ALL
+		SELECT 'budget', B.id, B.household_id, C.name, B.amount, B.deleted_at
+		FROM budgets B JOIN categories C ON C.id = B.category_id
+		WHERE B.is_deleted = TRUE
+		UNION ALL
+		SELECT 'transaction', id, household_id, COALESCE(note, ''), amount, deleted_at
+		FROM transactions
+		WHERE is_deleted = TRUE`
+
+// restoreQueries are run in order to restore a record; the first one
+// restores the record itself, the rest bring back what it depends on.
+var restoreQueries = map[string][]string{
+	domain.TrashAccount: {
+		`UPDATE accounts SET is_deleted = FALSE, deleted_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND is_deleted = TRUE`,
+	},
+	domain.TrashCategory: {
+		`UPDATE categories SET is_deleted = FALSE, deleted_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND is_deleted = TRUE`,
+	},
+	domain.TrashBudget: {
+		`UPDATE budgets SET is_deleted = FALSE, deleted_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND is_deleted = TRUE`,
+		`UPDATE categories SET is_deleted = FALSE, deleted_at = NULL, updated_at = NOW()
+		WHERE id = (SELECT category_id FROM budgets WHERE id = $1) AND is_deleted = TRUE`,
+	},
+	domain.TrashTransaction: {
+		`UPDATE transactions SET is_deleted = FALSE, deleted_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND is_deleted = TRUE`,
+		`UPDATE accounts SET is_deleted = FALSE, deleted_at = NULL, updated_at = NOW()
+		WHERE id = (SELECT account_id FROM transactions WHERE id = $1) AND is_deleted = TRUE`,
+		`UPDATE categories SET is_deleted = FALSE, deleted_at = NULL, updated_at = NOW()
+		WHERE
+			is_deleted = TRUE
+			AND id IN (
+				SELECT category_id FROM transaction_allocations WHERE transaction_id = $1
+			)`,
+	},
+}
+
+// purgeQueries are run in order so that records are gone before the ones
+// they point at are considered.
+var purgeQueries = []string{
+	`DELETE FROM transactions
+	WHERE is_deleted = TRUE AND deleted_at < $1`,
+	`DELETE FROM budgets
+	WHERE is_deleted = TRUE AND deleted_at < $1`,
+	`DELETE FROM accounts A
+	WHERE
+		is_deleted = TRUE
+		AND deleted_at < $1
+		AND NOT EXISTS (SELECT 1 FROM transactions T WHERE T.account_id = A.id)
+		AND NOT EXISTS (SELECT 1 FROM rules R WHERE R.account_id = A.id)`,
+	`DELETE FROM categories C
+	WHERE
+		is_deleted = TRUE
+		AND deleted_at < $1
+		AND NOT EXISTS (SELECT 1 FROM transactions T WHERE T.category_id = C.id)
+		AND NOT EXISTS (SELECT 1 FROM transaction_lines L WHERE L.category_id = C.id)
+		AND NOT EXISTS (SELECT 1 FROM budgets B WHERE B.category_id = C.id)
+		AND NOT EXISTS (SELECT 1 FROM rules R WHERE R.set_category_id = C.id)`,
+}
+
+type postgresTrashRepository struct {
+	conn   Connection
+	tracer trace.Tracer
+}
+
+func NewPostgresTrash(conn Connection) domain.TrashRepository {
+	tracer := otel.Tracer("db:postgres:trash")
+	return &postgresTrashRepository{conn: conn, tracer: tracer}
+}
+
+func (p *postgresTrashRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.TrashItem, error) {
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying trash")
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []domain.TrashItem{}
+	for rows.Next() {
+		var item domain.TrashItem
+		var deletedAt *time.Time
+		if err := rows.Scan(
+			&item.Type,
+			&item.ID,
+			&item.HouseholdID,
+			&item.Name,
+			&item.Amount,
+			&deletedAt,
+		); err != nil {
+			return nil, err
+		}
+		if deletedAt != nil {
+			item.DeletedAt = *deletedAt
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func (p *postgresTrashRepository) GetByHouseholdID(ctx context.Context, householdID uint, kind string) ([]domain.TrashItem, error) {
+	query := `
+		SELECT
+			type,
+			id,
+			household_id,
+			name,
+			amount,
+			deleted_at
+		FROM (` + trashQuery + `
+		) X
+		WHERE
+			household_id = $1
+			AND ($2 = '' OR type = $2)
+		ORDER BY
+			deleted_at DESC NULLS LAST`
+
+	items, err := p.fetch(ctx, query, householdID, kind)
+	if err != nil {
+		return []domain.TrashItem{}, err
+	}
+
+	return items, nil
+}
+
+func (p *postgresTrashRepository) GetByID(ctx context.Context, kind string, id uint) (domain.TrashItem, error) {
+	query := `
+		SELECT
+			type,
+			id,
+			household_id,
+			name,
+			amount,
+			deleted_at
+		FROM (` + trashQuery + `
+		) X
+		WHERE
+			type = $1
+			AND id = $2`
+
+	items, err := p.fetch(ctx, query, kind, id)
+	if err != nil {
+		return domain.TrashItem{}, err
+	}
+
+	if len(items) == 0 {
+		return domain.TrashItem{}, domain.ErrNotFound
+	}
+
+	return items[0], nil
+}
+
+func (p *postgresTrashRepository) Restore(ctx context.Context, kind string, id uint) error {
+	queries, ok := restoreQueries[kind]
+	if !ok {
+		return domain.ErrTrashType
+	}
+
+	tx, err := p.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for i, query := range queries {
+		ctx, span := spanWithQuery(ctx, p.tracer, query)
+		result, err := tx.Exec(ctx, query, id)
+		if err != nil {
+			span.SetStatus(codes.Error, "failed to restore "+kind)
+			span.RecordError(err)
+			span.End()
+			return err
+		}
+		span.End()
+
+		if i == 0 && result.RowsAffected() == 0 {
+			return domain.ErrNotFound
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (p *postgresTrashRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	tx, err := p.conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var purged int64
+	for _, query := range purgeQueries {
+		ctx, span := spanWithQuery(ctx, p.tracer, query)
+		result, err := tx.Exec(ctx, query, before)
+		if err != nil {
+			span.SetStatus(codes.Error, "failed to purge trash")
+			span.RecordError(err)
+			span.End()
+			return 0, err
+		}
+		span.End()
+
+		purged += result.RowsAffected()
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/codes"
@@ -40,9 +41,15 @@ func (p *postgresUserRepository) fetch(ctx context.Context, query string, args .
 			&usr.ID,
 			&usr.Name,
 			&usr.Email,
+			&usr.EmailVerifiedAt,
 			&usr.Password,
 			&usr.Bio,
 			&usr.Image,
+			&usr.Role,
+			&usr.IsActive,
+			&usr.DeleteAfter,
+			&usr.SessionsRevokedAt,
+			&usr.Version,
 			&usr.CreatedAt,
 			&usr.UpdatedAt,
 		); err != nil {
@@ -60,9 +67,15 @@ func (p *postgresUserRepository) GetByID(ctx context.Context, id uint) (domain.U
 			id,
 			name,
 			email,
+			email_verified_at,
 			password,
 			bio,
 			image,
+			role,
+			is_active,
+			delete_after,
+			sessions_revoked_at,
+			version,
 			created_at,
 			updated_at
 		FROM
@@ -89,9 +102,15 @@ func (p *postgresUserRepository) GetByEmail(ctx context.Context, email string) (
 			id,
 			name,
 			email,
+			email_verified_at,
 			password,
 			bio,
 			image,
+			role,
+			is_active,
+			delete_after,
+			sessions_revoked_at,
+			version,
 			created_at,
 			updated_at
 		FROM
@@ -112,11 +131,51 @@ func (p *postgresUserRepository) GetByEmail(ctx context.Context, email string) (
 	return usr[0], nil
 }
 
+func (p *postgresUserRepository) List(ctx context.Context, filter domain.UserFilter) ([]domain.User, error) {
+	query := `
+		SELECT
+			id,
+			name,
+			email,
+			email_verified_at,
+			password,
+			bio,
+			image,
+			role,
+			is_active,
+			delete_after,
+			sessions_revoked_at,
+			version,
+			created_at,
+			updated_at
+		FROM
+			users
+		WHERE
+			is_deleted = FALSE
+			AND ($1 = '' OR name ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%')
+			AND ($2 = '' OR role = $2)
+		ORDER BY
+			id ASC
+		LIMIT $3 OFFSET $4`
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	usrs, err := p.fetch(ctx, query, filter.Query, filter.Role, limit, filter.Offset)
+	if err != nil {
+		return []domain.User{}, err
+	}
+
+	return usrs, nil
+}
+
 func (p *postgresUserRepository) Create(ctx context.Context, usr *domain.User) (*domain.User, error) {
 	query := `
-		INSERT INTO users (name, email, password, bio, image)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, created_at, updated_at`
+		INSERT INTO users (name, email, email_verified_at, password, bio, image)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, role, is_active, version, created_at, updated_at`
 
 	ctx, span := spanWithQuery(ctx, p.tracer, query)
 	defer span.End()
@@ -126,11 +185,15 @@ func (p *postgresUserRepository) Create(ctx context.Context, usr *domain.User) (
 		query,
 		usr.Name,
 		usr.Email,
+		usr.EmailVerifiedAt,
 		usr.Password,
 		usr.Bio,
 		usr.Image,
 	).Scan(
 		&usr.ID,
+		&usr.Role,
+		&usr.IsActive,
+		&usr.Version,
 		&usr.CreatedAt,
 		&usr.UpdatedAt); err != nil {
 		span.SetStatus(codes.Error, "failed inserting users")
@@ -146,13 +209,15 @@ func (p *postgresUserRepository) Update(ctx context.Context, usr *domain.User) (
 		SET
 			name = $2,
 			email = $3,
-			password = $4,
-			bio = $5,
-			image = $6,
+			email_verified_at = $4,
+			password = $5,
+			bio = $6,
+			image = $7,
 			updated_at = NOW()
 		WHERE
 			id = $1
-		RETURNING updated_at`
+			AND version = $8
+		RETURNING updated_at, version`
 
 	ctx, span := spanWithQuery(ctx, p.tracer, query)
 	defer span.End()
@@ -163,15 +228,17 @@ func (p *postgresUserRepository) Update(ctx context.Context, usr *domain.User) (
 		usr.ID,
 		usr.Name,
 		usr.Email,
+		usr.EmailVerifiedAt,
 		usr.Password,
 		usr.Bio,
 		usr.Image,
+		usr.Version,
 	)
 
-	if err := row.Scan(&usr.UpdatedAt); err != nil {
+	if err := row.Scan(&usr.UpdatedAt, &usr.Version); err != nil {
 		span.SetStatus(codes.Error, "failed to update User")
 		span.RecordError(err)
-		return nil, err
+		return nil, versionError(err)
 	}
 
 	return usr, nil
@@ -203,3 +270,286 @@ func (p *postgresUserRepository) Delete(ctx context.Context, id uint) error {
 
 	return nil
 }
+
+func (p *postgresUserRepository) ScheduleDeletion(ctx context.Context, id uint, version int, at time.Time) error {
+	query := `
+		UPDATE users
+		SET
+			delete_after = $2,
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND version = $3`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, id, at, version)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to schedule User deletion")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrVersionConflict
+	}
+
+	return nil
+}
+
+func (p *postgresUserRepository) CancelDeletion(ctx context.Context, id uint) error {
+	query := `
+		UPDATE users
+		SET
+			delete_after = NULL,
+			updated_at = NOW()
+		WHERE
+			id = $1`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, id)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to cancel User deletion")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+
+	return nil
+}
+
+// householdTables hold household data with a created_by reference to users
+// that cascades on delete.
+var householdTables = []string{
+	"accounts",
+	"budgets",
+	"categories",
+	"contacts",
+	"family_members",
+	"payees",
+	"rules",
+	"settlements",
+	"share_links",
+	"tags",
+	"transaction_splits",
+	"transactions",
+}
+
+func (p *postgresUserRepository) PurgeScheduled(ctx context.Context) (int64, error) {
+	tx, err := p.conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		SELECT id FROM users
+		WHERE
+			delete_after IS NOT NULL
+			AND delete_after <= NOW()
+		FOR UPDATE`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed querying Users to purge")
+		span.RecordError(err)
+		return 0, err
+	}
+
+	ids := []uint{}
+	for rows.Next() {
+		var id uint
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(ids) == 0 {
+		return 0, tx.Commit(ctx)
+	}
+
+	// Personal households go with their user. Shared households stay with
+	// their remaining members: one of them becomes owner if needed, and what
+	// the purged users created there is handed to them, since deleting the
+	// users would cascade to it. Shared households nobody else belongs to
+	// are removed.
+	queries := []string{
+		`DELETE FROM households
+		WHERE
+			is_personal = TRUE
+			AND created_by = ANY($1::INTEGER[])`,
+		`DELETE FROM households H
+		WHERE
+			H.id IN (SELECT household_id FROM household_members WHERE user_id = ANY($1::INTEGER[]))
+			AND NOT EXISTS (
+				SELECT 1 FROM household_members R
+				WHERE R.household_id = H.id AND R.user_id <> ALL($1::INTEGER[])
+			)`,
+		`UPDATE household_members
+		SET
+			role = 'owner',
+			updated_at = NOW()
+		WHERE
+			id IN (
+				SELECT DISTINCT ON (R.household_id) R.id
+				FROM household_members R
+				WHERE
+					R.user_id <> ALL($1::INTEGER[])
+					AND NOT EXISTS (
+						SELECT 1 FROM household_members O
+						WHERE O.household_id = R.household_id AND O.role = 'owner' AND O.user_id <> ALL($1::INTEGER[])
+					)
+					AND R.household_id IN (SELECT household_id FROM household_members WHERE user_id = ANY($1::INTEGER[]))
+				ORDER BY R.household_id, CASE R.role WHEN 'editor' THEN 0 ELSE 1 END, R.created_at, R.id
+			)`,
+	}
+	for _, table := range householdTables {
+		queries = append(queries, `
+		UPDATE `+table+` T
+		SET
+			created_by = (
+				SELECT M.user_id FROM household_members M
+				WHERE M.household_id = T.household_id AND M.user_id <> ALL($1::INTEGER[])
+				ORDER BY CASE M.role WHEN 'owner' THEN 0 WHEN 'editor' THEN 1 ELSE 2 END, M.created_at, M.id
+				LIMIT 1
+			)
+		WHERE
+			T.created_by = ANY($1::INTEGER[])`)
+	}
+
+	for _, query := range queries {
+		ctx, span := spanWithQuery(ctx, p.tracer, query)
+		if _, err := tx.Exec(ctx, query, ids); err != nil {
+			span.SetStatus(codes.Error, "failed to hand over data of purged Users")
+			span.RecordError(err)
+			span.End()
+			return 0, err
+		}
+		span.End()
+	}
+
+	query = `
+		DELETE FROM users
+		WHERE
+			id = ANY($1::INTEGER[])`
+
+	ctx, span = spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := tx.Exec(ctx, query, ids)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to purge Users")
+		span.RecordError(err)
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (p *postgresUserRepository) SetActive(ctx context.Context, id uint, version int, active bool) error {
+	query := `
+		UPDATE users
+		SET
+			is_active = $2,
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND version = $3
+			AND is_deleted = FALSE`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, id, active, version)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to set User active")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrVersionConflict
+	}
+
+	return nil
+}
+
+func (p *postgresUserRepository) RevokeSessions(ctx context.Context, id uint) error {
+	// Truncated to the second so it compares with the iat of refresh tokens
+	// issued right after the revocation.
+	query := `
+		UPDATE users
+		SET
+			sessions_revoked_at = date_trunc('second', NOW()),
+			updated_at = NOW()
+		WHERE
+			id = $1`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, id)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to revoke User sessions")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *postgresUserRepository) SetRole(ctx context.Context, id uint, version int, role string) error {
+	query := `
+		UPDATE users
+		SET
+			role = $2,
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND version = $3
+			AND is_deleted = FALSE`
+
+	ctx, span := spanWithQuery(ctx, p.tracer, query)
+	defer span.End()
+
+	result, err := p.conn.Exec(ctx, query, id, role, version)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to set User role")
+		span.RecordError(err)
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return domain.ErrVersionConflict
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package throttle
+
+import (
+	"context"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Config holds throttling limits. Failures are counted per IP and per subject
+// (an email for sign-in, a share link for passcodes) within Window; once a
+// counter passes its limit every further failure blocks that key for
+// BaseDelay doubled per extra failure, capped at MaxDelay. LockoutThreshold
+// failures for one subject lock it for LockoutDuration regardless of the IP
+// they come from. Namespace keeps the redis keys of limiters apart.
+type Config struct {
+	Namespace        string
+	IPLimit          int
+	SubjectLimit     int
+	Window           time.Duration
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+}
+
+// ConfigFromEnv reads the SIGNIN_* variables, falling back to defaults for
+// anything unset or malformed.
+func ConfigFromEnv() Config {
+	return Config{
+		Namespace:        "signin",
+		IPLimit:          envInt("SIGNIN_IP_LIMIT", 20),
+		SubjectLimit:     envInt("SIGNIN_EMAIL_LIMIT", 5),
+		Window:           envDuration("SIGNIN_WINDOW", 15*time.Minute),
+		BaseDelay:        envDuration("SIGNIN_BASE_DELAY", time.Second),
+		MaxDelay:         envDuration("SIGNIN_MAX_DELAY", 5*time.Minute),
+		LockoutThreshold: envInt("SIGNIN_LOCKOUT_THRESHOLD", 10),
+		LockoutDuration:  envDuration("SIGNIN_LOCKOUT_DURATION", 30*time.Minute),
+	}
+}
+
+// PasscodeConfigFromEnv reads the SHARE_PASSCODE_* variables, which throttle
+// wrong share link passcodes per IP and per link.
+func PasscodeConfigFromEnv() Config {
+	return Config{
+		Namespace:        "passcode",
+		IPLimit:          envInt("SHARE_PASSCODE_IP_LIMIT", 20),
+		SubjectLimit:     envInt("SHARE_PASSCODE_LINK_LIMIT", 5),
+		Window:           envDuration("SHARE_PASSCODE_WINDOW", 15*time.Minute),
+		BaseDelay:        envDuration("SHARE_PASSCODE_BASE_DELAY", time.Second),
+		MaxDelay:         envDuration("SHARE_PASSCODE_MAX_DELAY", 5*time.Minute),
+		LockoutThreshold: envInt("SHARE_PASSCODE_LOCKOUT_THRESHOLD", 10),
+		LockoutDuration:  envDuration("SHARE_PASSCODE_LOCKOUT_DURATION", 30*time.Minute),
+	}
+}
+
+// Limiter tracks failed attempts in redis.
+type Limiter struct {
+	rdb *redis.Client
+	cfg Config
+}
+
+func NewLimiter(rdb *redis.Client, cfg Config) *Limiter {
+	return &Limiter{rdb: rdb, cfg: cfg}
+}
+
+// Check returns how long the caller must wait before trying again, or zero
+// when the attempt may proceed. Locked reports an account lockout rather
+// than a backoff.
+func (l *Limiter) Check(ctx context.Context, ip string, subject string) (wait time.Duration, locked bool, err error) {
+	subject = normalize(subject)
+
+	pipe := l.rdb.Pipeline()
+	ipBlock := pipe.PTTL(ctx, l.blockKey("ip", ip))
+	subjectBlock := pipe.PTTL(ctx, l.blockKey("subject", subject))
+	lock := pipe.PTTL(ctx, l.lockKey(subject))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, false, err
+	}
+
+	if d := lock.Val(); d > 0 {
+		return d, true, nil
+	}
+	return maxDuration(ipBlock.Val(), subjectBlock.Val()), false, nil
+}
+
+// Fail records a failed attempt and returns the resulting wait.
+func (l *Limiter) Fail(ctx context.Context, ip string, subject string) (wait time.Duration, locked bool, err error) {
+	subject = normalize(subject)
+
+	ipCount, err := l.incr(ctx, l.failKey("ip", ip))
+	if err != nil {
+		return 0, false, err
+	}
+	subjectCount, err := l.incr(ctx, l.failKey("subject", subject))
+	if err != nil {
+		return 0, false, err
+	}
+
+	if l.cfg.LockoutThreshold > 0 && subjectCount >= int64(l.cfg.LockoutThreshold) {
+		if err := l.rdb.Set(ctx, l.lockKey(subject), 1, l.cfg.LockoutDuration).Err(); err != nil {
+			return 0, false, err
+		}
+		return l.cfg.LockoutDuration, true, nil
+	}
+
+	ipWait, err := l.block(ctx, l.blockKey("ip", ip), ipCount, l.cfg.IPLimit)
+	if err != nil {
+		return 0, false, err
+	}
+	subjectWait, err := l.block(ctx, l.blockKey("subject", subject), subjectCount, l.cfg.SubjectLimit)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return maxDuration(ipWait, subjectWait), false, nil
+}
+
+// Reset clears the subject's failures after a successful attempt. The IP
+// counter is left alone so one valid subject does not reset an attacker.
+func (l *Limiter) Reset(ctx context.Context, subject string) error {
+	subject = normalize(subject)
+	return l.rdb.Del(ctx, l.failKey("subject", subject), l.blockKey("subject", subject)).Err()
+}
+
+func (l *Limiter) incr(ctx context.Context, key string) (int64, error) {
+	count, err := l.rdb.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		if err := l.rdb.Expire(ctx, key, l.cfg.Window).Err(); err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+func (l *Limiter) block(ctx context.Context, key string, count int64, limit int) (time.Duration, error) {
+	if limit <= 0 || count <= int64(limit) {
+		return 0, nil
+	}
+
+	wait := Backoff(l.cfg.BaseDelay, l.cfg.MaxDelay, int(count)-limit-1)
+	if err := l.rdb.Set(ctx, key, 1, wait).Err(); err != nil {
+		return 0, err
+	}
+	return wait, nil
+}
+
+// Backoff returns base doubled n times, capped at max.
+func Backoff(base time.Duration, max time.Duration, n int) time.Duration {
+	if n < 0 {
+		n = 0
+	}
+	wait := float64(base) * math.Pow(2, float64(n))
+	if wait > float64(max) {
+		return max
+	}
+	return time.Duration(wait)
+}
+
+func (l *Limiter) failKey(kind string, v string) string {
+	return l.cfg.Namespace + ":fail:" + kind + ":" + v
+}
+
+func (l *Limiter) blockKey(kind string, v string) string {
+	return l.cfg.Namespace + ":block:" + kind + ":" + v
+}
+
+func (l *Limiter) lockKey(subject string) string {
+	return l.cfg.Namespace + ":lock:" + subject
+}
+
+func normalize(subject string) string {
+	return strings.ToLower(strings.TrimSpace(subject))
+}
+
+func maxDuration(a time.Duration, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func envInt(key string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return fallback
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return v
+	}
+	return fallback
+}
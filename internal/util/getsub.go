@@ -22,3 +22,11 @@ func GetSub(ctx context.Context) (uint, error) {
 
 	return uint(userId), nil
 }
+
+// GetRole returns the caller's role claim, or an empty string for tokens
+// without one.
+func GetRole(ctx context.Context) string {
+	user, _ := ctx.Value(middlewares.AuthCtx{}).(jwt.MapClaims)
+	role, _ := user["role"].(string)
+	return role
+}
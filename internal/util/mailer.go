@@ -0,0 +1,28 @@
+package util
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/Brix101/budgetto-backend/internal/domain"
+)
+
+type logMailer struct {
+	logger *zap.Logger
+}
+
+// NewLogMailer returns a mailer that writes messages to the log instead of
+// sending them, for development and until a real provider is configured.
+func NewLogMailer(logger *zap.Logger) domain.Mailer {
+	return &logMailer{logger: logger}
+}
+
+func (m *logMailer) Send(_ context.Context, to string, subject string, body string) error {
+	m.logger.Info("📧📧📧 Sending email",
+		zap.String("to", to),
+		zap.String("subject", subject),
+		zap.String("body", body),
+	)
+	return nil
+}
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE sign_in_attempts (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR NOT NULL,
+    ip VARCHAR NOT NULL,
+    user_agent TEXT DEFAULT '',
+    reason VARCHAR NOT NULL,
+    user_id INTEGER REFERENCES users (id) ON DELETE SET NULL DEFAULT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS sign_in_attempt_email_idx ON sign_in_attempts (email, created_at);
+CREATE INDEX IF NOT EXISTS sign_in_attempt_ip_idx ON sign_in_attempts (ip, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE sign_in_attempts
+-- +goose StatementEnd
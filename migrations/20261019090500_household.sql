@@ -0,0 +1,72 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE households (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR NOT NULL,
+    is_personal BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by INTEGER REFERENCES users (id) ON DELETE SET NULL DEFAULT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    is_deleted BOOLEAN DEFAULT FALSE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS household_personal_idx ON households (created_by) WHERE is_personal = TRUE;
+
+CREATE TABLE household_members (
+    id SERIAL PRIMARY KEY,
+    household_id INTEGER NOT NULL REFERENCES households (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role VARCHAR NOT NULL DEFAULT 'viewer' CHECK (role IN ('owner', 'editor', 'viewer')),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (household_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS household_member_user_idx ON household_members (user_id);
+
+-- Every existing user gets a personal household that owns their data.
+INSERT INTO households (name, is_personal, created_by)
+SELECT 'Personal', TRUE, id FROM users;
+
+INSERT INTO household_members (household_id, user_id, role)
+SELECT id, created_by, 'owner' FROM households WHERE is_personal = TRUE;
+
+ALTER TABLE accounts ADD COLUMN household_id INTEGER REFERENCES households (id) ON DELETE CASCADE;
+UPDATE accounts SET household_id = h.id
+FROM households h
+WHERE h.is_personal = TRUE AND h.created_by = accounts.created_by;
+ALTER TABLE accounts ALTER COLUMN household_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS account_household_idx ON accounts (household_id);
+
+-- Global categories keep a NULL household.
+ALTER TABLE categories ADD COLUMN household_id INTEGER REFERENCES households (id) ON DELETE CASCADE DEFAULT NULL;
+UPDATE categories SET household_id = h.id
+FROM households h
+WHERE h.is_personal = TRUE AND h.created_by = categories.created_by;
+CREATE INDEX IF NOT EXISTS category_household_idx ON categories (household_id);
+
+ALTER TABLE budgets ADD COLUMN household_id INTEGER REFERENCES households (id) ON DELETE CASCADE;
+UPDATE budgets SET household_id = h.id
+FROM households h
+WHERE h.is_personal = TRUE AND h.created_by = budgets.created_by;
+ALTER TABLE budgets ALTER COLUMN household_id SET NOT NULL;
+ALTER TABLE budgets DROP CONSTRAINT IF EXISTS budgets_created_by_category_id_key;
+ALTER TABLE budgets ADD CONSTRAINT budgets_household_id_category_id_key UNIQUE (household_id, category_id);
+
+ALTER TABLE transactions ADD COLUMN household_id INTEGER REFERENCES households (id) ON DELETE CASCADE;
+UPDATE transactions SET household_id = h.id
+FROM households h
+WHERE h.is_personal = TRUE AND h.created_by = transactions.created_by;
+ALTER TABLE transactions ALTER COLUMN household_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS transaction_household_idx ON transactions (household_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE transactions DROP COLUMN household_id;
+ALTER TABLE budgets DROP CONSTRAINT budgets_household_id_category_id_key;
+ALTER TABLE budgets ADD CONSTRAINT budgets_created_by_category_id_key UNIQUE (created_by, category_id);
+ALTER TABLE budgets DROP COLUMN household_id;
+ALTER TABLE categories DROP COLUMN household_id;
+ALTER TABLE accounts DROP COLUMN household_id;
+DROP TABLE household_members;
+DROP TABLE households;
+-- +goose StatementEnd
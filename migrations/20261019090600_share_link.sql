@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE share_links (
+    id SERIAL PRIMARY KEY,
+    household_id INTEGER NOT NULL REFERENCES households (id) ON DELETE CASCADE,
+    resource VARCHAR NOT NULL CHECK (resource IN ('budgets', 'category_report', 'account_transactions')),
+    resource_id INTEGER DEFAULT NULL,
+    starts_at TIMESTAMPTZ DEFAULT NULL,
+    ends_at TIMESTAMPTZ DEFAULT NULL,
+    prefix VARCHAR NOT NULL,
+    token_hash VARCHAR NOT NULL UNIQUE,
+    passcode_hash VARCHAR DEFAULT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    last_accessed_at TIMESTAMPTZ DEFAULT NULL,
+    revoked_at TIMESTAMPTZ DEFAULT NULL,
+    created_by INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS share_link_household_idx ON share_links (household_id);
+
+CREATE TABLE share_link_accesses (
+    id SERIAL PRIMARY KEY,
+    share_link_id INTEGER NOT NULL REFERENCES share_links (id) ON DELETE CASCADE,
+    ip VARCHAR NOT NULL DEFAULT '',
+    user_agent VARCHAR NOT NULL DEFAULT '',
+    granted BOOLEAN NOT NULL,
+    reason VARCHAR NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS share_link_access_link_idx ON share_link_accesses (share_link_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE share_link_accesses;
+DROP TABLE share_links;
+-- +goose StatementEnd
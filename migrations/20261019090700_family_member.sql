@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE family_members (
+    id SERIAL PRIMARY KEY,
+    household_id INTEGER NOT NULL REFERENCES households (id) ON DELETE CASCADE,
+    name VARCHAR NOT NULL,
+    relationship VARCHAR DEFAULT '',
+    created_by INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    is_deleted BOOLEAN DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS family_member_household_idx ON family_members (household_id);
+
+ALTER TABLE accounts ADD COLUMN family_member_id INTEGER REFERENCES family_members (id) ON DELETE SET NULL DEFAULT NULL;
+ALTER TABLE transactions ADD COLUMN family_member_id INTEGER REFERENCES family_members (id) ON DELETE SET NULL DEFAULT NULL;
+CREATE INDEX IF NOT EXISTS transaction_family_member_idx ON transactions (family_member_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE transactions DROP COLUMN family_member_id;
+ALTER TABLE accounts DROP COLUMN family_member_id;
+DROP TABLE family_members;
+-- +goose StatementEnd
@@ -0,0 +1,56 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE contacts (
+    id SERIAL PRIMARY KEY,
+    household_id INTEGER NOT NULL REFERENCES households (id) ON DELETE CASCADE,
+    name VARCHAR NOT NULL,
+    email VARCHAR DEFAULT '',
+    created_by INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    is_deleted BOOLEAN DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS contact_household_idx ON contacts (household_id);
+
+CREATE TABLE transaction_splits (
+    id SERIAL PRIMARY KEY,
+    transaction_id INTEGER NOT NULL UNIQUE REFERENCES transactions (id) ON DELETE CASCADE,
+    household_id INTEGER NOT NULL REFERENCES households (id) ON DELETE CASCADE,
+    method VARCHAR NOT NULL CHECK (method IN ('amount', 'percentage', 'equal')),
+    paid_by INTEGER REFERENCES contacts (id) ON DELETE CASCADE DEFAULT NULL,
+    created_by INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS transaction_split_household_idx ON transaction_splits (household_id);
+
+CREATE TABLE split_shares (
+    id SERIAL PRIMARY KEY,
+    split_id INTEGER NOT NULL REFERENCES transaction_splits (id) ON DELETE CASCADE,
+    contact_id INTEGER REFERENCES contacts (id) ON DELETE CASCADE DEFAULT NULL,
+    value DOUBLE PRECISION NOT NULL DEFAULT 0,
+    amount DOUBLE PRECISION NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS split_share_split_idx ON split_shares (split_id);
+
+CREATE TABLE settlements (
+    id SERIAL PRIMARY KEY,
+    household_id INTEGER NOT NULL REFERENCES households (id) ON DELETE CASCADE,
+    from_contact_id INTEGER REFERENCES contacts (id) ON DELETE CASCADE DEFAULT NULL,
+    to_contact_id INTEGER REFERENCES contacts (id) ON DELETE CASCADE DEFAULT NULL,
+    amount DOUBLE PRECISION NOT NULL,
+    note TEXT DEFAULT '',
+    transaction_id INTEGER REFERENCES transactions (id) ON DELETE SET NULL DEFAULT NULL,
+    created_by INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS settlement_household_idx ON settlements (household_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE settlements;
+DROP TABLE split_shares;
+DROP TABLE transaction_splits;
+DROP TABLE contacts;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE transaction_lines (
+    id SERIAL PRIMARY KEY,
+    transaction_id INTEGER NOT NULL REFERENCES transactions (id) ON DELETE CASCADE,
+    category_id INTEGER NOT NULL REFERENCES categories (id) ON DELETE CASCADE,
+    amount DOUBLE PRECISION NOT NULL DEFAULT 0,
+    note TEXT DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS transaction_line_transaction_idx ON transaction_lines (transaction_id);
+
+-- transaction_allocations spreads every transaction over its categories: its
+-- lines when it has any, its own category otherwise.
+CREATE VIEW transaction_allocations AS
+SELECT
+    T.id AS transaction_id,
+    T.household_id,
+    COALESCE(L.category_id, T.category_id) AS category_id,
+    COALESCE(L.amount, T.amount) AS amount,
+    T.operation,
+    T.family_member_id,
+    T.created_at,
+    T.is_deleted
+FROM
+    transactions T
+    LEFT JOIN transaction_lines L ON L.transaction_id = T.id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP VIEW transaction_allocations;
+DROP TABLE transaction_lines;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE payees (
+    id SERIAL PRIMARY KEY,
+    household_id INTEGER NOT NULL REFERENCES households (id) ON DELETE CASCADE,
+    name VARCHAR NOT NULL,
+    normalized_name VARCHAR NOT NULL,
+    created_by INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    CONSTRAINT payee_name UNIQUE (household_id, normalized_name)
+);
+
+CREATE TABLE payee_aliases (
+    id SERIAL PRIMARY KEY,
+    payee_id INTEGER NOT NULL REFERENCES payees (id) ON DELETE CASCADE,
+    household_id INTEGER NOT NULL REFERENCES households (id) ON DELETE CASCADE,
+    alias VARCHAR NOT NULL,
+    CONSTRAINT payee_alias UNIQUE (household_id, alias)
+);
+CREATE INDEX IF NOT EXISTS payee_alias_payee_idx ON payee_aliases (payee_id);
+
+ALTER TABLE transactions ADD COLUMN payee_id INTEGER REFERENCES payees (id) ON DELETE SET NULL DEFAULT NULL;
+CREATE INDEX IF NOT EXISTS transaction_payee_idx ON transactions (payee_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE transactions DROP COLUMN payee_id;
+DROP TABLE payee_aliases;
+DROP TABLE payees;
+-- +goose StatementEnd
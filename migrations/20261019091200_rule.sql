@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE rules (
+    id SERIAL PRIMARY KEY,
+    household_id INTEGER NOT NULL REFERENCES households (id) ON DELETE CASCADE,
+    name VARCHAR NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    note_contains VARCHAR NOT NULL DEFAULT '',
+    payee_contains VARCHAR NOT NULL DEFAULT '',
+    amount_min DOUBLE PRECISION DEFAULT NULL,
+    amount_max DOUBLE PRECISION DEFAULT NULL,
+    account_id INTEGER REFERENCES accounts (id) ON DELETE CASCADE DEFAULT NULL,
+    operation operation DEFAULT NULL,
+    set_category_id INTEGER REFERENCES categories (id) ON DELETE CASCADE DEFAULT NULL,
+    set_tags VARCHAR[] NOT NULL DEFAULT '{}',
+    set_payee_id INTEGER REFERENCES payees (id) ON DELETE CASCADE DEFAULT NULL,
+    set_note TEXT DEFAULT NULL,
+    created_by INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS rule_household_idx ON rules (household_id, priority);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE rules;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE categories ADD COLUMN parent_id INTEGER REFERENCES categories (id) ON DELETE SET NULL DEFAULT NULL;
+CREATE INDEX IF NOT EXISTS category_parent_idx ON categories (parent_id);
+
+-- category_closure pairs every category with itself and all of its
+-- descendants, so spending can be rolled up into parents.
+CREATE VIEW category_closure AS
+WITH RECURSIVE tree (ancestor_id, descendant_id) AS (
+    SELECT id, id FROM categories
+    UNION
+    SELECT T.ancestor_id, C.id
+    FROM tree T JOIN categories C ON C.parent_id = T.descendant_id
+)
+SELECT ancestor_id, descendant_id FROM tree;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP VIEW category_closure;
+ALTER TABLE categories DROP COLUMN parent_id;
+-- +goose StatementEnd
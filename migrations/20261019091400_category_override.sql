@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE category_overrides (
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    category_id INTEGER NOT NULL REFERENCES categories (id) ON DELETE CASCADE,
+    hidden BOOLEAN NOT NULL DEFAULT FALSE,
+    name VARCHAR DEFAULT NULL,
+    icon VARCHAR DEFAULT NULL,
+    color VARCHAR DEFAULT NULL,
+    sort_order INTEGER DEFAULT NULL,
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (user_id, category_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE category_overrides;
+-- +goose StatementEnd
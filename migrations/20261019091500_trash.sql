@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE accounts ADD COLUMN deleted_at TIMESTAMPTZ DEFAULT NULL;
+ALTER TABLE categories ADD COLUMN deleted_at TIMESTAMPTZ DEFAULT NULL;
+ALTER TABLE budgets ADD COLUMN deleted_at TIMESTAMPTZ DEFAULT NULL;
+ALTER TABLE transactions ADD COLUMN deleted_at TIMESTAMPTZ DEFAULT NULL;
+
+UPDATE accounts SET deleted_at = updated_at WHERE is_deleted = TRUE;
+UPDATE categories SET deleted_at = updated_at WHERE is_deleted = TRUE;
+UPDATE budgets SET deleted_at = updated_at WHERE is_deleted = TRUE;
+UPDATE transactions SET deleted_at = updated_at WHERE is_deleted = TRUE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE transactions DROP COLUMN deleted_at;
+ALTER TABLE budgets DROP COLUMN deleted_at;
+ALTER TABLE categories DROP COLUMN deleted_at;
+ALTER TABLE accounts DROP COLUMN deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,169 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    household_id INTEGER DEFAULT NULL,
+    actor_id INTEGER DEFAULT NULL,
+    entity_type VARCHAR NOT NULL,
+    entity_id INTEGER NOT NULL,
+    action VARCHAR NOT NULL,
+    before JSONB DEFAULT NULL,
+    after JSONB DEFAULT NULL,
+    request_id VARCHAR NOT NULL DEFAULT '',
+    ip VARCHAR NOT NULL DEFAULT '',
+    prev_hash VARCHAR NOT NULL DEFAULT '',
+    hash VARCHAR NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS audit_event_household_idx ON audit_events (household_id, created_at);
+CREATE INDEX IF NOT EXISTS audit_event_entity_idx ON audit_events (entity_type, entity_id);
+
+-- audit_hash is what chains an event to the one before it.
+CREATE FUNCTION audit_hash(e audit_events) RETURNS VARCHAR AS $$
+    SELECT encode(sha256(convert_to(concat_ws('|',
+        e.prev_hash,
+        e.id,
+        e.household_id,
+        e.actor_id,
+        e.entity_type,
+        e.entity_id,
+        e.action,
+        e.before::TEXT,
+        e.after::TEXT,
+        e.request_id,
+        e.ip,
+        (EXTRACT(EPOCH FROM e.created_at) * 1000000)::BIGINT
+    ), 'UTF8')), 'hex')
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- audit_row records every change to the table it is attached to. The actor,
+-- request and whether to hash chain come from settings the application sets
+-- for the transaction.
+CREATE FUNCTION audit_row() RETURNS TRIGGER AS $$
+DECLARE
+    old_row JSONB := CASE WHEN TG_OP <> 'INSERT' THEN to_jsonb(OLD) END;
+    new_row JSONB := CASE WHEN TG_OP <> 'DELETE' THEN to_jsonb(NEW) END;
+    cur_row JSONB := COALESCE(new_row, old_row);
+    e audit_events;
+BEGIN
+    e.entity_type := TG_TABLE_NAME;
+    e.entity_id := COALESCE(cur_row ->> 'id', cur_row ->> 'transaction_id')::INTEGER;
+
+    IF TG_OP = 'INSERT' THEN
+        e.action := 'create';
+        e.after := new_row;
+    ELSIF TG_OP = 'DELETE' THEN
+        e.action := CASE WHEN (old_row ->> 'is_deleted')::BOOLEAN THEN 'purge' ELSE 'delete' END;
+        e.before := old_row;
+    ELSE
+        SELECT jsonb_object_agg(key, value) INTO e.before
+        FROM jsonb_each(old_row) WHERE new_row -> key IS DISTINCT FROM value;
+        SELECT jsonb_object_agg(key, value) INTO e.after
+        FROM jsonb_each(new_row) WHERE old_row -> key IS DISTINCT FROM value;
+
+        IF e.after IS NULL OR e.after - 'updated_at' = '{}'::JSONB THEN
+            RETURN NULL;
+        END IF;
+
+        e.action := CASE
+            WHEN (e.after ->> 'is_deleted')::BOOLEAN THEN 'delete'
+            WHEN (e.before ->> 'is_deleted')::BOOLEAN THEN 'restore'
+            ELSE 'update'
+        END;
+    END IF;
+
+    e.household_id := CASE TG_TABLE_NAME
+        WHEN 'households' THEN (cur_row ->> 'id')::INTEGER
+        WHEN 'transaction_lines' THEN (SELECT household_id FROM transactions WHERE id = (cur_row ->> 'transaction_id')::INTEGER)
+        WHEN 'transaction_tags' THEN (SELECT household_id FROM transactions WHERE id = (cur_row ->> 'transaction_id')::INTEGER)
+        WHEN 'split_shares' THEN (SELECT household_id FROM transaction_splits WHERE id = (cur_row ->> 'split_id')::INTEGER)
+        ELSE (cur_row ->> 'household_id')::INTEGER
+    END;
+    e.actor_id := NULLIF(current_setting('budgetto.actor_id', TRUE), '')::INTEGER;
+    e.request_id := COALESCE(current_setting('budgetto.request_id', TRUE), '');
+    e.ip := COALESCE(current_setting('budgetto.ip', TRUE), '');
+    e.id := nextval(pg_get_serial_sequence('audit_events', 'id'));
+    e.created_at := NOW();
+    e.prev_hash := '';
+    e.hash := '';
+
+    IF current_setting('budgetto.audit_chain', TRUE) = 'on' THEN
+        -- Chained events are written one at a time so each sees the last.
+        PERFORM pg_advisory_xact_lock(hashtext('audit_events'));
+        SELECT hash INTO e.prev_hash FROM audit_events WHERE hash <> '' ORDER BY id DESC LIMIT 1;
+        e.prev_hash := COALESCE(e.prev_hash, '');
+        e.hash := audit_hash(e);
+    END IF;
+
+    INSERT INTO audit_events SELECT (e).*;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- The audit log is append-only.
+CREATE FUNCTION audit_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_append_only();
+
+CREATE TRIGGER audit_accounts AFTER INSERT OR UPDATE OR DELETE ON accounts
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+CREATE TRIGGER audit_categories AFTER INSERT OR UPDATE OR DELETE ON categories
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+CREATE TRIGGER audit_budgets AFTER INSERT OR UPDATE OR DELETE ON budgets
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+CREATE TRIGGER audit_transactions AFTER INSERT OR UPDATE OR DELETE ON transactions
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+CREATE TRIGGER audit_transaction_lines AFTER INSERT OR UPDATE OR DELETE ON transaction_lines
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+CREATE TRIGGER audit_transaction_tags AFTER INSERT OR UPDATE OR DELETE ON transaction_tags
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+CREATE TRIGGER audit_transaction_splits AFTER INSERT OR UPDATE OR DELETE ON transaction_splits
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+CREATE TRIGGER audit_split_shares AFTER INSERT OR UPDATE OR DELETE ON split_shares
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+CREATE TRIGGER audit_settlements AFTER INSERT OR UPDATE OR DELETE ON settlements
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+CREATE TRIGGER audit_contacts AFTER INSERT OR UPDATE OR DELETE ON contacts
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+CREATE TRIGGER audit_family_members AFTER INSERT OR UPDATE OR DELETE ON family_members
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+CREATE TRIGGER audit_tags AFTER INSERT OR UPDATE OR DELETE ON tags
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+CREATE TRIGGER audit_payees AFTER INSERT OR UPDATE OR DELETE ON payees
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+CREATE TRIGGER audit_rules AFTER INSERT OR UPDATE OR DELETE ON rules
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+CREATE TRIGGER audit_households AFTER INSERT OR UPDATE OR DELETE ON households
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+CREATE TRIGGER audit_household_members AFTER INSERT OR UPDATE OR DELETE ON household_members
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER audit_household_members ON household_members;
+DROP TRIGGER audit_households ON households;
+DROP TRIGGER audit_rules ON rules;
+DROP TRIGGER audit_payees ON payees;
+DROP TRIGGER audit_tags ON tags;
+DROP TRIGGER audit_family_members ON family_members;
+DROP TRIGGER audit_contacts ON contacts;
+DROP TRIGGER audit_settlements ON settlements;
+DROP TRIGGER audit_split_shares ON split_shares;
+DROP TRIGGER audit_transaction_splits ON transaction_splits;
+DROP TRIGGER audit_transaction_tags ON transaction_tags;
+DROP TRIGGER audit_transaction_lines ON transaction_lines;
+DROP TRIGGER audit_transactions ON transactions;
+DROP TRIGGER audit_budgets ON budgets;
+DROP TRIGGER audit_categories ON categories;
+DROP TRIGGER audit_accounts ON accounts;
+DROP FUNCTION audit_row;
+DROP FUNCTION audit_hash;
+DROP TABLE audit_events;
+DROP FUNCTION audit_append_only;
+-- +goose StatementEnd
@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE transaction_versions (
+    id SERIAL PRIMARY KEY,
+    transaction_id INTEGER NOT NULL REFERENCES transactions (id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    amount DOUBLE PRECISION DEFAULT 0,
+    note TEXT DEFAULT '',
+    operation operation NOT NULL,
+    account_id INTEGER NOT NULL,
+    category_id INTEGER NOT NULL,
+    family_member_id INTEGER DEFAULT NULL,
+    payee_id INTEGER DEFAULT NULL,
+    lines JSONB NOT NULL DEFAULT '[]',
+    tags VARCHAR[] NOT NULL DEFAULT '{}',
+    edited_by INTEGER REFERENCES users (id) ON DELETE SET NULL DEFAULT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CONSTRAINT transaction_version UNIQUE (transaction_id, version)
+);
+
+-- Existing transactions start their history with how they look now.
+INSERT INTO transaction_versions
+    (transaction_id, version, amount, note, operation, account_id, category_id, family_member_id, payee_id, lines, tags, edited_by, created_at)
+SELECT
+    T.id,
+    1,
+    T.amount,
+    T.note,
+    T.operation,
+    T.account_id,
+    T.category_id,
+    T.family_member_id,
+    T.payee_id,
+    COALESCE((
+        SELECT jsonb_agg(jsonb_build_object('category_id', L.category_id, 'amount', L.amount, 'note', L.note) ORDER BY L.id)
+        FROM transaction_lines L WHERE L.transaction_id = T.id
+    ), '[]'),
+    COALESCE((
+        SELECT array_agg(G.name ORDER BY G.name)
+        FROM transaction_tags TT JOIN tags G ON G.id = TT.tag_id WHERE TT.transaction_id = T.id
+    ), '{}'),
+    T.created_by,
+    T.updated_at
+FROM
+    transactions T;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE transaction_versions;
+-- +goose StatementEnd
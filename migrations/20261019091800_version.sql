@@ -0,0 +1,57 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE accounts ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE budgets ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE categories ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE contacts ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE family_members ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE households ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE payees ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE rules ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE tags ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE transactions ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION bump_version() RETURNS TRIGGER AS $$
+BEGIN
+	NEW.version := OLD.version + 1;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER accounts_version BEFORE UPDATE ON accounts FOR EACH ROW EXECUTE FUNCTION bump_version();
+CREATE TRIGGER budgets_version BEFORE UPDATE ON budgets FOR EACH ROW EXECUTE FUNCTION bump_version();
+CREATE TRIGGER categories_version BEFORE UPDATE ON categories FOR EACH ROW EXECUTE FUNCTION bump_version();
+CREATE TRIGGER contacts_version BEFORE UPDATE ON contacts FOR EACH ROW EXECUTE FUNCTION bump_version();
+CREATE TRIGGER family_members_version BEFORE UPDATE ON family_members FOR EACH ROW EXECUTE FUNCTION bump_version();
+CREATE TRIGGER households_version BEFORE UPDATE ON households FOR EACH ROW EXECUTE FUNCTION bump_version();
+CREATE TRIGGER payees_version BEFORE UPDATE ON payees FOR EACH ROW EXECUTE FUNCTION bump_version();
+CREATE TRIGGER rules_version BEFORE UPDATE ON rules FOR EACH ROW EXECUTE FUNCTION bump_version();
+CREATE TRIGGER tags_version BEFORE UPDATE ON tags FOR EACH ROW EXECUTE FUNCTION bump_version();
+CREATE TRIGGER transactions_version BEFORE UPDATE ON transactions FOR EACH ROW EXECUTE FUNCTION bump_version();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS transactions_version ON transactions;
+DROP TRIGGER IF EXISTS tags_version ON tags;
+DROP TRIGGER IF EXISTS rules_version ON rules;
+DROP TRIGGER IF EXISTS payees_version ON payees;
+DROP TRIGGER IF EXISTS households_version ON households;
+DROP TRIGGER IF EXISTS family_members_version ON family_members;
+DROP TRIGGER IF EXISTS contacts_version ON contacts;
+DROP TRIGGER IF EXISTS categories_version ON categories;
+DROP TRIGGER IF EXISTS budgets_version ON budgets;
+DROP TRIGGER IF EXISTS accounts_version ON accounts;
+DROP FUNCTION IF EXISTS bump_version();
+
+ALTER TABLE transactions DROP COLUMN version;
+ALTER TABLE tags DROP COLUMN version;
+ALTER TABLE rules DROP COLUMN version;
+ALTER TABLE payees DROP COLUMN version;
+ALTER TABLE households DROP COLUMN version;
+ALTER TABLE family_members DROP COLUMN version;
+ALTER TABLE contacts DROP COLUMN version;
+ALTER TABLE categories DROP COLUMN version;
+ALTER TABLE budgets DROP COLUMN version;
+ALTER TABLE accounts DROP COLUMN version;
+-- +goose StatementEnd
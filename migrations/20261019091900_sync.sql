@@ -0,0 +1,96 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE sync_change_seq AS BIGINT;
+
+-- A volatile default numbers the existing rows without firing any trigger.
+ALTER TABLE accounts ADD COLUMN change_seq BIGINT NOT NULL DEFAULT nextval('sync_change_seq');
+ALTER TABLE categories ADD COLUMN change_seq BIGINT NOT NULL DEFAULT nextval('sync_change_seq');
+ALTER TABLE budgets ADD COLUMN change_seq BIGINT NOT NULL DEFAULT nextval('sync_change_seq');
+ALTER TABLE transactions ADD COLUMN change_seq BIGINT NOT NULL DEFAULT nextval('sync_change_seq');
+
+CREATE INDEX IF NOT EXISTS account_change_seq_idx ON accounts (household_id, change_seq);
+CREATE INDEX IF NOT EXISTS category_change_seq_idx ON categories (household_id, change_seq);
+CREATE INDEX IF NOT EXISTS budget_change_seq_idx ON budgets (household_id, change_seq);
+CREATE INDEX IF NOT EXISTS transaction_change_seq_idx ON transactions (household_id, change_seq);
+
+-- Rows removed for good, so clients holding them learn they are gone.
+CREATE TABLE sync_tombstones (
+    id BIGSERIAL PRIMARY KEY,
+    household_id INTEGER DEFAULT NULL,
+    entity_type VARCHAR NOT NULL,
+    entity_id INTEGER NOT NULL,
+    change_seq BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS sync_tombstone_household_idx ON sync_tombstones (household_id, change_seq);
+
+-- The ids clients generate for items they create offline. entity_id stays
+-- NULL while the item is being created.
+CREATE TABLE sync_client_ids (
+    client_id UUID PRIMARY KEY,
+    household_id INTEGER NOT NULL REFERENCES households (id) ON DELETE CASCADE,
+    entity_type VARCHAR NOT NULL,
+    entity_id INTEGER DEFAULT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS sync_client_id_entity_idx ON sync_client_ids (entity_type, entity_id);
+
+CREATE OR REPLACE FUNCTION sync_change() RETURNS TRIGGER AS $$
+BEGIN
+    -- Numbers are handed out under a lock held until commit, so changes
+    -- become visible in sequence order and a cursor never skips one that
+    -- commits late. It is the audit chain's lock so the two never wait on
+    -- each other.
+    PERFORM pg_advisory_xact_lock(hashtext('audit_events'));
+
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO sync_tombstones (household_id, entity_type, entity_id, change_seq)
+        VALUES (OLD.household_id, TG_ARGV[0], OLD.id, nextval('sync_change_seq'));
+        RETURN NULL;
+    END IF;
+
+    NEW.change_seq := nextval('sync_change_seq');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER sync_accounts BEFORE INSERT OR UPDATE ON accounts
+    FOR EACH ROW EXECUTE FUNCTION sync_change('account');
+CREATE TRIGGER sync_accounts_delete AFTER DELETE ON accounts
+    FOR EACH ROW EXECUTE FUNCTION sync_change('account');
+CREATE TRIGGER sync_categories BEFORE INSERT OR UPDATE ON categories
+    FOR EACH ROW EXECUTE FUNCTION sync_change('category');
+CREATE TRIGGER sync_categories_delete AFTER DELETE ON categories
+    FOR EACH ROW EXECUTE FUNCTION sync_change('category');
+CREATE TRIGGER sync_budgets BEFORE INSERT OR UPDATE ON budgets
+    FOR EACH ROW EXECUTE FUNCTION sync_change('budget');
+CREATE TRIGGER sync_budgets_delete AFTER DELETE ON budgets
+    FOR EACH ROW EXECUTE FUNCTION sync_change('budget');
+CREATE TRIGGER sync_transactions BEFORE INSERT OR UPDATE ON transactions
+    FOR EACH ROW EXECUTE FUNCTION sync_change('transaction');
+CREATE TRIGGER sync_transactions_delete AFTER DELETE ON transactions
+    FOR EACH ROW EXECUTE FUNCTION sync_change('transaction');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER sync_transactions_delete ON transactions;
+DROP TRIGGER sync_transactions ON transactions;
+DROP TRIGGER sync_budgets_delete ON budgets;
+DROP TRIGGER sync_budgets ON budgets;
+DROP TRIGGER sync_categories_delete ON categories;
+DROP TRIGGER sync_categories ON categories;
+DROP TRIGGER sync_accounts_delete ON accounts;
+DROP TRIGGER sync_accounts ON accounts;
+DROP FUNCTION sync_change;
+
+DROP TABLE sync_client_ids;
+DROP TABLE sync_tombstones;
+
+ALTER TABLE transactions DROP COLUMN change_seq;
+ALTER TABLE budgets DROP COLUMN change_seq;
+ALTER TABLE categories DROP COLUMN change_seq;
+ALTER TABLE accounts DROP COLUMN change_seq;
+
+DROP SEQUENCE sync_change_seq;
+-- +goose StatementEnd
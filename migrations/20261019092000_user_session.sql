@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Refresh tokens issued before this moment are no longer accepted.
+ALTER TABLE users ADD COLUMN sessions_revoked_at TIMESTAMPTZ DEFAULT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN sessions_revoked_at;
+-- +goose StatementEnd
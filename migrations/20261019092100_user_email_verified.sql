@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Sign-up never checked ownership of the address, so existing emails start
+-- out unverified.
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ DEFAULT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN email_verified_at;
+-- +goose StatementEnd
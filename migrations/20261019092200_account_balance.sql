@@ -0,0 +1,78 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Keeps accounts.balance in step with the transactions booked against it.
+-- Every insert, update and delete takes the old row's effect off and puts the
+-- new row's on, so soft deletes, trash restores, reverts, bulk edits and
+-- moves between accounts all settle the balance the same way.
+--
+-- Transfers leave balances alone: a transaction names a single account, so
+-- there is no other side to move the money to.
+--
+-- The balance is part of the account a client reads and writes back, so a
+-- change to it bumps the account's version like any other edit. An account
+-- PUT carrying an ETag from before a transaction was booked gets a 412 with
+-- the new balance instead of putting the old one back.
+CREATE OR REPLACE FUNCTION transaction_balance_effect(op operation, amt DOUBLE PRECISION, deleted BOOLEAN) RETURNS DOUBLE PRECISION AS $$
+BEGIN
+	IF deleted OR op = 'Transfer' THEN
+		RETURN 0;
+	END IF;
+	IF op IN ('Income', 'Refund') THEN
+		RETURN amt;
+	END IF;
+	RETURN -amt;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION transaction_balance() RETURNS TRIGGER AS $$
+BEGIN
+	IF TG_OP IN ('UPDATE', 'DELETE') THEN
+		UPDATE accounts
+		SET balance = balance - transaction_balance_effect(OLD.operation, OLD.amount, OLD.is_deleted)
+		WHERE id = OLD.account_id
+			AND transaction_balance_effect(OLD.operation, OLD.amount, OLD.is_deleted) <> 0;
+	END IF;
+	IF TG_OP IN ('INSERT', 'UPDATE') THEN
+		UPDATE accounts
+		SET balance = balance + transaction_balance_effect(NEW.operation, NEW.amount, NEW.is_deleted)
+		WHERE id = NEW.account_id
+			AND transaction_balance_effect(NEW.operation, NEW.amount, NEW.is_deleted) <> 0;
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER transactions_balance
+	AFTER INSERT OR DELETE OR UPDATE OF amount, operation, account_id, is_deleted ON transactions
+	FOR EACH ROW EXECUTE FUNCTION transaction_balance();
+
+-- Balances so far were only ever typed in, so the transactions already
+-- saved are booked on top of them.
+UPDATE accounts A
+SET balance = COALESCE(A.balance, 0) + E.total
+FROM (
+	SELECT account_id, SUM(transaction_balance_effect(operation, amount, is_deleted)) AS total
+	FROM transactions
+	GROUP BY account_id
+) E
+WHERE E.account_id = A.id
+	AND E.total <> 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS transactions_balance ON transactions;
+
+UPDATE accounts A
+SET balance = COALESCE(A.balance, 0) - E.total
+FROM (
+	SELECT account_id, SUM(transaction_balance_effect(operation, amount, is_deleted)) AS total
+	FROM transactions
+	GROUP BY account_id
+) E
+WHERE E.account_id = A.id
+	AND E.total <> 0;
+
+DROP FUNCTION IF EXISTS transaction_balance();
+DROP FUNCTION IF EXISTS transaction_balance_effect(operation, DOUBLE PRECISION, BOOLEAN);
+-- +goose StatementEnd
@@ -0,0 +1,163 @@
+-- +goose Up
+-- +goose StatementBegin
+-- audit_redact swaps secrets for a digest of them, so the log shows that a
+-- password or token changed without holding anything that could be used.
+CREATE FUNCTION audit_redact(r JSONB) RETURNS JSONB AS $$
+    SELECT jsonb_object_agg(key, CASE
+        WHEN key IN ('password', 'token_hash', 'passcode_hash') AND jsonb_typeof(value) = 'string'
+            THEN to_jsonb(encode(sha256(convert_to(value #>> '{}', 'UTF8')), 'hex'))
+        ELSE value
+    END)
+    FROM jsonb_each(r)
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- The chain lock is now taken before the event id is drawn, so chained ids
+-- increase in the order events are chained and Verify, which walks them by
+-- id, sees the same order the hashes were built in.
+CREATE OR REPLACE FUNCTION audit_row() RETURNS TRIGGER AS $$
+DECLARE
+    old_row JSONB := CASE WHEN TG_OP <> 'INSERT' THEN audit_redact(to_jsonb(OLD)) END;
+    new_row JSONB := CASE WHEN TG_OP <> 'DELETE' THEN audit_redact(to_jsonb(NEW)) END;
+    cur_row JSONB := COALESCE(new_row, old_row);
+    chained BOOLEAN := current_setting('budgetto.audit_chain', TRUE) = 'on';
+    e audit_events;
+BEGIN
+    e.entity_type := TG_TABLE_NAME;
+    e.entity_id := COALESCE(cur_row ->> 'id', cur_row ->> 'transaction_id', cur_row ->> 'category_id')::INTEGER;
+
+    IF TG_OP = 'INSERT' THEN
+        e.action := 'create';
+        e.after := new_row;
+    ELSIF TG_OP = 'DELETE' THEN
+        e.action := CASE WHEN (old_row ->> 'is_deleted')::BOOLEAN THEN 'purge' ELSE 'delete' END;
+        e.before := old_row;
+    ELSE
+        SELECT jsonb_object_agg(key, value) INTO e.before
+        FROM jsonb_each(old_row) WHERE new_row -> key IS DISTINCT FROM value;
+        SELECT jsonb_object_agg(key, value) INTO e.after
+        FROM jsonb_each(new_row) WHERE old_row -> key IS DISTINCT FROM value;
+
+        -- Usage tracking on tokens and share links is not a change.
+        IF e.after IS NULL OR e.after - 'updated_at' - 'last_used_at' - 'last_accessed_at' = '{}'::JSONB THEN
+            RETURN NULL;
+        END IF;
+
+        e.action := CASE
+            WHEN (e.after ->> 'is_deleted')::BOOLEAN THEN 'delete'
+            WHEN (e.before ->> 'is_deleted')::BOOLEAN THEN 'restore'
+            ELSE 'update'
+        END;
+    END IF;
+
+    e.household_id := CASE TG_TABLE_NAME
+        WHEN 'households' THEN (cur_row ->> 'id')::INTEGER
+        WHEN 'transaction_lines' THEN (SELECT household_id FROM transactions WHERE id = (cur_row ->> 'transaction_id')::INTEGER)
+        WHEN 'transaction_tags' THEN (SELECT household_id FROM transactions WHERE id = (cur_row ->> 'transaction_id')::INTEGER)
+        WHEN 'split_shares' THEN (SELECT household_id FROM transaction_splits WHERE id = (cur_row ->> 'split_id')::INTEGER)
+        ELSE (cur_row ->> 'household_id')::INTEGER
+    END;
+    e.actor_id := NULLIF(current_setting('budgetto.actor_id', TRUE), '')::INTEGER;
+    e.request_id := COALESCE(current_setting('budgetto.request_id', TRUE), '');
+    e.ip := COALESCE(current_setting('budgetto.ip', TRUE), '');
+
+    IF chained THEN
+        -- Chained events are written one at a time so each sees the last.
+        PERFORM pg_advisory_xact_lock(hashtext('audit_events'));
+    END IF;
+
+    e.id := nextval(pg_get_serial_sequence('audit_events', 'id'));
+    e.created_at := NOW();
+    e.prev_hash := '';
+    e.hash := '';
+
+    IF chained THEN
+        SELECT hash INTO e.prev_hash FROM audit_events WHERE hash <> '' ORDER BY id DESC LIMIT 1;
+        e.prev_hash := COALESCE(e.prev_hash, '');
+        e.hash := audit_hash(e);
+    END IF;
+
+    INSERT INTO audit_events SELECT (e).*;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_users AFTER INSERT OR UPDATE OR DELETE ON users
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+CREATE TRIGGER audit_personal_access_tokens AFTER INSERT OR UPDATE OR DELETE ON personal_access_tokens
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+CREATE TRIGGER audit_share_links AFTER INSERT OR UPDATE OR DELETE ON share_links
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+CREATE TRIGGER audit_category_overrides AFTER INSERT OR UPDATE OR DELETE ON category_overrides
+    FOR EACH ROW EXECUTE FUNCTION audit_row();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER audit_category_overrides ON category_overrides;
+DROP TRIGGER audit_share_links ON share_links;
+DROP TRIGGER audit_personal_access_tokens ON personal_access_tokens;
+DROP TRIGGER audit_users ON users;
+
+CREATE OR REPLACE FUNCTION audit_row() RETURNS TRIGGER AS $$
+DECLARE
+    old_row JSONB := CASE WHEN TG_OP <> 'INSERT' THEN to_jsonb(OLD) END;
+    new_row JSONB := CASE WHEN TG_OP <> 'DELETE' THEN to_jsonb(NEW) END;
+    cur_row JSONB := COALESCE(new_row, old_row);
+    e audit_events;
+BEGIN
+    e.entity_type := TG_TABLE_NAME;
+    e.entity_id := COALESCE(cur_row ->> 'id', cur_row ->> 'transaction_id')::INTEGER;
+
+    IF TG_OP = 'INSERT' THEN
+        e.action := 'create';
+        e.after := new_row;
+    ELSIF TG_OP = 'DELETE' THEN
+        e.action := CASE WHEN (old_row ->> 'is_deleted')::BOOLEAN THEN 'purge' ELSE 'delete' END;
+        e.before := old_row;
+    ELSE
+        SELECT jsonb_object_agg(key, value) INTO e.before
+        FROM jsonb_each(old_row) WHERE new_row -> key IS DISTINCT FROM value;
+        SELECT jsonb_object_agg(key, value) INTO e.after
+        FROM jsonb_each(new_row) WHERE old_row -> key IS DISTINCT FROM value;
+
+        IF e.after IS NULL OR e.after - 'updated_at' = '{}'::JSONB THEN
+            RETURN NULL;
+        END IF;
+
+        e.action := CASE
+            WHEN (e.after ->> 'is_deleted')::BOOLEAN THEN 'delete'
+            WHEN (e.before ->> 'is_deleted')::BOOLEAN THEN 'restore'
+            ELSE 'update'
+        END;
+    END IF;
+
+    e.household_id := CASE TG_TABLE_NAME
+        WHEN 'households' THEN (cur_row ->> 'id')::INTEGER
+        WHEN 'transaction_lines' THEN (SELECT household_id FROM transactions WHERE id = (cur_row ->> 'transaction_id')::INTEGER)
+        WHEN 'transaction_tags' THEN (SELECT household_id FROM transactions WHERE id = (cur_row ->> 'transaction_id')::INTEGER)
+        WHEN 'split_shares' THEN (SELECT household_id FROM transaction_splits WHERE id = (cur_row ->> 'split_id')::INTEGER)
+        ELSE (cur_row ->> 'household_id')::INTEGER
+    END;
+    e.actor_id := NULLIF(current_setting('budgetto.actor_id', TRUE), '')::INTEGER;
+    e.request_id := COALESCE(current_setting('budgetto.request_id', TRUE), '');
+    e.ip := COALESCE(current_setting('budgetto.ip', TRUE), '');
+    e.id := nextval(pg_get_serial_sequence('audit_events', 'id'));
+    e.created_at := NOW();
+    e.prev_hash := '';
+    e.hash := '';
+
+    IF current_setting('budgetto.audit_chain', TRUE) = 'on' THEN
+        -- Chained events are written one at a time so each sees the last.
+        PERFORM pg_advisory_xact_lock(hashtext('audit_events'));
+        SELECT hash INTO e.prev_hash FROM audit_events WHERE hash <> '' ORDER BY id DESC LIMIT 1;
+        e.prev_hash := COALESCE(e.prev_hash, '');
+        e.hash := audit_hash(e);
+    END IF;
+
+    INSERT INTO audit_events SELECT (e).*;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP FUNCTION audit_redact;
+-- +goose StatementEnd
@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE household_members ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE personal_access_tokens ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE share_links ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE category_overrides ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+CREATE TRIGGER users_version BEFORE UPDATE ON users FOR EACH ROW EXECUTE FUNCTION bump_version();
+CREATE TRIGGER household_members_version BEFORE UPDATE ON household_members FOR EACH ROW EXECUTE FUNCTION bump_version();
+CREATE TRIGGER category_overrides_version BEFORE UPDATE ON category_overrides FOR EACH ROW EXECUTE FUNCTION bump_version();
+-- Recording use is not a change to the token or link.
+CREATE TRIGGER personal_access_tokens_version BEFORE UPDATE OF name, scopes, expires_at, revoked_at, is_deleted ON personal_access_tokens
+    FOR EACH ROW EXECUTE FUNCTION bump_version();
+CREATE TRIGGER share_links_version BEFORE UPDATE OF resource, resource_id, starts_at, ends_at, passcode_hash, expires_at, revoked_at ON share_links
+    FOR EACH ROW EXECUTE FUNCTION bump_version();
+
+-- Transaction history is now numbered by transactions.version. Move every
+-- transaction up to its latest history entry so the two never collide; the
+-- triggers are off so this is not itself recorded as an edit.
+ALTER TABLE transactions DISABLE TRIGGER USER;
+UPDATE transactions T
+SET version = V.version
+FROM (
+    SELECT transaction_id, MAX(version) AS version
+    FROM transaction_versions
+    GROUP BY transaction_id
+) V
+WHERE
+    V.transaction_id = T.id
+    AND V.version > T.version;
+ALTER TABLE transactions ENABLE TRIGGER USER;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS share_links_version ON share_links;
+DROP TRIGGER IF EXISTS personal_access_tokens_version ON personal_access_tokens;
+DROP TRIGGER IF EXISTS category_overrides_version ON category_overrides;
+DROP TRIGGER IF EXISTS household_members_version ON household_members;
+DROP TRIGGER IF EXISTS users_version ON users;
+
+ALTER TABLE category_overrides DROP COLUMN version;
+ALTER TABLE share_links DROP COLUMN version;
+ALTER TABLE personal_access_tokens DROP COLUMN version;
+ALTER TABLE household_members DROP COLUMN version;
+ALTER TABLE users DROP COLUMN version;
+-- +goose StatementEnd
@@ -0,0 +1,103 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Overrides change how a category reads for one user, so they are part of
+-- that user's feed.
+ALTER TABLE category_overrides ADD COLUMN change_seq BIGINT NOT NULL DEFAULT nextval('sync_change_seq');
+ALTER TABLE sync_tombstones ADD COLUMN user_id INTEGER DEFAULT NULL;
+CREATE INDEX IF NOT EXISTS sync_tombstone_user_idx ON sync_tombstones (user_id, entity_id) WHERE user_id IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION sync_change() RETURNS TRIGGER AS $$
+DECLARE
+    cur_row JSONB := CASE WHEN TG_OP = 'DELETE' THEN to_jsonb(OLD) ELSE to_jsonb(NEW) END;
+    household INTEGER := (cur_row ->> 'household_id')::INTEGER;
+BEGIN
+    -- Numbers are handed out under a lock held until commit, so changes
+    -- become visible in sequence order and a cursor never skips one that
+    -- commits late. Feeds are per household, so only writers to the same
+    -- household wait on each other. Rows seen by every household (global
+    -- categories, and overrides, which follow their user around) wait for
+    -- all of them instead.
+    IF current_setting('budgetto.audit_chain', TRUE) = 'on' THEN
+        -- audit_row takes the chain lock too; taking it first here keeps
+        -- the two locks in one order.
+        PERFORM pg_advisory_xact_lock(hashtext('audit_events'));
+    END IF;
+
+    IF household IS NULL THEN
+        PERFORM pg_advisory_xact_lock(hashtext('sync_change'), 0);
+    ELSE
+        PERFORM pg_advisory_xact_lock_shared(hashtext('sync_change'), 0);
+        PERFORM pg_advisory_xact_lock(hashtext('sync_change'), household);
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO sync_tombstones (household_id, user_id, entity_type, entity_id, change_seq)
+        VALUES (
+            household,
+            CASE WHEN TG_ARGV[0] = 'category_override' THEN (cur_row ->> 'user_id')::INTEGER END,
+            TG_ARGV[0],
+            COALESCE(cur_row ->> 'id', cur_row ->> 'category_id')::INTEGER,
+            nextval('sync_change_seq')
+        );
+        RETURN NULL;
+    END IF;
+
+    NEW.change_seq := nextval('sync_change_seq');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER sync_category_overrides BEFORE INSERT OR UPDATE ON category_overrides
+    FOR EACH ROW EXECUTE FUNCTION sync_change('category_override');
+CREATE TRIGGER sync_category_overrides_delete AFTER DELETE ON category_overrides
+    FOR EACH ROW EXECUTE FUNCTION sync_change('category_override');
+
+-- Transactions carry their tags by name, so renaming, merging or deleting a
+-- tag changes every transaction holding it. It runs before the row goes so
+-- the links are still there to follow.
+CREATE OR REPLACE FUNCTION sync_tag_change() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.name IS NOT DISTINCT FROM OLD.name THEN
+        RETURN NEW;
+    END IF;
+
+    UPDATE transactions
+    SET updated_at = NOW()
+    WHERE id IN (SELECT transaction_id FROM transaction_tags WHERE tag_id = OLD.id);
+
+    RETURN CASE WHEN TG_OP = 'DELETE' THEN OLD ELSE NEW END;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER sync_tags BEFORE UPDATE OF name OR DELETE ON tags
+    FOR EACH ROW EXECUTE FUNCTION sync_tag_change();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER sync_tags ON tags;
+DROP FUNCTION sync_tag_change;
+
+DROP TRIGGER sync_category_overrides_delete ON category_overrides;
+DROP TRIGGER sync_category_overrides ON category_overrides;
+
+CREATE OR REPLACE FUNCTION sync_change() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('audit_events'));
+
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO sync_tombstones (household_id, entity_type, entity_id, change_seq)
+        VALUES (OLD.household_id, TG_ARGV[0], OLD.id, nextval('sync_change_seq'));
+        RETURN NULL;
+    END IF;
+
+    NEW.change_seq := nextval('sync_change_seq');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DELETE FROM sync_tombstones WHERE entity_type = 'category_override';
+DROP INDEX IF EXISTS sync_tombstone_user_idx;
+ALTER TABLE sync_tombstones DROP COLUMN user_id;
+ALTER TABLE category_overrides DROP COLUMN change_seq;
+-- +goose StatementEnd
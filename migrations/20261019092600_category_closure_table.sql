@@ -0,0 +1,101 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The recursive view walked every category in the database each time it was
+-- read, once per budget or category in the outer query. It is kept as a
+-- table instead, updated whenever a category is added or moved.
+DROP VIEW category_closure;
+
+CREATE TABLE IF NOT EXISTS category_closure (
+    ancestor_id INTEGER NOT NULL REFERENCES categories (id) ON DELETE CASCADE,
+    descendant_id INTEGER NOT NULL REFERENCES categories (id) ON DELETE CASCADE,
+    PRIMARY KEY (ancestor_id, descendant_id)
+);
+CREATE INDEX IF NOT EXISTS category_closure_descendant_idx ON category_closure (descendant_id);
+
+INSERT INTO category_closure (ancestor_id, descendant_id)
+WITH RECURSIVE tree (ancestor_id, descendant_id) AS (
+    SELECT id, id FROM categories
+    UNION
+    SELECT T.ancestor_id, C.id
+    FROM tree T JOIN categories C ON C.parent_id = T.descendant_id
+)
+SELECT ancestor_id, descendant_id FROM tree;
+
+-- Refuses a parent that would put a category below itself. Moves take one
+-- lock, held until commit, so two of them can't each pass the check and
+-- close a loop between them, and the closure they read is never stale.
+CREATE OR REPLACE FUNCTION category_parent_guard() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' AND NEW.parent_id IS NULL THEN
+        RETURN NEW;
+    END IF;
+    IF TG_OP = 'UPDATE' AND NEW.parent_id IS NOT DISTINCT FROM OLD.parent_id THEN
+        RETURN NEW;
+    END IF;
+
+    PERFORM pg_advisory_xact_lock(hashtext('category_tree'));
+
+    IF NEW.parent_id = NEW.id OR EXISTS (
+        SELECT 1 FROM category_closure
+        WHERE ancestor_id = NEW.id AND descendant_id = NEW.parent_id
+    ) THEN
+        RAISE EXCEPTION 'category % can''t be moved under %', NEW.id, NEW.parent_id
+            USING ERRCODE = 'check_violation', CONSTRAINT = 'category_parent_cycle';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION category_closure_change() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO category_closure (ancestor_id, descendant_id)
+        SELECT NEW.id, NEW.id
+        UNION ALL
+        SELECT ancestor_id, NEW.id FROM category_closure WHERE descendant_id = NEW.parent_id;
+        RETURN NULL;
+    END IF;
+
+    IF NEW.parent_id IS NOT DISTINCT FROM OLD.parent_id THEN
+        RETURN NULL;
+    END IF;
+
+    -- Cut the moved subtree off from its old ancestors...
+    DELETE FROM category_closure
+    WHERE descendant_id IN (SELECT descendant_id FROM category_closure WHERE ancestor_id = NEW.id)
+        AND ancestor_id NOT IN (SELECT descendant_id FROM category_closure WHERE ancestor_id = NEW.id);
+
+    -- ...and hang it under the new parent's.
+    INSERT INTO category_closure (ancestor_id, descendant_id)
+    SELECT A.ancestor_id, D.descendant_id
+    FROM category_closure A, category_closure D
+    WHERE A.descendant_id = NEW.parent_id AND D.ancestor_id = NEW.id;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER category_parent_guard BEFORE INSERT OR UPDATE OF parent_id ON categories
+    FOR EACH ROW EXECUTE FUNCTION category_parent_guard();
+CREATE TRIGGER category_closure_change AFTER INSERT OR UPDATE OF parent_id ON categories
+    FOR EACH ROW EXECUTE FUNCTION category_closure_change();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER category_closure_change ON categories;
+DROP TRIGGER category_parent_guard ON categories;
+DROP FUNCTION category_closure_change;
+DROP FUNCTION category_parent_guard;
+DROP TABLE category_closure;
+
+CREATE VIEW category_closure AS
+WITH RECURSIVE tree (ancestor_id, descendant_id) AS (
+    SELECT id, id FROM categories
+    UNION
+    SELECT T.ancestor_id, C.id
+    FROM tree T JOIN categories C ON C.parent_id = T.descendant_id
+)
+SELECT ancestor_id, descendant_id FROM tree;
+-- +goose StatementEnd
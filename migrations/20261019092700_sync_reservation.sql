@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- When a reservation was last claimed, so one left behind by a create that
+-- never finished can be claimed again instead of blocking its client id.
+ALTER TABLE sync_client_ids ADD COLUMN reserved_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE sync_client_ids DROP COLUMN reserved_at;
+-- +goose StatementEnd